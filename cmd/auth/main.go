@@ -28,6 +28,7 @@ func main() {
 	)
 	application := app.New(ctx, log, cfg)
 	go application.GRPCServer.MustRun()
+	go application.HTTPServer.MustRun()
 
 	profApplication := pprofapp.New(cfg.ProfileServer.Host, cfg.ProfileServer.Port)
 	go profApplication.Start()
@@ -49,6 +50,7 @@ func main() {
 
 	log.Info("stopping application", slog.String("signal", sign.String()))
 	application.GRPCServer.Stop()
+	application.HTTPServer.Stop()
 	log.Info("application stopped")
 }
 
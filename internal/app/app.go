@@ -5,7 +5,19 @@ import (
 	"context"
 
 	grpcapp "AuthGrpc/internal/app/grpc"
+	httpapp "AuthGrpc/internal/app/http"
 	"AuthGrpc/internal/config"
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/grpc/interceptor"
+	"AuthGrpc/internal/lib/encryption/jwt"
+	"AuthGrpc/internal/lib/encryption/keyring"
+	"AuthGrpc/internal/lib/encryption/signer"
+	"AuthGrpc/internal/lib/encryption/symmetric"
+	"AuthGrpc/internal/lib/password"
+	"AuthGrpc/internal/lib/webauthn"
+	"AuthGrpc/internal/pkg/i18n"
+	"AuthGrpc/internal/pkg/notifier"
+	"AuthGrpc/internal/pkg/server"
 	"AuthGrpc/internal/pkg/storage/sqlite"
 	"AuthGrpc/internal/services/auth"
 	"log/slog"
@@ -13,6 +25,7 @@ import (
 
 type App struct {
 	GRPCServer *grpcapp.App
+	HTTPServer *httpapp.App
 }
 
 func New(ctx context.Context, log *slog.Logger, cfg *config.Config) *App {
@@ -24,8 +37,215 @@ func New(ctx context.Context, log *slog.Logger, cfg *config.Config) *App {
 	if err != nil {
 		panic(err)
 	}
-	authService := auth.New(log, storage, cache, cfg.JWT.AccessTokenTTL, cfg.JWT.RefreshTokenTTL, cfg.JWT.SecretKey)
+	algorithm, err := signer.ParseAlgorithm(cfg.JWT.Algorithm)
+	if err != nil {
+		panic(err)
+	}
+	oauthSettings := auth.OAuthSettings{
+		LoginURL:           cfg.OAuth.LoginURL,
+		CodeTTL:            cfg.OAuth.CodeTTL,
+		RequestTTL:         cfg.OAuth.RequestTTL,
+		IDTokenTTL:         cfg.OAuth.IDTokenTTL,
+		VerificationURL:    cfg.OAuth.VerificationURL,
+		DeviceCodeTTL:      cfg.OAuth.DeviceCodeTTL,
+		DevicePollInterval: cfg.OAuth.DevicePollInterval,
+	}
+	// A retired key must outlive every token it signed, ID tokens included.
+	retention := auth.SigningKeyRetention(cfg.JWT.AccessTokenTTL, oauthSettings)
+	keys, err := keyring.New(log, cfg.JWT.KeysPath, cfg.JWT.PrivateKeyPath, algorithm, cfg.JWT.KeyRotationInterval, retention)
+	if err != nil {
+		panic(err)
+	}
+	go keys.Run(ctx, cfg.JWT.KeyCheckInterval)
+
+	tokenOptions := jwt.Options{Issuer: cfg.JWT.Issuer, Audience: cfg.JWT.Audience}
+	mfaKey, err := symmetric.GenerateKey(cfg.MFA.EncryptionKeyPath)
+	if err != nil {
+		panic(err)
+	}
+
+	notifications, err := newNotifier(cfg.Notifier)
+	if err != nil {
+		panic(err)
+	}
+	queue := notifier.NewQueue(log, notifications, cfg.Notifier.QueueSize, cfg.Notifier.MaxAttempts, cfg.Notifier.RetryBackoff)
+	for i := 0; i < cfg.Notifier.Workers; i++ {
+		go queue.Run(ctx)
+	}
+
+	catalog, err := i18n.Load(cfg.I18n.TemplatesDir, cfg.I18n.DefaultLocale)
+	if err != nil {
+		panic(err)
+	}
+
+	passwordPolicy, err := newPasswordPolicy(cfg.Password)
+	if err != nil {
+		panic(err)
+	}
 
-	grpcApp := grpcapp.New(log, authService, cfg.GRPC.Port)
-	return &App{GRPCServer: grpcApp}
+	authOptions := []auth.Option{
+		auth.WithCatalog(catalog),
+		auth.WithPasswordPolicy(passwordPolicy, cfg.Password.ReportBreachedLogins),
+		auth.WithPasswordHasher(newPasswordHasher(cfg.Hashing)),
+		auth.WithNotifier(queue, auth.Links{
+			ResetPassword: cfg.Notifier.ResetPasswordURL,
+			VerifyEmail:   cfg.Notifier.VerifyEmailURL,
+			MagicLink:     cfg.Notifier.MagicLinkURL,
+		}),
+		auth.WithMFA(mfaKey, cfg.MFA.Issuer, cfg.MFA.ChallengeTTL),
+		auth.WithEmailVerification(cfg.Verification.Required, cfg.Verification.TokenTTL),
+		auth.WithMagicLinkTTL(cfg.MagicLink.TokenTTL),
+		auth.WithOAuth(oauthSettings),
+		auth.WithLockout(auth.LockoutPolicy{
+			Threshold:   cfg.Lockout.Threshold,
+			Duration:    cfg.Lockout.Duration,
+			MaxDuration: cfg.Lockout.MaxDuration,
+			IPThreshold: cfg.Lockout.IPThreshold,
+			IPWindow:    cfg.Lockout.IPWindow,
+		}),
+	}
+	if cfg.WebAuthn.RPID != "" {
+		authOptions = append(authOptions, auth.WithWebAuthn(webauthn.Config{
+			RPID:             cfg.WebAuthn.RPID,
+			RPName:           cfg.WebAuthn.RPName,
+			Origins:          cfg.WebAuthn.Origins,
+			UserVerification: cfg.WebAuthn.UserVerification,
+			Timeout:          cfg.WebAuthn.Timeout,
+		}))
+	}
+
+	authService := auth.New(log, storage, cache, cfg.JWT.AccessTokenTTL, cfg.JWT.RefreshTokenTTL, keys, tokenOptions, authOptions...)
+	for _, client := range cfg.OAuth.Clients {
+		err := authService.RegisterOAuthClient(ctx, models.OAuthClient{
+			ID:           client.ID,
+			Name:         client.Name,
+			RedirectURIs: client.RedirectURIs,
+			Scopes:       client.Scopes,
+			GrantTypes:   client.GrantTypes,
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// Locale comes first so it also translates the rate limiter's errors.
+	grpcApp := grpcapp.New(log, authService, cfg.GRPC.Port,
+		interceptor.Locale(catalog),
+		interceptor.RateLimit(cache, rateLimits(cfg.RateLimit)),
+	)
+	httpApp := httpapp.New(log, authService, httpOptions(cfg.HTTP)...)
+	return &App{GRPCServer: grpcApp, HTTPServer: httpApp}
+}
+
+func newNotifier(cfg config.Notifier) (notifier.Notifier, error) {
+	var email, sms notifier.Notifier
+	switch cfg.Email.Driver {
+	case "smtp":
+		email = notifier.NewSMTPSender(cfg.Email.Host, cfg.Email.Port, cfg.Email.Username, cfg.Email.Password, cfg.Email.From, notifier.SMTPSecurity(cfg.Email.TLS))
+	default:
+		sender, err := notifier.NewFileSender(cfg.Email.Path)
+		if err != nil {
+			return nil, err
+		}
+		email = sender
+	}
+	switch cfg.SMS.Driver {
+	case "http":
+		sms = notifier.NewHTTPSMSSender(cfg.SMS.URL, cfg.SMS.Token, cfg.SMS.From)
+	default:
+		sender, err := notifier.NewFileSender(cfg.SMS.Path)
+		if err != nil {
+			return nil, err
+		}
+		sms = sender
+	}
+	return notifier.Mux{notifier.Email: email, notifier.SMS: sms}, nil
+}
+
+func newPasswordPolicy(cfg config.Password) (password.Policy, error) {
+	policy := password.Policy{
+		MinLength:      cfg.MinLength,
+		MaxBytes:       cfg.MaxBytes,
+		RequireLower:   cfg.RequireLower,
+		RequireUpper:   cfg.RequireUpper,
+		RequireDigit:   cfg.RequireDigit,
+		RequireSymbol:  cfg.RequireSymbol,
+		ForbidUserInfo: cfg.ForbidUserInfo,
+		Blocklist:      password.DefaultPolicy().Blocklist,
+	}
+	if cfg.BlocklistPath != "" {
+		blocklist, err := password.LoadBlocklist(cfg.BlocklistPath)
+		if err != nil {
+			return password.Policy{}, err
+		}
+		policy.Blocklist = blocklist
+	}
+	if cfg.BreachListPath != "" {
+		breaches, err := password.OpenBreachList(cfg.BreachListPath, cfg.BreachListHash)
+		if err != nil {
+			return password.Policy{}, err
+		}
+		policy.Breaches = breaches
+	}
+	return policy, nil
+}
+
+func newPasswordHasher(cfg config.Hashing) password.Hasher {
+	if cfg.Algorithm == "bcrypt" {
+		return password.NewBcryptHasher(cfg.BcryptCost)
+	}
+	params := password.DefaultArgon2Params
+	if cfg.Argon2.Memory > 0 {
+		params.Memory = cfg.Argon2.Memory
+	}
+	if cfg.Argon2.Iterations > 0 {
+		params.Iterations = cfg.Argon2.Iterations
+	}
+	if cfg.Argon2.Parallelism > 0 {
+		params.Parallelism = cfg.Argon2.Parallelism
+	}
+	if cfg.Argon2.SaltLength > 0 {
+		params.SaltLength = cfg.Argon2.SaltLength
+	}
+	if cfg.Argon2.KeyLength > 0 {
+		params.KeyLength = cfg.Argon2.KeyLength
+	}
+	return password.NewArgon2idHasher(params)
+}
+
+func rateLimits(cfg config.RateLimit) map[string]interceptor.Limit {
+	limits := make(map[string]interceptor.Limit, len(cfg.Methods))
+	for method, limit := range cfg.Methods {
+		l := interceptor.Limit{
+			Rate:  float64(limit.Requests) / limit.Per.Seconds(),
+			Burst: limit.Burst,
+		}
+		for _, key := range limit.Keys {
+			switch key {
+			case "ip":
+				l.ByIP = true
+			case "login":
+				l.ByLogin = true
+			}
+		}
+		limits[method] = l
+	}
+	return limits
+}
+
+func httpOptions(cfg config.HTTP) []server.Option {
+	opts := []server.Option{
+		server.WithHost(cfg.Host),
+		server.WithPort(cfg.Port),
+	}
+	if cfg.MaxHeaderBytes > 0 {
+		opts = append(opts, server.WithMaxHeaderBytes(cfg.MaxHeaderBytes))
+	}
+	if cfg.ReadTimeout > 0 {
+		opts = append(opts, server.WithReadTimeout(cfg.ReadTimeout))
+	}
+	if cfg.WriteTimeout > 0 {
+		opts = append(opts, server.WithWriteTimeout(cfg.WriteTimeout))
+	}
+	return opts
 }
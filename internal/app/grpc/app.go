@@ -14,8 +14,8 @@ type App struct {
 	port       int
 }
 
-func New(log *slog.Logger, authService authgrpc.Authenticator, port int) *App {
-	gRPCServer := grpc.NewServer()
+func New(log *slog.Logger, authService authgrpc.Authenticator, port int, interceptors ...grpc.UnaryServerInterceptor) *App {
+	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
 	authgrpc.Register(gRPCServer, authService)
 	return &App{
 		log:        log,
@@ -0,0 +1,57 @@
+package httpapp
+
+import (
+	authhttp "AuthGrpc/internal/http/auth"
+	"AuthGrpc/internal/pkg/server"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+type App struct {
+	log        *slog.Logger
+	httpServer *server.Server
+	addr       string
+}
+
+func New(log *slog.Logger, authenticator authhttp.Authenticator, opts ...server.Option) *App {
+	mux := http.NewServeMux()
+	authhttp.Register(mux, log, authenticator)
+
+	httpServer := server.New(mux, opts...)
+	return &App{
+		log:        log,
+		httpServer: httpServer,
+		addr:       httpServer.Addr(),
+	}
+}
+
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+func (a *App) Run() error {
+	const op = "httpapp.Run"
+
+	a.log.With(slog.String("op", op)).Info("http server is running", slog.String("addr", a.addr))
+	if err := a.httpServer.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (a *App) Stop() {
+	const op = "httpapp.Stop"
+
+	a.log.With(slog.String("op", op)).Info("stopping http server", slog.String("addr", a.addr))
+	if err := a.httpServer.Stop(context.Background(), shutdownTimeout); err != nil {
+		a.log.Error("failed to stop http server", slog.String("error", err.Error()))
+	}
+}
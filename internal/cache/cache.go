@@ -9,4 +9,10 @@ type Cacher interface {
 	Set(ctx context.Context, key string, value interface{}, duration time.Duration) error
 	Get(ctx context.Context, key string) (interface{}, bool)
 	Delete(ctx context.Context, key string) error
+	// Take returns the value stored under key and deletes it in one step, so
+	// of several concurrent callers only one gets the value.
+	Take(ctx context.Context, key string) (interface{}, bool)
+	// Increment adds delta to the int stored under key and returns the sum.
+	// A missing or expired key starts from zero and expires after duration.
+	Increment(ctx context.Context, key string, delta int, duration time.Duration) (int, error)
 }
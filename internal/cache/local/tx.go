@@ -94,6 +94,61 @@ func (c *Cache) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+func (c *Cache) Take(ctx context.Context, key string) (interface{}, bool) {
+	select {
+	case <-ctx.Done():
+		return nil, false
+	default:
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	item, found := c.items[key]
+	if !found {
+		return nil, false
+	}
+	delete(c.items, key)
+	if item.Expiration > 0 && time.Now().UnixNano() > item.Expiration {
+		return nil, false
+	}
+	return item.Value, true
+}
+
+func (c *Cache) Increment(ctx context.Context, key string, delta int, duration time.Duration) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	if key == "" {
+		return 0, errors.New("key cannot be empty")
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+	item, found := c.items[key]
+	if !found || (item.Expiration > 0 && now.UnixNano() > item.Expiration) {
+		if duration == 0 {
+			duration = c.defaultExpiration
+		}
+		item = Item{Value: 0, Created: now}
+		if duration > 0 {
+			item.Expiration = now.Add(duration).UnixNano()
+		}
+	}
+	n, ok := item.Value.(int)
+	if !ok {
+		return 0, errors.New("value is not an int")
+	}
+	item.Value = n + delta
+	c.items[key] = item
+	return n + delta, nil
+}
+
 func (c *Cache) startGC() {
 	go c.gc()
 }
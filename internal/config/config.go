@@ -1,12 +1,13 @@
 package config
 
 import (
-	myecdsa "AuthGrpc/internal/lib/encryption/ecdsa"
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"io"
 	"log"
+	"log/slog"
 	"os"
+	"slices"
 	"sync"
 	"time"
 )
@@ -28,6 +29,17 @@ type (
 		Logger        Logger        `yaml:"logger"`
 		GRPC          GRPCConfig    `yaml:"grpc"`
 		JWT           JWT           `yaml:"jwt"`
+		MFA           MFA           `yaml:"mfa"`
+		Lockout       Lockout       `yaml:"lockout"`
+		Password      Password      `yaml:"passwordPolicy"`
+		Hashing       Hashing       `yaml:"passwordHashing"`
+		RateLimit     RateLimit     `yaml:"rateLimit"`
+		Verification  Verification  `yaml:"emailVerification"`
+		MagicLink     MagicLink     `yaml:"magicLink"`
+		WebAuthn      WebAuthn      `yaml:"webauthn"`
+		OAuth         OAuth         `yaml:"oauth"`
+		Notifier      Notifier      `yaml:"notifier"`
+		I18n          I18n          `yaml:"i18n"`
 		ProfileServer ProfileServer `yaml:"profileServer"`
 		Profile       Profile       `yaml:"profile"`
 	}
@@ -59,11 +71,169 @@ type (
 	}
 
 	JWT struct {
-		AccessTokenTTL  time.Duration `yaml:"accessTokenTTL"`
-		RefreshTokenTTL time.Duration `yaml:"refreshTokenTTL"`
-		PrivateKeyPath  string        `yaml:"privateKeyPath"`
-		SecretKey       []byte
+		AccessTokenTTL      time.Duration `yaml:"accessTokenTTL"`
+		RefreshTokenTTL     time.Duration `yaml:"refreshTokenTTL"`
+		PrivateKeyPath      string        `yaml:"privateKeyPath"`
+		Algorithm           string        `yaml:"algorithm"`
+		Issuer              string        `yaml:"issuer"`
+		Audience            []string      `yaml:"audience"`
+		KeysPath            string        `yaml:"keysPath"`
+		KeyRotationInterval time.Duration `yaml:"keyRotationInterval"`
+		KeyCheckInterval    time.Duration `yaml:"keyCheckInterval"`
 	}
+
+	Verification struct {
+		Required bool          `yaml:"required"`
+		TokenTTL time.Duration `yaml:"tokenTTL"`
+	}
+
+	MagicLink struct {
+		TokenTTL time.Duration `yaml:"tokenTTL"`
+	}
+
+	MFA struct {
+		EncryptionKeyPath string        `yaml:"encryptionKeyPath"`
+		Issuer            string        `yaml:"issuer"`
+		ChallengeTTL      time.Duration `yaml:"challengeTTL"`
+	}
+
+	Lockout struct {
+		Threshold   int           `yaml:"threshold"`
+		Duration    time.Duration `yaml:"duration"`
+		MaxDuration time.Duration `yaml:"maxDuration"`
+		IPThreshold int           `yaml:"ipThreshold"`
+		IPWindow    time.Duration `yaml:"ipWindow"`
+	}
+
+	Password struct {
+		MinLength      int  `yaml:"minLength"`
+		MaxBytes       int  `yaml:"maxBytes"`
+		RequireLower   bool `yaml:"requireLower"`
+		RequireUpper   bool `yaml:"requireUpper"`
+		RequireDigit   bool `yaml:"requireDigit"`
+		RequireSymbol  bool `yaml:"requireSymbol"`
+		ForbidUserInfo bool `yaml:"forbidUserInfo"`
+		// BlocklistPath is a file of refused passwords, one per line,
+		// added to the built-in list.
+		BlocklistPath string `yaml:"blocklistPath"`
+		// BreachListPath is a Have I Been Pwned hash list: a sorted file
+		// or a directory of range files.
+		BreachListPath string `yaml:"breachListPath"`
+		// BreachListHash is sha1 or ntlm.
+		BreachListHash string `yaml:"breachListHash"`
+		// ReportBreachedLogins logs logins with a breached password
+		// instead of refusing them.
+		ReportBreachedLogins bool `yaml:"reportBreachedLogins"`
+	}
+
+	Hashing struct {
+		// Algorithm is argon2id or bcrypt. Hashes made with the other one
+		// or other parameters are replaced on login.
+		Algorithm  string `yaml:"algorithm"`
+		Argon2     Argon2 `yaml:"argon2"`
+		BcryptCost int    `yaml:"bcryptCost"`
+	}
+
+	Argon2 struct {
+		// Memory is in KiB.
+		Memory      uint32 `yaml:"memory"`
+		Iterations  uint32 `yaml:"iterations"`
+		Parallelism uint8  `yaml:"parallelism"`
+		SaltLength  uint32 `yaml:"saltLength"`
+		KeyLength   uint32 `yaml:"keyLength"`
+	}
+
+	RateLimit struct {
+		// Methods is keyed by the full gRPC method name or the bare
+		// method name, e.g. Login.
+		Methods map[string]MethodLimit `yaml:"methods"`
+	}
+
+	MethodLimit struct {
+		Requests int           `yaml:"requests"`
+		Per      time.Duration `yaml:"per"`
+		// Burst defaults to Requests.
+		Burst int `yaml:"burst"`
+		// Keys lists what clients are told apart by: ip, login or both.
+		Keys []string `yaml:"keys"`
+	}
+
+	WebAuthn struct {
+		RPID             string        `yaml:"rpId"`
+		RPName           string        `yaml:"rpName"`
+		Origins          []string      `yaml:"origins"`
+		UserVerification string        `yaml:"userVerification"`
+		Timeout          time.Duration `yaml:"timeout"`
+	}
+
+	OAuth struct {
+		// LoginURL is the page /authorize sends users to for signing in
+		// and consent.
+		LoginURL   string        `yaml:"loginURL"`
+		CodeTTL    time.Duration `yaml:"codeTTL"`
+		RequestTTL time.Duration `yaml:"requestTTL"`
+		IDTokenTTL time.Duration `yaml:"idTokenTTL"`
+		// VerificationURL is the page where users enter the code a device
+		// shows them.
+		VerificationURL    string        `yaml:"verificationURL"`
+		DeviceCodeTTL      time.Duration `yaml:"deviceCodeTTL"`
+		DevicePollInterval time.Duration `yaml:"devicePollInterval"`
+		Clients            []OAuthClient `yaml:"clients"`
+	}
+
+	OAuthClient struct {
+		ID           string   `yaml:"id"`
+		Name         string   `yaml:"name"`
+		RedirectURIs []string `yaml:"redirectURIs"`
+		Scopes       []string `yaml:"scopes"`
+		// GrantTypes default to authorization_code and refresh_token.
+		GrantTypes []string `yaml:"grantTypes"`
+	}
+
+	Notifier struct {
+		Email            EmailSender   `yaml:"email"`
+		SMS              SMSSender     `yaml:"sms"`
+		QueueSize        int           `yaml:"queueSize"`
+		Workers          int           `yaml:"workers"`
+		MaxAttempts      int           `yaml:"maxAttempts"`
+		RetryBackoff     time.Duration `yaml:"retryBackoff"`
+		ResetPasswordURL string        `yaml:"resetPasswordURL"`
+		VerifyEmailURL   string        `yaml:"verifyEmailURL"`
+		MagicLinkURL     string        `yaml:"magicLinkURL"`
+	}
+
+	EmailSender struct {
+		// Driver is smtp or file.
+		Driver   string `yaml:"driver"`
+		Path     string `yaml:"path"`
+		Host     string `yaml:"host"`
+		Port     int    `yaml:"port"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+		From     string `yaml:"from"`
+		// TLS is starttls, implicit or none; it defaults to implicit on
+		// port 465 and starttls otherwise. none sends in the clear.
+		TLS string `yaml:"tls"`
+	}
+
+	SMSSender struct {
+		// Driver is http or file.
+		Driver string `yaml:"driver"`
+		Path   string `yaml:"path"`
+		URL    string `yaml:"url"`
+		Token  string `yaml:"token"`
+		From   string `yaml:"from"`
+	}
+
+	I18n struct {
+		// DefaultLocale is used when neither the user nor the request
+		// picks a supported locale.
+		DefaultLocale string `yaml:"defaultLocale"`
+		// TemplatesDir holds one directory per locale whose files replace
+		// the built-in templates of the same name.
+		TemplatesDir string `yaml:"templatesDir"`
+	}
+
 	ProfileServer struct {
 		Host string `yaml:"host"`
 		Port int    `yaml:"port"`
@@ -86,6 +256,28 @@ func (c *Config) IsProd() bool {
 	return c.Environment == prod
 }
 
+// redacted replaces a set secret when the config is logged or printed.
+const redacted = "[REDACTED]"
+
+// Redacted returns a copy of the config with its secrets replaced, safe to
+// log or print.
+func (c Config) Redacted() Config {
+	if c.Notifier.Email.Password != "" {
+		c.Notifier.Email.Password = redacted
+	}
+	if c.Notifier.SMS.Token != "" {
+		c.Notifier.SMS.Token = redacted
+	}
+	return c
+}
+
+// LogValue logs the config without its secrets.
+func (c Config) LogValue() slog.Value {
+	// plain has no LogValue method, so slog does not resolve it again.
+	type plain Config
+	return slog.AnyValue(plain(c.Redacted()))
+}
+
 var (
 	instance Config
 	once     sync.Once
@@ -119,13 +311,93 @@ func Get() *Config {
 			log.Fatal("config environment should be test, prod or dev")
 		}
 
-		instance.JWT.SecretKey, err = myecdsa.GenerateECDSAKey(instance.JWT.PrivateKeyPath)
-		if err != nil {
-			log.Fatalf("Error initializing JWT secret key: %v", err)
+		if instance.JWT.Issuer == "" {
+			log.Fatal("config jwt.issuer is required")
+		}
+		if instance.JWT.Algorithm == "" {
+			instance.JWT.Algorithm = "ES256"
+		}
+		if instance.JWT.KeysPath == "" {
+			log.Fatal("config jwt.keysPath is required")
+		}
+		if instance.JWT.KeyCheckInterval <= 0 {
+			instance.JWT.KeyCheckInterval = time.Minute
+		}
+
+		switch instance.Notifier.Email.Driver {
+		case "":
+			instance.Notifier.Email.Driver = "file"
+		case "smtp", "file":
+		default:
+			log.Fatal("config notifier.email.driver should be smtp or file")
+		}
+		switch instance.Notifier.Email.TLS {
+		case "", "starttls", "implicit", "none":
+		default:
+			log.Fatal("config notifier.email.tls should be starttls, implicit or none")
+		}
+		switch instance.Notifier.SMS.Driver {
+		case "":
+			instance.Notifier.SMS.Driver = "file"
+		case "http", "file":
+		default:
+			log.Fatal("config notifier.sms.driver should be http or file")
+		}
+		if instance.Notifier.Workers <= 0 {
+			instance.Notifier.Workers = 1
+		}
+		switch instance.Hashing.Algorithm {
+		case "":
+			instance.Hashing.Algorithm = "argon2id"
+		case "argon2id", "bcrypt":
+		default:
+			log.Fatal("config passwordHashing.algorithm should be argon2id or bcrypt")
+		}
+		if instance.Password.MaxBytes <= 0 {
+			instance.Password.MaxBytes = 256
+		}
+		if instance.Hashing.Algorithm == "bcrypt" && instance.Password.MaxBytes > 72 {
+			log.Fatal("config passwordPolicy.maxBytes cannot exceed 72 with bcrypt")
+		}
+		switch instance.Password.BreachListHash {
+		case "":
+			instance.Password.BreachListHash = "sha1"
+		case "sha1", "ntlm":
+		default:
+			log.Fatal("config passwordPolicy.breachListHash should be sha1 or ntlm")
+		}
+		for method, limit := range instance.RateLimit.Methods {
+			if limit.Requests <= 0 || limit.Per <= 0 {
+				log.Fatalf("config rateLimit.methods.%s needs requests and per", method)
+			}
+			for _, key := range limit.Keys {
+				if key != "ip" && key != "login" {
+					log.Fatalf("config rateLimit.methods.%s.keys should be ip or login", method)
+				}
+			}
+			if limit.Burst <= 0 {
+				limit.Burst = limit.Requests
+			}
+			if len(limit.Keys) == 0 {
+				limit.Keys = []string{"ip"}
+			}
+			instance.RateLimit.Methods[method] = limit
+		}
+		for _, client := range instance.OAuth.Clients {
+			if (len(client.GrantTypes) == 0 || slices.Contains(client.GrantTypes, "authorization_code")) && instance.OAuth.LoginURL == "" {
+				log.Fatalf("config oauth.loginURL is required by client %s", client.ID)
+			}
+			if slices.Contains(client.GrantTypes, "urn:ietf:params:oauth:grant-type:device_code") && instance.OAuth.VerificationURL == "" {
+				log.Fatalf("config oauth.verificationURL is required by client %s", client.ID)
+			}
+		}
+		if instance.I18n.DefaultLocale == "" {
+			instance.I18n.DefaultLocale = "en"
 		}
 
 		if instance.IsDev() {
-			configBytes, err := yaml.Marshal(&instance)
+			redactedConfig := instance.Redacted()
+			configBytes, err := yaml.Marshal(&redactedConfig)
 			if err != nil {
 				log.Fatalf("Error marshaling config to YAML: %v", err)
 			}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"bytes"
+	"gopkg.in/yaml.v3"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestConfigRedactsSecrets(t *testing.T) {
+	const (
+		password = "smtp-password"
+		token    = "sms-token"
+	)
+	cfg := &Config{}
+	cfg.Notifier.Email.Password = password
+	cfg.Notifier.SMS.Token = token
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("config", slog.Any("cfg", cfg))
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("config", slog.Any("cfg", cfg))
+	dump, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		t.Fatalf("yaml.Marshal: %v", err)
+	}
+	buf.Write(dump)
+
+	out := buf.String()
+	for _, secret := range []string{password, token} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("secret %q in output:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, redacted) {
+		t.Fatalf("no %s in output:\n%s", redacted, out)
+	}
+	if cfg.Notifier.Email.Password != password {
+		t.Fatal("Redacted changed the config")
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// AccountLock tracks failed password logins of one user.
+type AccountLock struct {
+	UserID int64
+	// FailedAttempts counts failures since the last successful login or
+	// lockout.
+	FailedAttempts int
+	// Lockouts counts the lockouts since the last successful login; each one
+	// lasts twice as long as the one before.
+	Lockouts     int
+	LockedUntil  time.Time
+	LastFailedAt time.Time
+}
+
+func (l AccountLock) Locked(now time.Time) bool {
+	return now.Before(l.LockedUntil)
+}
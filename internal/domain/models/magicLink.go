@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// MagicLink signs its user in once, when followed before ExpiresAt. A link
+// bound to the device that asked for it carries the hash of a secret that
+// device holds in DeviceHash.
+type MagicLink struct {
+	ID         int64
+	UserID     int64
+	TokenHash  string
+	Email      string
+	DeviceHash string
+	Used       bool
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
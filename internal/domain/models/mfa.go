@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+type MFAFactor struct {
+	ID     int64
+	UserID int64
+	// Secret is the TOTP secret encrypted with the MFA key.
+	Secret       []byte
+	Confirmed    bool
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// MFAChallenge is the state kept between the password step of Login and
+// VerifyMFA.
+type MFAChallenge struct {
+	ID int64
+	// TokenHash is the SHA-256 of the MFA token returned by Login.
+	TokenHash string
+	UserID    int64
+	Client    ClientInfo
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+type LoginResult struct {
+	Tokens TokenPair
+	// MFAToken is set instead of Tokens when the user has to pass a second
+	// factor with VerifyMFA.
+	MFAToken string
+}
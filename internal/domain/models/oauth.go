@@ -0,0 +1,154 @@
+package models
+
+import "time"
+
+// OAuthClient is an application allowed to send users to /authorize. It
+// may only redirect back to one of its RedirectURIs and ask for a subset of
+// its Scopes.
+type OAuthClient struct {
+	ID           string
+	Name         string
+	RedirectURIs []string
+	Scopes       []string
+	// GrantTypes are the grants the client may use at /token.
+	GrantTypes []string
+	// AuthMethod is how the client authenticates at /token: "none" for a
+	// public client, or client_secret_basic, client_secret_post or
+	// private_key_jwt for a confidential one.
+	AuthMethod string
+	// SecretHash is the SHA-256 of the client secret.
+	SecretHash string
+	// JWKS is the JWK Set a private_key_jwt client signs assertions with.
+	JWKS      string
+	Disabled  bool
+	CreatedAt time.Time
+}
+
+// ClientCredentials is what a client presented at /token to identify
+// itself: a secret, a signed assertion, or only its ID if it is public.
+type ClientCredentials struct {
+	ID string
+	// Method is the token endpoint auth method the request used, which must
+	// be the one the client registered.
+	Method        string
+	Secret        string
+	Assertion     string
+	AssertionType string
+}
+
+// AuthorizationRequest is what a client asked /authorize for. It is kept
+// while the user signs in and decides whether to grant it.
+type AuthorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// Nonce is echoed in the ID token of an OpenID Connect request.
+	Nonce     string
+	ExpiresAt time.Time
+}
+
+// ConsentPrompt is what the consent page shows the signed-in user.
+type ConsentPrompt struct {
+	ClientID   string
+	ClientName string
+	Scopes     []string
+	// ConsentRequired is false when the user already granted every scope,
+	// so the page may approve without asking again.
+	ConsentRequired bool
+}
+
+// AuthorizationCode is a granted authorization request, exchanged once at
+// /token by the client that presents the PKCE verifier. SessionID is set
+// when it is exchanged, so the session can be revoked if it is replayed.
+type AuthorizationCode struct {
+	ID            int64
+	CodeHash      string
+	ClientID      string
+	UserID        int64
+	RedirectURI   string
+	Scope         string
+	CodeChallenge string
+	Nonce         string
+	// AuthTime is when the user signed in to approve the request.
+	AuthTime  time.Time
+	SessionID string
+	Used      bool
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// OAuthConsent records the scopes a user granted a client.
+type OAuthConsent struct {
+	UserID    int64
+	ClientID  string
+	Scope     string
+	GrantedAt time.Time
+}
+
+// OAuthTokens is the /token response.
+type OAuthTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+	Scope        string
+	// IDToken is set when the openid scope was granted.
+	IDToken string
+}
+
+// OpenIDProvider describes what the OpenID Connect discovery document
+// advertises.
+type OpenIDProvider struct {
+	Issuer            string
+	SigningAlgorithms []string
+	Scopes            []string
+	Claims            []string
+	GrantTypes        []string
+	// TokenEndpointAuthMethods and TokenEndpointAuthAlgorithms are how
+	// clients may authenticate at /token.
+	TokenEndpointAuthMethods    []string
+	TokenEndpointAuthAlgorithms []string
+}
+
+// Statuses of a DeviceAuthorization.
+const (
+	DeviceAuthorizationPending  = "pending"
+	DeviceAuthorizationApproved = "approved"
+	DeviceAuthorizationDenied   = "denied"
+	DeviceAuthorizationUsed     = "used"
+)
+
+// DeviceAuthorization is a pending device authorization grant of RFC 8628.
+// The device polls with the device code while the user approves the user
+// code on another device. Interval is how often the device may poll; it
+// grows each time the device polls too fast.
+type DeviceAuthorization struct {
+	ID             int64
+	DeviceCodeHash string
+	UserCodeHash   string
+	ClientID       string
+	Scope          string
+	Status         string
+	UserID         int64
+	// AuthTime is when the user who approved signed in.
+	AuthTime     time.Time
+	SessionID    string
+	Interval     time.Duration
+	LastPolledAt time.Time
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+// DeviceCode is the device authorization response, see RFC 8628 section
+// 3.2.
+type DeviceCode struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               time.Duration
+	Interval                time.Duration
+}
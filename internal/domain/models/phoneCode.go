@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PhoneCode is a one-time code sent by SMS to prove control of Phone.
+type PhoneCode struct {
+	ID        int64
+	UserID    int64
+	Phone     string
+	CodeHash  string
+	Attempts  int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type RecoveryCode struct {
+	ID        int64
+	UserID    int64
+	CodeHash  []byte
+	Used      bool
+	CreatedAt time.Time
+}
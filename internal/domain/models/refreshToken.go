@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	FamilyID  string
+	Used      bool
+	Revoked   bool
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+}
@@ -5,7 +5,7 @@ import "time"
 type ResetToken struct {
 	ID        int64
 	UserID    int64
-	Token     string
+	TokenHash string
 	CreatedAt time.Time
 	ExpiresAt time.Time
 }
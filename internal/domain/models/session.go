@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID         string
+	UserID     int64
+	DeviceName string
+	UserAgent  string
+	IP         string
+	// ClientID and Scope are set for sessions an OAuth client signed the
+	// user into; its access tokens carry them.
+	ClientID   string
+	Scope      string
+	Revoked    bool
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	// Current marks the session the listing request was made from.
+	Current bool
+}
+
+// ClientInfo describes the device a request came from.
+type ClientInfo struct {
+	DeviceName string
+	UserAgent  string
+	IP         string
+}
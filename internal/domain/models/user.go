@@ -7,4 +7,12 @@ type User struct {
 	Email    string
 	PassHash []byte
 	Role     string
+	// TokenVersion is bumped whenever the password changes; tokens carrying
+	// an older version are rejected.
+	TokenVersion  int64
+	EmailVerified bool
+	PhoneVerified bool
+	// Locale is the language the user prefers notifications in, or empty to
+	// follow the request.
+	Locale string
 }
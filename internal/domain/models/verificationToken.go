@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// VerificationToken proves control of Email, the address the user had when
+// it was issued.
+type VerificationToken struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
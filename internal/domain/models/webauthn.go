@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+type WebAuthnCredential struct {
+	ID           int64
+	UserID       int64
+	CredentialID []byte
+	// PublicKey is the credential public key as a COSE_Key.
+	PublicKey  []byte
+	SignCount  uint32
+	AAGUID     []byte
+	Name       string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+// WebAuthnChallenge is the state kept between the start and the end of a
+// registration or login ceremony. UserID is zero for a login that lets the
+// authenticator pick the account.
+type WebAuthnChallenge struct {
+	Type      string
+	UserID    int64
+	ExpiresAt time.Time
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	ssov1 "github.com/kechdarho/authproto/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+func (s *serverAPI) UnlockUser(ctx context.Context, req *ssov1.UnlockUserRequest) (*ssov1.UnlockUserResponse, error) {
+	if err := validateUnlockUser(req); err != nil {
+		return nil, err
+	}
+
+	result, err := s.auth.UnlockUser(ctx, req.GetToken(), req.GetLogin())
+	if err != nil {
+		return nil, adminError(err)
+	}
+	return &ssov1.UnlockUserResponse{Success: result}, nil
+}
+
+func (s *serverAPI) GetLockStatus(ctx context.Context, req *ssov1.GetLockStatusRequest) (*ssov1.GetLockStatusResponse, error) {
+	if err := validateGetLockStatus(req); err != nil {
+		return nil, err
+	}
+
+	lock, err := s.auth.LockStatus(ctx, req.GetToken(), req.GetLogin())
+	if err != nil {
+		return nil, adminError(err)
+	}
+	resp := &ssov1.GetLockStatusResponse{
+		Locked:         lock.Locked(time.Now()),
+		FailedAttempts: int64(lock.FailedAttempts),
+		Lockouts:       int64(lock.Lockouts),
+	}
+	if resp.Locked {
+		resp.LockedUntil = lock.LockedUntil.Unix()
+	}
+	return resp, nil
+}
+
+func adminError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "invalid token")
+	case errors.Is(err, auth.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case errors.Is(err, auth.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+func validateUnlockUser(req *ssov1.UnlockUserRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	if req.GetLogin() == "" {
+		return status.Error(codes.InvalidArgument, "login is required")
+	}
+	return nil
+}
+
+func validateGetLockStatus(req *ssov1.GetLockStatusRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	if req.GetLogin() == "" {
+		return status.Error(codes.InvalidArgument, "login is required")
+	}
+	return nil
+}
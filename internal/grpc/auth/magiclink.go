@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"AuthGrpc/internal/pkg/i18n"
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	ssov1 "github.com/kechdarho/authproto/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) RequestMagicLink(ctx context.Context, req *ssov1.RequestMagicLinkRequest) (*ssov1.RequestMagicLinkResponse, error) {
+	if err := validateRequestMagicLink(req); err != nil {
+		return nil, err
+	}
+
+	deviceSecret, err := s.auth.RequestMagicLink(ctx, req.GetLogin(), req.GetBindDevice())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &ssov1.RequestMagicLinkResponse{
+		Message:      i18n.T(ctx, "if the account exists, a sign-in link has been sent"),
+		DeviceSecret: deviceSecret,
+	}, nil
+}
+
+func (s *serverAPI) ExchangeMagicLink(ctx context.Context, req *ssov1.ExchangeMagicLinkRequest) (*ssov1.ExchangeMagicLinkResponse, error) {
+	if err := validateExchangeMagicLink(req); err != nil {
+		return nil, err
+	}
+
+	result, err := s.auth.ExchangeMagicLink(ctx, req.GetToken(), req.GetDeviceSecret(), clientInfo(ctx, req.GetDeviceName()))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidMagicLink) {
+			return nil, status.Error(codes.Unauthenticated, "invalid magic link")
+		}
+		if errors.Is(err, auth.ErrAccountLocked) {
+			return nil, status.Error(codes.PermissionDenied, "account locked")
+		}
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "email not verified")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.ExchangeMagicLinkResponse{
+		Token:        result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
+		MfaRequired:  result.MFAToken != "",
+		MfaToken:     result.MFAToken,
+	}, nil
+}
+
+func validateRequestMagicLink(req *ssov1.RequestMagicLinkRequest) error {
+	if req.GetLogin() == "" {
+		return status.Error(codes.InvalidArgument, "login is required")
+	}
+	return nil
+}
+
+func validateExchangeMagicLink(req *ssov1.ExchangeMagicLinkRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	ssov1 "github.com/kechdarho/authproto/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) EnrollTOTP(ctx context.Context, req *ssov1.EnrollTOTPRequest) (*ssov1.EnrollTOTPResponse, error) {
+	if err := validateEnrollTOTP(req); err != nil {
+		return nil, err
+	}
+
+	secret, uri, err := s.auth.EnrollTOTP(ctx, req.GetToken())
+	if err != nil {
+		return nil, mfaError(err)
+	}
+	return &ssov1.EnrollTOTPResponse{Secret: secret, OtpauthUri: uri}, nil
+}
+
+func (s *serverAPI) ConfirmTOTP(ctx context.Context, req *ssov1.ConfirmTOTPRequest) (*ssov1.ConfirmTOTPResponse, error) {
+	if err := validateConfirmTOTP(req); err != nil {
+		return nil, err
+	}
+
+	result, err := s.auth.ConfirmTOTP(ctx, req.GetToken(), req.GetCode())
+	if err != nil {
+		return nil, mfaError(err)
+	}
+	return &ssov1.ConfirmTOTPResponse{Success: result}, nil
+}
+
+func (s *serverAPI) VerifyMFA(ctx context.Context, req *ssov1.VerifyMFARequest) (*ssov1.VerifyMFAResponse, error) {
+	if err := validateVerifyMFA(req); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.auth.VerifyMFA(ctx, req.GetMfaToken(), req.GetCode())
+	if err != nil {
+		return nil, mfaError(err)
+	}
+	return &ssov1.VerifyMFAResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+func mfaError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "invalid token")
+	case errors.Is(err, auth.ErrInvalidMFAToken):
+		return status.Error(codes.Unauthenticated, "invalid mfa token")
+	case errors.Is(err, auth.ErrInvalidMFACode):
+		return status.Error(codes.Unauthenticated, "invalid code")
+	case errors.Is(err, auth.ErrAccountLocked):
+		return status.Error(codes.PermissionDenied, "account locked")
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		return status.Error(codes.ResourceExhausted, "too many failed login attempts")
+	case errors.Is(err, auth.ErrMFAAlreadyEnabled):
+		return status.Error(codes.AlreadyExists, "mfa already enabled")
+	case errors.Is(err, auth.ErrMFANotEnrolled):
+		return status.Error(codes.FailedPrecondition, "mfa not enrolled")
+	case errors.Is(err, auth.ErrMFADisabled):
+		return status.Error(codes.Unimplemented, "mfa is not configured")
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		return status.Error(codes.FailedPrecondition, "email not verified")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+func validateEnrollTOTP(req *ssov1.EnrollTOTPRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	return nil
+}
+
+func validateConfirmTOTP(req *ssov1.ConfirmTOTPRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	if req.GetCode() == "" {
+		return status.Error(codes.InvalidArgument, "code is required")
+	}
+	return nil
+}
+
+func validateVerifyMFA(req *ssov1.VerifyMFARequest) error {
+	if req.GetMfaToken() == "" {
+		return status.Error(codes.InvalidArgument, "mfa token is required")
+	}
+	if req.GetCode() == "" {
+		return status.Error(codes.InvalidArgument, "code is required")
+	}
+	return nil
+}
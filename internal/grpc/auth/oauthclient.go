@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	ssov1 "github.com/kechdarho/authproto/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) CreateOAuthClient(ctx context.Context, req *ssov1.CreateOAuthClientRequest) (*ssov1.CreateOAuthClientResponse, error) {
+	if err := validateCreateOAuthClient(req); err != nil {
+		return nil, err
+	}
+
+	client, secret, err := s.auth.CreateOAuthClient(ctx, req.GetToken(), models.OAuthClient{
+		Name:         req.GetName(),
+		RedirectURIs: req.GetRedirectUris(),
+		Scopes:       req.GetScopes(),
+		GrantTypes:   req.GetGrantTypes(),
+		AuthMethod:   req.GetTokenEndpointAuthMethod(),
+		JWKS:         req.GetJwks(),
+	})
+	if err != nil {
+		return nil, oauthClientError(err)
+	}
+	return &ssov1.CreateOAuthClientResponse{ClientId: client.ID, ClientSecret: secret}, nil
+}
+
+func (s *serverAPI) RotateOAuthClientSecret(ctx context.Context, req *ssov1.RotateOAuthClientSecretRequest) (*ssov1.RotateOAuthClientSecretResponse, error) {
+	if err := validateOAuthClientID(req.GetToken(), req.GetClientId()); err != nil {
+		return nil, err
+	}
+
+	secret, err := s.auth.RotateOAuthClientSecret(ctx, req.GetToken(), req.GetClientId())
+	if err != nil {
+		return nil, oauthClientError(err)
+	}
+	return &ssov1.RotateOAuthClientSecretResponse{ClientSecret: secret}, nil
+}
+
+func (s *serverAPI) DisableOAuthClient(ctx context.Context, req *ssov1.DisableOAuthClientRequest) (*ssov1.DisableOAuthClientResponse, error) {
+	if err := validateOAuthClientID(req.GetToken(), req.GetClientId()); err != nil {
+		return nil, err
+	}
+
+	result, err := s.auth.DisableOAuthClient(ctx, req.GetToken(), req.GetClientId())
+	if err != nil {
+		return nil, oauthClientError(err)
+	}
+	return &ssov1.DisableOAuthClientResponse{Success: result}, nil
+}
+
+func oauthClientError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidClient):
+		return status.Error(codes.NotFound, "client not found")
+	case errors.Is(err, auth.ErrInvalidClientMetadata):
+		return status.Error(codes.InvalidArgument, "invalid client metadata")
+	}
+	return adminError(err)
+}
+
+func validateCreateOAuthClient(req *ssov1.CreateOAuthClientRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	if req.GetName() == "" {
+		return status.Error(codes.InvalidArgument, "name is required")
+	}
+	return nil
+}
+
+func validateOAuthClientID(token string, clientID string) error {
+	if token == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	if clientID == "" {
+		return status.Error(codes.InvalidArgument, "client_id is required")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"AuthGrpc/internal/lib/password"
+	"AuthGrpc/internal/pkg/i18n"
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// passwordPolicyReason identifies password policy errors in their ErrorInfo
+// detail.
+const passwordPolicyReason = "PASSWORD_POLICY_VIOLATION"
+
+// passwordPolicyError turns a password policy error into an InvalidArgument
+// status carrying one BadRequest field violation per broken rule, with the
+// rule names in an ErrorInfo. field is the request field the password was
+// sent in. It returns nil for any other error.
+func passwordPolicyError(ctx context.Context, err error, field string) error {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return nil
+	}
+
+	badRequest := &errdetails.BadRequest{}
+	rules := make([]string, 0, len(policyErr.Violations))
+	for _, v := range policyErr.Violations {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: fmt.Sprintf(i18n.T(ctx, v.Message), v.Args...),
+		})
+		rules = append(rules, v.Rule)
+	}
+	info := &errdetails.ErrorInfo{
+		Reason:   passwordPolicyReason,
+		Metadata: map[string]string{"rules": strings.Join(rules, ",")},
+	}
+
+	st, detailsErr := status.New(codes.InvalidArgument, "password does not meet the policy").WithDetails(badRequest, info)
+	if detailsErr != nil {
+		return status.Error(codes.InvalidArgument, "password does not meet the policy")
+	}
+	return st.Err()
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	ssov1 "github.com/kechdarho/authproto/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) SendPhoneCode(ctx context.Context, req *ssov1.SendPhoneCodeRequest) (*ssov1.SendPhoneCodeResponse, error) {
+	if err := validateSendPhoneCode(req); err != nil {
+		return nil, err
+	}
+
+	result, err := s.auth.SendPhoneCode(ctx, req.GetToken())
+	if err != nil {
+		return nil, phoneError(err)
+	}
+	return &ssov1.SendPhoneCodeResponse{Success: result}, nil
+}
+
+func (s *serverAPI) VerifyPhone(ctx context.Context, req *ssov1.VerifyPhoneRequest) (*ssov1.VerifyPhoneResponse, error) {
+	if err := validateVerifyPhone(req); err != nil {
+		return nil, err
+	}
+
+	result, err := s.auth.VerifyPhone(ctx, req.GetToken(), req.GetCode())
+	if err != nil {
+		return nil, phoneError(err)
+	}
+	return &ssov1.VerifyPhoneResponse{Success: result}, nil
+}
+
+func phoneError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "invalid token")
+	case errors.Is(err, auth.ErrPhoneMissing):
+		return status.Error(codes.FailedPrecondition, "no phone number")
+	case errors.Is(err, auth.ErrPhoneAlreadyVerified):
+		return status.Error(codes.FailedPrecondition, "phone already verified")
+	case errors.Is(err, auth.ErrPhoneTaken):
+		return status.Error(codes.AlreadyExists, "phone already verified by another user")
+	case errors.Is(err, auth.ErrPhoneCodeTooSoon):
+		return status.Error(codes.ResourceExhausted, "code requested too soon")
+	case errors.Is(err, auth.ErrInvalidPhoneCode):
+		return status.Error(codes.InvalidArgument, "invalid code")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+func validateSendPhoneCode(req *ssov1.SendPhoneCodeRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	return nil
+}
+
+func validateVerifyPhone(req *ssov1.VerifyPhoneRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	if req.GetCode() == "" {
+		return status.Error(codes.InvalidArgument, "code is required")
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	ssov1 "github.com/kechdarho/authproto/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) GenerateRecoveryCodes(ctx context.Context, req *ssov1.GenerateRecoveryCodesRequest) (*ssov1.GenerateRecoveryCodesResponse, error) {
+	if err := validateGenerateRecoveryCodes(req); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := s.auth.GenerateRecoveryCodes(ctx, req.GetToken())
+	if err != nil {
+		return nil, recoveryError(err)
+	}
+	return &ssov1.GenerateRecoveryCodesResponse{Codes: recoveryCodes}, nil
+}
+
+func (s *serverAPI) CountRecoveryCodes(ctx context.Context, req *ssov1.CountRecoveryCodesRequest) (*ssov1.CountRecoveryCodesResponse, error) {
+	if err := validateCountRecoveryCodes(req); err != nil {
+		return nil, err
+	}
+
+	remaining, err := s.auth.RecoveryCodesRemaining(ctx, req.GetToken())
+	if err != nil {
+		return nil, recoveryError(err)
+	}
+	return &ssov1.CountRecoveryCodesResponse{Remaining: int64(remaining)}, nil
+}
+
+func (s *serverAPI) LoginWithRecoveryCode(ctx context.Context, req *ssov1.LoginWithRecoveryCodeRequest) (*ssov1.LoginWithRecoveryCodeResponse, error) {
+	if err := validateLoginWithRecoveryCode(req); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.auth.LoginWithRecoveryCode(ctx, req.GetMfaToken(), req.GetCode())
+	if err != nil {
+		return nil, recoveryError(err)
+	}
+	return &ssov1.LoginWithRecoveryCodeResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+func (s *serverAPI) ResetPasswordWithRecoveryCode(ctx context.Context, req *ssov1.ResetPasswordWithRecoveryCodeRequest) (*ssov1.ResetPasswordWithRecoveryCodeResponse, error) {
+	if err := validateResetPasswordWithRecoveryCode(req); err != nil {
+		return nil, err
+	}
+
+	result, err := s.auth.ResetPasswordWithRecoveryCode(ctx, req.GetLogin(), req.GetCode(), req.GetNewPassword(), clientInfo(ctx, ""))
+	if err != nil {
+		if policyErr := passwordPolicyError(ctx, err, "new_password"); policyErr != nil {
+			return nil, policyErr
+		}
+		return nil, recoveryError(err)
+	}
+	return &ssov1.ResetPasswordWithRecoveryCodeResponse{Success: result}, nil
+}
+
+func recoveryError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "invalid token")
+	case errors.Is(err, auth.ErrInvalidMFAToken):
+		return status.Error(codes.Unauthenticated, "invalid mfa token")
+	case errors.Is(err, auth.ErrInvalidRecoveryCode):
+		return status.Error(codes.Unauthenticated, "invalid recovery code")
+	case errors.Is(err, auth.ErrMFANotEnrolled):
+		return status.Error(codes.FailedPrecondition, "mfa not enrolled")
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		return status.Error(codes.FailedPrecondition, "email not verified")
+	case errors.Is(err, auth.ErrAccountLocked):
+		return status.Error(codes.PermissionDenied, "account locked")
+	case errors.Is(err, auth.ErrTooManyAttempts):
+		return status.Error(codes.ResourceExhausted, "too many failed login attempts")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+func validateGenerateRecoveryCodes(req *ssov1.GenerateRecoveryCodesRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	return nil
+}
+
+func validateCountRecoveryCodes(req *ssov1.CountRecoveryCodesRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	return nil
+}
+
+func validateLoginWithRecoveryCode(req *ssov1.LoginWithRecoveryCodeRequest) error {
+	if req.GetMfaToken() == "" {
+		return status.Error(codes.InvalidArgument, "mfa token is required")
+	}
+	if req.GetCode() == "" {
+		return status.Error(codes.InvalidArgument, "code is required")
+	}
+	return nil
+}
+
+func validateResetPasswordWithRecoveryCode(req *ssov1.ResetPasswordWithRecoveryCodeRequest) error {
+	if req.GetLogin() == "" {
+		return status.Error(codes.InvalidArgument, "login is required")
+	}
+	if req.GetCode() == "" {
+		return status.Error(codes.InvalidArgument, "code is required")
+	}
+	if req.GetNewPassword() == "" {
+		return status.Error(codes.InvalidArgument, "new password is required")
+	}
+	return nil
+}
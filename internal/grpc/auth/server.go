@@ -1,7 +1,14 @@
 package auth
 
 import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/jwk"
+	"AuthGrpc/internal/lib/encryption/jwt"
+	"AuthGrpc/internal/lib/webauthn"
+	"AuthGrpc/internal/pkg/i18n"
+	"AuthGrpc/internal/services/auth"
 	"context"
+	"errors"
 	ssov1 "github.com/kechdarho/authproto/gen/go/sso"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -13,7 +20,20 @@ type Authenticator interface {
 		ctx context.Context,
 		login string,
 		password string,
-	) (token string, err error)
+		client models.ClientInfo,
+	) (result models.LoginResult, err error)
+	Refresh(
+		ctx context.Context,
+		refreshToken string,
+		client models.ClientInfo,
+	) (tokens models.TokenPair, err error)
+	ValidateToken(
+		ctx context.Context,
+		token string,
+	) (accessToken jwt.AccessToken, err error)
+	PublicKeys(
+		ctx context.Context,
+	) (keys []jwk.Key, err error)
 	RegisterNewUser(
 		ctx context.Context,
 		email string,
@@ -30,11 +50,12 @@ type Authenticator interface {
 		login string,
 		oldPassword string,
 		newPassword string,
+		client models.ClientInfo,
 	) (success bool, err error)
 	ForgotPassword(
 		ctx context.Context,
 		login string,
-	) (string, error)
+	) (success bool, err error)
 	ResetPassword(
 		ctx context.Context,
 		token string,
@@ -44,6 +65,128 @@ type Authenticator interface {
 		jwtToken string,
 		email string,
 		phone string,
+		locale string,
+	) (success bool, err error)
+	VerifyEmail(
+		ctx context.Context,
+		token string,
+	) (success bool, err error)
+	ResendVerificationEmail(
+		ctx context.Context,
+		login string,
+	) (success bool, err error)
+	SendPhoneCode(
+		ctx context.Context,
+		jwtToken string,
+	) (success bool, err error)
+	VerifyPhone(
+		ctx context.Context,
+		jwtToken string,
+		code string,
+	) (success bool, err error)
+	ListSessions(
+		ctx context.Context,
+		jwtToken string,
+	) (sessions []models.Session, err error)
+	RevokeSession(
+		ctx context.Context,
+		jwtToken string,
+		sessionID string,
+	) (success bool, err error)
+	RevokeAllSessions(
+		ctx context.Context,
+		jwtToken string,
+		keepCurrent bool,
+	) (revoked int, err error)
+	EnrollTOTP(
+		ctx context.Context,
+		jwtToken string,
+	) (secret string, uri string, err error)
+	ConfirmTOTP(
+		ctx context.Context,
+		jwtToken string,
+		code string,
+	) (success bool, err error)
+	VerifyMFA(
+		ctx context.Context,
+		mfaToken string,
+		code string,
+	) (tokens models.TokenPair, err error)
+	GenerateRecoveryCodes(
+		ctx context.Context,
+		jwtToken string,
+	) (codes []string, err error)
+	RecoveryCodesRemaining(
+		ctx context.Context,
+		jwtToken string,
+	) (remaining int, err error)
+	LoginWithRecoveryCode(
+		ctx context.Context,
+		mfaToken string,
+		code string,
+	) (tokens models.TokenPair, err error)
+	ResetPasswordWithRecoveryCode(
+		ctx context.Context,
+		login string,
+		code string,
+		newPassword string,
+		client models.ClientInfo,
+	) (success bool, err error)
+	BeginWebAuthnRegistration(
+		ctx context.Context,
+		jwtToken string,
+	) (options webauthn.CreationOptions, err error)
+	FinishWebAuthnRegistration(
+		ctx context.Context,
+		jwtToken string,
+		name string,
+		clientDataJSON []byte,
+		attestationObject []byte,
+	) (success bool, err error)
+	BeginWebAuthnLogin(
+		ctx context.Context,
+		login string,
+	) (options webauthn.RequestOptions, err error)
+	FinishWebAuthnLogin(
+		ctx context.Context,
+		assertion webauthn.Assertion,
+		client models.ClientInfo,
+	) (result models.LoginResult, err error)
+	UnlockUser(
+		ctx context.Context,
+		jwtToken string,
+		login string,
+	) (success bool, err error)
+	LockStatus(
+		ctx context.Context,
+		jwtToken string,
+		login string,
+	) (lock models.AccountLock, err error)
+	RequestMagicLink(
+		ctx context.Context,
+		login string,
+		bindDevice bool,
+	) (deviceSecret string, err error)
+	ExchangeMagicLink(
+		ctx context.Context,
+		token string,
+		deviceSecret string,
+		client models.ClientInfo,
+	) (result models.LoginResult, err error)
+	CreateOAuthClient(
+		ctx context.Context,
+		jwtToken string,
+		client models.OAuthClient,
+	) (created models.OAuthClient, secret string, err error)
+	RotateOAuthClientSecret(
+		ctx context.Context,
+		jwtToken string,
+		clientID string,
+	) (secret string, err error)
+	DisableOAuthClient(
+		ctx context.Context,
+		jwtToken string,
+		clientID string,
 	) (success bool, err error)
 }
 
@@ -63,6 +206,9 @@ func (s *serverAPI) Register(ctx context.Context, req *ssov1.RegisterRequest) (*
 
 	result, err := s.auth.RegisterNewUser(ctx, req.GetEmail(), req.GetLogin(), req.GetPhone(), req.GetPassword())
 	if err != nil {
+		if policyErr := passwordPolicyError(ctx, err, "password"); policyErr != nil {
+			return nil, policyErr
+		}
 		// TODO: ...
 		return nil, status.Error(codes.Internal, "internal error")
 	}
@@ -77,16 +223,105 @@ func (s *serverAPI) Login(ctx context.Context, req *ssov1.LoginRequest) (*ssov1.
 		return nil, err
 	}
 
-	token, err := s.auth.Login(ctx, req.GetLogin(), req.GetPassword())
+	result, err := s.auth.Login(ctx, req.GetLogin(), req.GetPassword(), clientInfo(ctx, req.GetDeviceName()))
 	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		if errors.Is(err, auth.ErrAccountLocked) {
+			return nil, status.Error(codes.PermissionDenied, "account locked")
+		}
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "too many failed login attempts")
+		}
+		if errors.Is(err, auth.ErrEmailNotVerified) {
+			return nil, status.Error(codes.FailedPrecondition, "email not verified")
+		}
 		return nil, status.Error(codes.Internal, "internal error")
 	}
 
 	return &ssov1.LoginResponse{
-		Token: token,
+		Token:        result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
+		MfaRequired:  result.MFAToken != "",
+		MfaToken:     result.MFAToken,
 	}, nil
 }
 
+func (s *serverAPI) Refresh(ctx context.Context, req *ssov1.RefreshRequest) (*ssov1.RefreshResponse, error) {
+	if err := validateRefresh(req); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.auth.Refresh(ctx, req.GetRefreshToken(), clientInfo(ctx, ""))
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+			return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.RefreshResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	}, nil
+}
+
+// ValidateToken answers in the style of RFC 7662 token introspection: a token
+// that fails validation is reported as inactive rather than as an error.
+func (s *serverAPI) ValidateToken(ctx context.Context, req *ssov1.ValidateTokenRequest) (*ssov1.ValidateTokenResponse, error) {
+	if err := validateValidateToken(req); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.auth.ValidateToken(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return &ssov1.ValidateTokenResponse{Active: false}, nil
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &ssov1.ValidateTokenResponse{
+		Active:    true,
+		TokenType: "access_token",
+		Sub:       accessToken.Sub,
+		Iss:       accessToken.Iss,
+		Aud:       accessToken.Aud,
+		Uid:       accessToken.Uid,
+		Role:      accessToken.Role,
+		Jti:       accessToken.Jti,
+		Exp:       accessToken.Exp,
+		Iat:       accessToken.Iat.Unix(),
+		Nbf:       accessToken.Nbf.Unix(),
+		ClientId:  accessToken.ClientID,
+		Scope:     accessToken.Scope,
+	}, nil
+}
+
+func (s *serverAPI) GetPublicKeys(ctx context.Context, req *ssov1.GetPublicKeysRequest) (*ssov1.GetPublicKeysResponse, error) {
+	keys, err := s.auth.PublicKeys(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	resp := &ssov1.GetPublicKeysResponse{}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, &ssov1.JsonWebKey{
+			Kty: key.Kty,
+			Kid: key.Kid,
+			Use: key.Use,
+			Alg: key.Alg,
+			Crv: key.Crv,
+			X:   key.X,
+			Y:   key.Y,
+			N:   key.N,
+			E:   key.E,
+		})
+	}
+	return resp, nil
+}
+
 func (s *serverAPI) LogOut(ctx context.Context, req *ssov1.LogOutRequest) (*ssov1.LogOutResponse, error) {
 	if err := validateLogOut(req); err != nil {
 		return nil, err
@@ -94,6 +329,9 @@ func (s *serverAPI) LogOut(ctx context.Context, req *ssov1.LogOutRequest) (*ssov
 
 	result, err := s.auth.LogOut(ctx, req.GetToken())
 	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
 		return nil, status.Error(codes.Internal, "internal error")
 	}
 	return &ssov1.LogOutResponse{Success: result}, nil
@@ -103,8 +341,20 @@ func (s *serverAPI) ChangePassword(ctx context.Context, req *ssov1.ChangePasswor
 	if err := validateChangePassword(req); err != nil {
 		return nil, err
 	}
-	result, err := s.auth.ChangePassword(ctx, req.GetLogin(), req.GetOldPassword(), req.GetNewPassword())
+	result, err := s.auth.ChangePassword(ctx, req.GetLogin(), req.GetOldPassword(), req.GetNewPassword(), clientInfo(ctx, ""))
 	if err != nil {
+		if policyErr := passwordPolicyError(ctx, err, "new_password"); policyErr != nil {
+			return nil, policyErr
+		}
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		if errors.Is(err, auth.ErrAccountLocked) {
+			return nil, status.Error(codes.PermissionDenied, "account locked")
+		}
+		if errors.Is(err, auth.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "too many failed login attempts")
+		}
 		return nil, status.Error(codes.Internal, "internal error")
 	}
 	return &ssov1.ChangePasswordResponse{Success: result}, nil
@@ -115,11 +365,11 @@ func (s *serverAPI) ForgotPassword(ctx context.Context, req *ssov1.ForgotPasswor
 		return nil, err
 	}
 
-	resetToken, err := s.auth.ForgotPassword(ctx, req.GetLogin())
+	_, err := s.auth.ForgotPassword(ctx, req.GetLogin())
 	if err != nil {
 		return nil, status.Error(codes.Internal, "internal error")
 	}
-	return &ssov1.ForgotPasswordResponse{Message: resetToken}, nil
+	return &ssov1.ForgotPasswordResponse{Message: i18n.T(ctx, "if the account exists, a reset link has been sent")}, nil
 }
 
 func (s *serverAPI) ResetPassword(ctx context.Context, req *ssov1.ResetPasswordRequest) (*ssov1.ResetPasswordResponse, error) {
@@ -128,6 +378,12 @@ func (s *serverAPI) ResetPassword(ctx context.Context, req *ssov1.ResetPasswordR
 	}
 	result, err := s.auth.ResetPassword(ctx, req.GetToken(), req.GetNewPassword())
 	if err != nil {
+		if errors.Is(err, auth.ErrInvalidResetToken) {
+			return nil, status.Error(codes.InvalidArgument, "invalid reset token")
+		}
+		if policyErr := passwordPolicyError(ctx, err, "new_password"); policyErr != nil {
+			return nil, policyErr
+		}
 		return nil, status.Error(codes.Internal, "internal error")
 	}
 	return &ssov1.ResetPasswordResponse{Success: result}, nil
@@ -137,8 +393,14 @@ func (s *serverAPI) UpdateUser(ctx context.Context, req *ssov1.UpdateUserRequest
 	if err := validateUpdateUser(req); err != nil {
 		return nil, err
 	}
-	result, err := s.auth.UpdateUser(ctx, req.GetToken(), req.GetEmail(), req.GetPhone())
+	result, err := s.auth.UpdateUser(ctx, req.GetToken(), req.GetEmail(), req.GetPhone(), req.GetLocale())
 	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if errors.Is(err, auth.ErrUnsupportedLocale) {
+			return nil, status.Error(codes.InvalidArgument, "invalid locale")
+		}
 		return nil, status.Error(codes.Internal, "internal error")
 	}
 	return &ssov1.UpdateUserResponse{Success: result}, nil
@@ -155,14 +417,28 @@ func validateLogin(req *ssov1.LoginRequest) error {
 	return nil
 }
 
+func validateRefresh(req *ssov1.RefreshRequest) error {
+	if req.GetRefreshToken() == "" {
+		return status.Error(codes.InvalidArgument, "refresh token is required")
+	}
+
+	return nil
+}
+
+func validateValidateToken(req *ssov1.ValidateTokenRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	return nil
+}
+
 func validateUpdateUser(req *ssov1.UpdateUserRequest) error {
 	if req.GetToken() == "" {
 		return status.Error(codes.InvalidArgument, "token is required")
 	}
-	if req.GetEmail() == "" {
-		if req.GetPhone() == "" {
-			return status.Error(codes.InvalidArgument, "phone or email is required")
-		}
+	if req.GetEmail() == "" && req.GetPhone() == "" && req.GetLocale() == "" {
+		return status.Error(codes.InvalidArgument, "phone, email or locale is required")
 	}
 	return nil
 }
@@ -219,7 +495,7 @@ func validateResetPassword(req *ssov1.ResetPasswordRequest) error {
 		return status.Error(codes.InvalidArgument, "Token is required")
 	}
 	if req.GetNewPassword() == "" {
-		return status.Error(codes.InvalidArgument, "Token is required")
+		return status.Error(codes.InvalidArgument, "New password is required")
 	}
 	return nil
 }
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	ssov1 "github.com/kechdarho/authproto/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+)
+
+func (s *serverAPI) ListSessions(ctx context.Context, req *ssov1.ListSessionsRequest) (*ssov1.ListSessionsResponse, error) {
+	if err := validateListSessions(req); err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.auth.ListSessions(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	resp := &ssov1.ListSessionsResponse{}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, &ssov1.Session{
+			Id:         session.ID,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			Ip:         session.IP,
+			CreatedAt:  session.CreatedAt.Unix(),
+			LastSeenAt: session.LastSeenAt.Unix(),
+			Current:    session.Current,
+		})
+	}
+	return resp, nil
+}
+
+func (s *serverAPI) RevokeSession(ctx context.Context, req *ssov1.RevokeSessionRequest) (*ssov1.RevokeSessionResponse, error) {
+	if err := validateRevokeSession(req); err != nil {
+		return nil, err
+	}
+
+	result, err := s.auth.RevokeSession(ctx, req.GetToken(), req.GetSessionId())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		if errors.Is(err, auth.ErrSessionNotFound) {
+			return nil, status.Error(codes.NotFound, "session not found")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &ssov1.RevokeSessionResponse{Success: result}, nil
+}
+
+func (s *serverAPI) RevokeAllSessions(ctx context.Context, req *ssov1.RevokeAllSessionsRequest) (*ssov1.RevokeAllSessionsResponse, error) {
+	if err := validateRevokeAllSessions(req); err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.auth.RevokeAllSessions(ctx, req.GetToken(), req.GetKeepCurrent())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &ssov1.RevokeAllSessionsResponse{Revoked: int64(revoked)}, nil
+}
+
+func validateListSessions(req *ssov1.ListSessionsRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	return nil
+}
+
+func validateRevokeSession(req *ssov1.RevokeSessionRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	if req.GetSessionId() == "" {
+		return status.Error(codes.InvalidArgument, "session id is required")
+	}
+	return nil
+}
+
+func validateRevokeAllSessions(req *ssov1.RevokeAllSessionsRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	return nil
+}
+
+// clientInfo collects what the request tells about the calling device: the
+// user agent from the metadata and the address of the peer.
+func clientInfo(ctx context.Context, deviceName string) models.ClientInfo {
+	client := models.ClientInfo{DeviceName: deviceName}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			client.UserAgent = ua[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		client.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(client.IP); err == nil {
+			client.IP = host
+		}
+	}
+	return client
+}
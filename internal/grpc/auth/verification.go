@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"AuthGrpc/internal/pkg/i18n"
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	ssov1 "github.com/kechdarho/authproto/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *serverAPI) VerifyEmail(ctx context.Context, req *ssov1.VerifyEmailRequest) (*ssov1.VerifyEmailResponse, error) {
+	if err := validateVerifyEmail(req); err != nil {
+		return nil, err
+	}
+
+	result, err := s.auth.VerifyEmail(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidVerificationToken) {
+			return nil, status.Error(codes.InvalidArgument, "invalid verification token")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &ssov1.VerifyEmailResponse{Success: result}, nil
+}
+
+func (s *serverAPI) ResendVerificationEmail(ctx context.Context, req *ssov1.ResendVerificationEmailRequest) (*ssov1.ResendVerificationEmailResponse, error) {
+	if err := validateResendVerificationEmail(req); err != nil {
+		return nil, err
+	}
+
+	_, err := s.auth.ResendVerificationEmail(ctx, req.GetLogin())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &ssov1.ResendVerificationEmailResponse{Message: i18n.T(ctx, "if the account exists and is not verified, a verification link has been sent")}, nil
+}
+
+func validateVerifyEmail(req *ssov1.VerifyEmailRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	return nil
+}
+
+func validateResendVerificationEmail(req *ssov1.ResendVerificationEmailRequest) error {
+	if req.GetLogin() == "" {
+		return status.Error(codes.InvalidArgument, "login is required")
+	}
+	return nil
+}
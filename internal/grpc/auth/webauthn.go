@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"AuthGrpc/internal/lib/webauthn"
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"encoding/json"
+	"errors"
+	ssov1 "github.com/kechdarho/authproto/gen/go/sso"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BeginWebAuthnRegistration returns the PublicKeyCredentialCreationOptions
+// as JSON, binary fields base64url-encoded.
+func (s *serverAPI) BeginWebAuthnRegistration(ctx context.Context, req *ssov1.BeginWebAuthnRegistrationRequest) (*ssov1.BeginWebAuthnRegistrationResponse, error) {
+	if err := validateBeginWebAuthnRegistration(req); err != nil {
+		return nil, err
+	}
+
+	options, err := s.auth.BeginWebAuthnRegistration(ctx, req.GetToken())
+	if err != nil {
+		return nil, webauthnError(err)
+	}
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &ssov1.BeginWebAuthnRegistrationResponse{Options: string(encoded)}, nil
+}
+
+func (s *serverAPI) FinishWebAuthnRegistration(ctx context.Context, req *ssov1.FinishWebAuthnRegistrationRequest) (*ssov1.FinishWebAuthnRegistrationResponse, error) {
+	if err := validateFinishWebAuthnRegistration(req); err != nil {
+		return nil, err
+	}
+
+	result, err := s.auth.FinishWebAuthnRegistration(ctx, req.GetToken(), req.GetName(), req.GetClientDataJson(), req.GetAttestationObject())
+	if err != nil {
+		return nil, webauthnError(err)
+	}
+	return &ssov1.FinishWebAuthnRegistrationResponse{Success: result}, nil
+}
+
+// BeginWebAuthnLogin returns the PublicKeyCredentialRequestOptions as JSON,
+// binary fields base64url-encoded.
+func (s *serverAPI) BeginWebAuthnLogin(ctx context.Context, req *ssov1.BeginWebAuthnLoginRequest) (*ssov1.BeginWebAuthnLoginResponse, error) {
+	options, err := s.auth.BeginWebAuthnLogin(ctx, req.GetLogin())
+	if err != nil {
+		return nil, webauthnError(err)
+	}
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &ssov1.BeginWebAuthnLoginResponse{Options: string(encoded)}, nil
+}
+
+func (s *serverAPI) FinishWebAuthnLogin(ctx context.Context, req *ssov1.FinishWebAuthnLoginRequest) (*ssov1.FinishWebAuthnLoginResponse, error) {
+	if err := validateFinishWebAuthnLogin(req); err != nil {
+		return nil, err
+	}
+
+	assertion := webauthn.Assertion{
+		CredentialID:      req.GetCredentialId(),
+		ClientDataJSON:    req.GetClientDataJson(),
+		AuthenticatorData: req.GetAuthenticatorData(),
+		Signature:         req.GetSignature(),
+		UserHandle:        req.GetUserHandle(),
+	}
+	result, err := s.auth.FinishWebAuthnLogin(ctx, assertion, clientInfo(ctx, req.GetDeviceName()))
+	if err != nil {
+		return nil, webauthnError(err)
+	}
+	return &ssov1.FinishWebAuthnLoginResponse{
+		Token:        result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
+		MfaRequired:  result.MFAToken != "",
+		MfaToken:     result.MFAToken,
+	}, nil
+}
+
+func webauthnError(err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		return status.Error(codes.Unauthenticated, "invalid token")
+	case errors.Is(err, auth.ErrInvalidWebAuthnChallenge):
+		return status.Error(codes.Unauthenticated, "invalid challenge")
+	case errors.Is(err, auth.ErrInvalidWebAuthnCredential):
+		return status.Error(codes.Unauthenticated, "invalid credential")
+	case errors.Is(err, auth.ErrWebAuthnCredentialExists):
+		return status.Error(codes.AlreadyExists, "credential already registered")
+	case errors.Is(err, auth.ErrWebAuthnDisabled):
+		return status.Error(codes.Unimplemented, "webauthn is not configured")
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		return status.Error(codes.FailedPrecondition, "email not verified")
+	case errors.Is(err, auth.ErrAccountLocked):
+		return status.Error(codes.PermissionDenied, "account locked")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+func validateBeginWebAuthnRegistration(req *ssov1.BeginWebAuthnRegistrationRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	return nil
+}
+
+func validateFinishWebAuthnRegistration(req *ssov1.FinishWebAuthnRegistrationRequest) error {
+	if req.GetToken() == "" {
+		return status.Error(codes.InvalidArgument, "token is required")
+	}
+	if len(req.GetClientDataJson()) == 0 {
+		return status.Error(codes.InvalidArgument, "client data is required")
+	}
+	if len(req.GetAttestationObject()) == 0 {
+		return status.Error(codes.InvalidArgument, "attestation object is required")
+	}
+	return nil
+}
+
+func validateFinishWebAuthnLogin(req *ssov1.FinishWebAuthnLoginRequest) error {
+	if len(req.GetCredentialId()) == 0 {
+		return status.Error(codes.InvalidArgument, "credential id is required")
+	}
+	if len(req.GetClientDataJson()) == 0 {
+		return status.Error(codes.InvalidArgument, "client data is required")
+	}
+	if len(req.GetAuthenticatorData()) == 0 {
+		return status.Error(codes.InvalidArgument, "authenticator data is required")
+	}
+	if len(req.GetSignature()) == 0 {
+		return status.Error(codes.InvalidArgument, "signature is required")
+	}
+	return nil
+}
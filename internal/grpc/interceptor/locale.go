@@ -0,0 +1,45 @@
+package interceptor
+
+import (
+	"AuthGrpc/internal/pkg/i18n"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// acceptLanguage is the metadata key clients state their languages in, with
+// the syntax of the HTTP Accept-Language header.
+const acceptLanguage = "accept-language"
+
+// Locale stores the locale a request asks for in its context and translates
+// the message of the status error the handler returns.
+func Locale(catalog *i18n.Catalog) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var requested string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			for _, header := range md.Get(acceptLanguage) {
+				for _, tag := range i18n.ParseAcceptLanguage(header) {
+					if catalog.Supports(tag) {
+						requested = tag
+						break
+					}
+				}
+				if requested != "" {
+					break
+				}
+			}
+		}
+		ctx = i18n.NewContext(ctx, catalog, requested)
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			if st, ok := status.FromError(err); ok {
+				p := st.Proto()
+				p.Message = i18n.T(ctx, p.Message)
+				err = status.FromProto(p).Err()
+			}
+		}
+		return resp, err
+	}
+}
@@ -0,0 +1,50 @@
+package interceptor
+
+import (
+	"AuthGrpc/internal/pkg/i18n"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestLocale(t *testing.T) {
+	locale := Locale(i18n.MustLoad("en"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/auth.Auth/ResetPassword"}
+	tests := []struct {
+		name       string
+		header     []string
+		wantLocale string
+		wantMsg    string
+	}{
+		{"no header", nil, "", "invalid reset token"},
+		{"supported", []string{"ru-RU"}, "ru-RU", "недействительный токен сброса пароля"},
+		{"first supported by preference", []string{"fr, en;q=0.5, ru;q=0.8"}, "ru", "недействительный токен сброса пароля"},
+		{"later header", []string{"fr", "ru"}, "ru", "недействительный токен сброса пароля"},
+		{"unsupported", []string{"fr, de"}, "", "invalid reset token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.header != nil {
+				ctx = metadata.NewIncomingContext(ctx, metadata.MD{acceptLanguage: tt.header})
+			}
+			var gotLocale string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				gotLocale = i18n.LocaleFromContext(ctx)
+				return nil, status.Error(codes.InvalidArgument, "invalid reset token")
+			}
+
+			_, err := locale(ctx, nil, info, handler)
+			if gotLocale != tt.wantLocale {
+				t.Fatalf("locale = %q, want %q", gotLocale, tt.wantLocale)
+			}
+			st, _ := status.FromError(err)
+			if st.Code() != codes.InvalidArgument || st.Message() != tt.wantMsg {
+				t.Fatalf("error = %v, want InvalidArgument %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package interceptor
+
+import (
+	"AuthGrpc/internal/cache"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retryAfter is the header telling a limited client how many seconds to wait.
+const retryAfter = "retry-after"
+
+// Limit is a token bucket: a client may make Burst calls at once and gets
+// Rate more per second after that. ByIP and ByLogin say what clients are told
+// apart by; with both, each peer IP and each login has its own bucket and a
+// call has to pass both.
+type Limit struct {
+	Rate    float64
+	Burst   int
+	ByIP    bool
+	ByLogin bool
+}
+
+type bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+type rateLimiter struct {
+	cache  cache.Cacher
+	limits map[string]Limit
+	// mu makes taking a token atomic; the Cacher interface has no
+	// compare-and-set, so buckets are only exact within one process.
+	mu sync.Mutex
+}
+
+// RateLimit refuses calls over their method's limit with ResourceExhausted.
+// limits is keyed by the full method name, e.g. "/auth.Auth/Login", or by the
+// bare method name; methods without a limit are not limited.
+func RateLimit(c cache.Cacher, limits map[string]Limit) grpc.UnaryServerInterceptor {
+	l := &rateLimiter{cache: c, limits: limits}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		limit, ok := l.limit(info.FullMethod)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		prefix := "rateLimit:" + info.FullMethod + ":"
+		var keys []string
+		if limit.ByIP {
+			if ip := peerIP(ctx); ip != "" {
+				keys = append(keys, prefix+"ip:"+ip)
+			}
+		}
+		if limit.ByLogin {
+			if r, ok := req.(interface{ GetLogin() string }); ok && r.GetLogin() != "" {
+				keys = append(keys, prefix+"login:"+strings.ToLower(r.GetLogin()))
+			}
+		}
+		if wait, ok := l.take(ctx, keys, limit); !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			_ = grpc.SetHeader(ctx, metadata.Pairs(retryAfter, strconv.Itoa(seconds)))
+			return nil, status.Error(codes.ResourceExhausted, "too many requests")
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (l *rateLimiter) limit(fullMethod string) (Limit, bool) {
+	if limit, ok := l.limits[fullMethod]; ok {
+		return limit, true
+	}
+	limit, ok := l.limits[fullMethod[strings.LastIndex(fullMethod, "/")+1:]]
+	return limit, ok
+}
+
+// take removes a token from every bucket in keys, or from none of them and
+// reports how long until all of them have one, so a call refused by one
+// bucket does not use up the others.
+func (l *rateLimiter) take(ctx context.Context, keys []string, limit Limit) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	buckets := make([]bucket, len(keys))
+	var wait time.Duration
+	limited := false
+	for i, key := range keys {
+		b := bucket{Tokens: float64(limit.Burst), Updated: now}
+		if value, ok := l.cache.Get(ctx, key); ok {
+			if stored, ok := value.(bucket); ok {
+				b = stored
+				b.Tokens = math.Min(float64(limit.Burst), b.Tokens+now.Sub(b.Updated).Seconds()*limit.Rate)
+				b.Updated = now
+			}
+		}
+		if b.Tokens < 1 {
+			limited = true
+			wait = max(wait, time.Duration((1-b.Tokens)/limit.Rate*float64(time.Second)))
+		}
+		buckets[i] = b
+	}
+	if limited {
+		return wait, false
+	}
+
+	for i, b := range buckets {
+		b.Tokens--
+		// An idle bucket is full again after this long and can be dropped.
+		ttl := time.Duration((float64(limit.Burst) - b.Tokens) / limit.Rate * float64(time.Second))
+		_ = l.cache.Set(ctx, keys[i], b, ttl+time.Second)
+	}
+	return 0, true
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	ip := p.Addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return ip
+}
@@ -0,0 +1,58 @@
+package interceptor
+
+import (
+	"AuthGrpc/internal/cache/local"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+	"time"
+)
+
+type loginRequest struct {
+	login string
+}
+
+func (r loginRequest) GetLogin() string {
+	return r.login
+}
+
+func TestRateLimitTakesTokensOnlyWhenEveryBucketAllows(t *testing.T) {
+	c, err := local.InitCache(context.Background(), time.Minute, time.Minute)
+	if err != nil {
+		t.Fatalf("InitCache: %v", err)
+	}
+	// Buckets do not refill within the test.
+	limit := RateLimit(c, map[string]Limit{"Login": {Rate: 0.001, Burst: 2, ByIP: true, ByLogin: true}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/auth.Auth/Login"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	call := func(ip string, login string) error {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+		_, err := limit(ctx, loginRequest{login: login}, info, handler)
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := call("203.0.113.7", "alice"); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	// alice's bucket is empty, so the call is refused without using up the
+	// bucket of the new address.
+	if err := call("198.51.100.1", "alice"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("call over the login limit = %v, want ResourceExhausted", err)
+	}
+	for _, login := range []string{"bob", "carol"} {
+		if err := call("198.51.100.1", login); err != nil {
+			t.Fatalf("call as %s: %v", login, err)
+		}
+	}
+	if err := call("198.51.100.1", "dave"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("call over the IP limit = %v, want ResourceExhausted", err)
+	}
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type DeviceProvider interface {
+	AuthorizeDevice(ctx context.Context, creds models.ClientCredentials, scope string) (models.DeviceCode, error)
+	DevicePrompt(ctx context.Context, jwtToken string, userCode string) (models.ConsentPrompt, error)
+	CompleteDeviceAuthorization(ctx context.Context, jwtToken string, userCode string, approved bool) error
+	ExchangeDeviceCode(ctx context.Context, creds models.ClientCredentials, deviceCode string, client models.ClientInfo) (models.OAuthTokens, error)
+}
+
+type deviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+type deviceDecisionResponse struct {
+	Approved bool `json:"approved"`
+}
+
+// deviceAuthorization is the device authorization endpoint of RFC 8628
+// section 3.1.
+func (h *handler) deviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, &auth.OAuthError{Code: auth.OAuthInvalidRequest, Description: "invalid form"})
+		return
+	}
+	creds, err := clientCredentials(r)
+	if err != nil {
+		writeTokenError(w, &auth.OAuthError{Code: auth.OAuthInvalidRequest, Description: err.Error()})
+		return
+	}
+
+	code, err := h.device.AuthorizeDevice(r.Context(), creds, r.PostForm.Get("scope"))
+	if err != nil {
+		var oauthErr *auth.OAuthError
+		if !errors.As(err, &oauthErr) {
+			h.log.Error("failed to authorize device", slog.String("error", err.Error()))
+			oauthErr = &auth.OAuthError{Code: auth.OAuthServerError}
+		}
+		writeTokenError(w, oauthErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, deviceCodeResponse{
+		DeviceCode:              code.DeviceCode,
+		UserCode:                code.UserCode,
+		VerificationURI:         code.VerificationURI,
+		VerificationURIComplete: code.VerificationURIComplete,
+		ExpiresIn:               int64(code.ExpiresIn / time.Second),
+		Interval:                int64(code.Interval / time.Second),
+	})
+}
+
+// devicePrompt lets the verification page, once the user signed in and
+// entered the code, ask what the device is asking for.
+func (h *handler) devicePrompt(w http.ResponseWriter, r *http.Request) {
+	jwtToken, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	prompt, err := h.device.DevicePrompt(r.Context(), jwtToken, r.URL.Query().Get("user_code"))
+	if err != nil {
+		h.deviceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, consentPromptResponse{
+		ClientID:        prompt.ClientID,
+		ClientName:      prompt.ClientName,
+		Scopes:          prompt.Scopes,
+		ConsentRequired: prompt.ConsentRequired,
+	})
+}
+
+// deviceDecision takes the signed-in user's decision on the device.
+func (h *handler) deviceDecision(w http.ResponseWriter, r *http.Request) {
+	jwtToken, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+
+	approved := r.PostForm.Get("approve") == "true"
+	if err := h.device.CompleteDeviceAuthorization(r.Context(), jwtToken, r.PostForm.Get("user_code"), approved); err != nil {
+		h.deviceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deviceDecisionResponse{Approved: approved})
+}
+
+func (h *handler) deviceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid_token"})
+	case errors.Is(err, auth.ErrInvalidUserCode), errors.Is(err, auth.ErrInvalidClient):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: auth.OAuthInvalidRequest, ErrorDescription: "unknown or expired user_code"})
+	case errors.Is(err, auth.ErrTooManyUserCodeAttempts):
+		writeJSON(w, http.StatusTooManyRequests, errorResponse{Error: auth.OAuthInvalidRequest, ErrorDescription: "too many wrong user codes"})
+	default:
+		h.log.Error("failed to complete device authorization", slog.String("error", err.Error()))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: auth.OAuthServerError})
+	}
+}
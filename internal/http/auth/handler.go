@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"AuthGrpc/internal/lib/encryption/jwk"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type KeyProvider interface {
+	PublicKeys(ctx context.Context) ([]jwk.Key, error)
+}
+
+// Authenticator is what the HTTP endpoints need from the auth service.
+type Authenticator interface {
+	KeyProvider
+	OAuthProvider
+	DeviceProvider
+	OpenIDProvider
+}
+
+type handler struct {
+	log    *slog.Logger
+	keys   KeyProvider
+	oauth  OAuthProvider
+	device DeviceProvider
+	openID OpenIDProvider
+}
+
+func Register(mux *http.ServeMux, log *slog.Logger, authenticator Authenticator) {
+	h := &handler{log: log, keys: authenticator, oauth: authenticator, device: authenticator, openID: authenticator}
+	mux.HandleFunc("GET /.well-known/jwks.json", h.jwks)
+	mux.HandleFunc("GET /authorize", h.authorize)
+	mux.HandleFunc("GET /authorize/consent", h.consentPrompt)
+	mux.HandleFunc("POST /authorize/consent", h.consent)
+	mux.HandleFunc("POST /token", h.token)
+	mux.HandleFunc("POST /device_authorization", h.deviceAuthorization)
+	mux.HandleFunc("GET /device/verify", h.devicePrompt)
+	mux.HandleFunc("POST /device/verify", h.deviceDecision)
+	mux.HandleFunc("GET /.well-known/openid-configuration", h.discovery)
+	mux.HandleFunc("GET /userinfo", h.userInfo)
+	mux.HandleFunc("POST /userinfo", h.userInfo)
+}
+
+func (h *handler) jwks(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.keys.PublicKeys(r.Context())
+	if err != nil {
+		h.log.Error("failed to load public keys", slog.String("error", err.Error()))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, jwk.Set{Keys: keys})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
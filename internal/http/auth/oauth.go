@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxFormBytes bounds the body of form posts.
+const maxFormBytes = 64 << 10
+
+type OAuthProvider interface {
+	Authorize(ctx context.Context, req models.AuthorizationRequest) (loginURL string, err error)
+	AuthorizationErrorRedirect(req models.AuthorizationRequest, oauthErr *auth.OAuthError) string
+	AuthorizationPrompt(ctx context.Context, jwtToken string, requestID string) (models.ConsentPrompt, error)
+	CompleteAuthorization(ctx context.Context, jwtToken string, requestID string, approved bool) (redirectURL string, err error)
+	ExchangeAuthorizationCode(ctx context.Context, creds models.ClientCredentials, code string, redirectURI string, codeVerifier string, client models.ClientInfo) (models.OAuthTokens, error)
+	RefreshOAuthToken(ctx context.Context, creds models.ClientCredentials, refreshToken string, client models.ClientInfo) (models.OAuthTokens, error)
+	ClientCredentialsToken(ctx context.Context, creds models.ClientCredentials, scope string) (models.OAuthTokens, error)
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type consentPromptResponse struct {
+	ClientID        string   `json:"client_id"`
+	ClientName      string   `json:"client_name"`
+	Scopes          []string `json:"scopes"`
+	ConsentRequired bool     `json:"consent_required"`
+}
+
+type consentResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// authorize is the authorization endpoint of RFC 6749 section 3.1. It only
+// checks the request and sends the user on to the login page.
+func (h *handler) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := models.AuthorizationRequest{
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		ResponseType:        query.Get("response_type"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+		Nonce:               query.Get("nonce"),
+	}
+
+	loginURL, err := h.oauth.Authorize(r.Context(), req)
+	if err != nil {
+		var oauthErr *auth.OAuthError
+		switch {
+		case errors.Is(err, auth.ErrInvalidClient):
+			http.Error(w, "unknown client_id", http.StatusBadRequest)
+		case errors.Is(err, auth.ErrInvalidRedirectURI):
+			http.Error(w, "redirect_uri is not registered for this client", http.StatusBadRequest)
+		case errors.As(err, &oauthErr):
+			http.Redirect(w, r, h.oauth.AuthorizationErrorRedirect(req, oauthErr), http.StatusFound)
+		default:
+			// The redirect URI may not have been checked yet, so the error
+			// cannot be sent there.
+			h.log.Error("failed to authorize", slog.String("error", err.Error()))
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+		return
+	}
+	http.Redirect(w, r, loginURL, http.StatusFound)
+}
+
+// consentPrompt lets the login page, once the user signed in, ask what the
+// pending request is for.
+func (h *handler) consentPrompt(w http.ResponseWriter, r *http.Request) {
+	jwtToken, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	prompt, err := h.oauth.AuthorizationPrompt(r.Context(), jwtToken, r.URL.Query().Get("request_id"))
+	if err != nil {
+		h.consentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, consentPromptResponse{
+		ClientID:        prompt.ClientID,
+		ClientName:      prompt.ClientName,
+		Scopes:          prompt.Scopes,
+		ConsentRequired: prompt.ConsentRequired,
+	})
+}
+
+// consent takes the signed-in user's decision and tells the login page
+// where to send them.
+func (h *handler) consent(w http.ResponseWriter, r *http.Request) {
+	jwtToken, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+
+	approved := r.PostForm.Get("approve") == "true"
+	redirectURL, err := h.oauth.CompleteAuthorization(r.Context(), jwtToken, r.PostForm.Get("request_id"), approved)
+	if err != nil {
+		h.consentError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, consentResponse{RedirectTo: redirectURL})
+}
+
+func (h *handler) consentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid_token"})
+	case errors.Is(err, auth.ErrInvalidAuthorizationRequest), errors.Is(err, auth.ErrInvalidClient):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: auth.OAuthInvalidRequest, ErrorDescription: "unknown or expired request_id"})
+	default:
+		h.log.Error("failed to complete authorization", slog.String("error", err.Error()))
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: auth.OAuthServerError})
+	}
+}
+
+// token is the token endpoint of RFC 6749 section 3.2.
+func (h *handler) token(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, &auth.OAuthError{Code: auth.OAuthInvalidRequest, Description: "invalid form"})
+		return
+	}
+	form := r.PostForm
+	creds, err := clientCredentials(r)
+	if err != nil {
+		writeTokenError(w, &auth.OAuthError{Code: auth.OAuthInvalidRequest, Description: err.Error()})
+		return
+	}
+
+	var tokens models.OAuthTokens
+	switch form.Get("grant_type") {
+	case auth.GrantAuthorizationCode:
+		tokens, err = h.oauth.ExchangeAuthorizationCode(r.Context(), creds, form.Get("code"), form.Get("redirect_uri"), form.Get("code_verifier"), requestClient(r))
+	case auth.GrantRefreshToken:
+		tokens, err = h.oauth.RefreshOAuthToken(r.Context(), creds, form.Get("refresh_token"), requestClient(r))
+	case auth.GrantClientCredentials:
+		tokens, err = h.oauth.ClientCredentialsToken(r.Context(), creds, form.Get("scope"))
+	case auth.GrantDeviceCode:
+		tokens, err = h.device.ExchangeDeviceCode(r.Context(), creds, form.Get("device_code"), requestClient(r))
+	case "":
+		err = &auth.OAuthError{Code: auth.OAuthInvalidRequest, Description: "grant_type is required"}
+	default:
+		err = &auth.OAuthError{Code: auth.OAuthUnsupportedGrantType}
+	}
+	if err != nil {
+		var oauthErr *auth.OAuthError
+		if !errors.As(err, &oauthErr) {
+			h.log.Error("failed to issue tokens", slog.String("error", err.Error()))
+			oauthErr = &auth.OAuthError{Code: auth.OAuthServerError}
+		}
+		writeTokenError(w, oauthErr)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(tokens.ExpiresIn / time.Second),
+		RefreshToken: tokens.RefreshToken,
+		Scope:        tokens.Scope,
+		IDToken:      tokens.IDToken,
+	})
+}
+
+func writeTokenError(w http.ResponseWriter, oauthErr *auth.OAuthError) {
+	code := http.StatusBadRequest
+	switch oauthErr.Code {
+	case auth.OAuthInvalidClient:
+		code = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+	case auth.OAuthServerError:
+		code = http.StatusInternalServerError
+	}
+	writeJSON(w, code, errorResponse{Error: oauthErr.Code, ErrorDescription: oauthErr.Description})
+}
+
+// clientCredentials reads how the client authenticated: with a secret in
+// the Basic header or the form, with a signed assertion, or not at all.
+// RFC 6749 section 2.3 allows only one of them per request.
+func clientCredentials(r *http.Request) (models.ClientCredentials, error) {
+	form := r.PostForm
+	creds := models.ClientCredentials{
+		ID:     form.Get("client_id"),
+		Method: auth.AuthMethodNone,
+	}
+	methods := 0
+	if username, password, ok := r.BasicAuth(); ok {
+		// RFC 6749 section 2.3.1 form-encodes both before encoding the header.
+		id, err := url.QueryUnescape(username)
+		if err != nil {
+			return models.ClientCredentials{}, errors.New("invalid basic credentials")
+		}
+		secret, err := url.QueryUnescape(password)
+		if err != nil {
+			return models.ClientCredentials{}, errors.New("invalid basic credentials")
+		}
+		if creds.ID != "" && creds.ID != id {
+			return models.ClientCredentials{}, errors.New("client_id does not match the basic credentials")
+		}
+		creds.ID = id
+		creds.Secret = secret
+		creds.Method = auth.AuthMethodClientSecretBasic
+		methods++
+	}
+	if form.Has("client_secret") {
+		creds.Secret = form.Get("client_secret")
+		creds.Method = auth.AuthMethodClientSecretPost
+		methods++
+	}
+	if form.Has("client_assertion") {
+		creds.Assertion = form.Get("client_assertion")
+		creds.AssertionType = form.Get("client_assertion_type")
+		creds.Method = auth.AuthMethodPrivateKeyJWT
+		methods++
+	}
+	if methods > 1 {
+		return models.ClientCredentials{}, errors.New("more than one client authentication method used")
+	}
+	return creds, nil
+}
+
+// bearerToken reads the access token of the signed-in user, answering 401
+// when there is none.
+func bearerToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	scheme, jwtToken, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || jwtToken == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid_token"})
+		return "", false
+	}
+	return jwtToken, true
+}
+
+// requestClient collects what the request tells about the calling device.
+func requestClient(r *http.Request) models.ClientInfo {
+	client := models.ClientInfo{UserAgent: r.UserAgent(), IP: r.RemoteAddr}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		client.IP = host
+	}
+	return client
+}
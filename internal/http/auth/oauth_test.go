@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeOAuth answers Authorize with err.
+type fakeOAuth struct {
+	OAuthProvider
+	err error
+}
+
+func (f fakeOAuth) Authorize(ctx context.Context, req models.AuthorizationRequest) (string, error) {
+	return "", f.err
+}
+
+func (f fakeOAuth) AuthorizationErrorRedirect(req models.AuthorizationRequest, oauthErr *auth.OAuthError) string {
+	return req.RedirectURI + "?error=" + oauthErr.Code
+}
+
+func TestAuthorizeRedirectsOnlyOAuthErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		code     int
+		redirect bool
+	}{
+		{"unknown client", fmt.Errorf("auth.Authorize: %w", auth.ErrInvalidClient), http.StatusBadRequest, false},
+		{"unregistered redirect uri", fmt.Errorf("auth.Authorize: %w", auth.ErrInvalidRedirectURI), http.StatusBadRequest, false},
+		{"internal error", errors.New("storage unavailable"), http.StatusInternalServerError, false},
+		{"oauth error", &auth.OAuthError{Code: auth.OAuthInvalidScope}, http.StatusFound, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler{log: slog.New(slog.NewTextHandler(io.Discard, nil)), oauth: fakeOAuth{err: tt.err}}
+			rec := httptest.NewRecorder()
+			h.authorize(rec, httptest.NewRequest(http.MethodGet, "/authorize?client_id=c&redirect_uri=https://evil.example/cb", nil))
+
+			if rec.Code != tt.code {
+				t.Fatalf("status %d, want %d", rec.Code, tt.code)
+			}
+			if location := rec.Header().Get("Location"); (location != "") != tt.redirect {
+				t.Fatalf("Location %q, redirect wanted: %v", location, tt.redirect)
+			}
+		})
+	}
+}
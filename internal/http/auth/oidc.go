@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/services/auth"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type OpenIDProvider interface {
+	OpenIDProvider() models.OpenIDProvider
+	UserInfo(ctx context.Context, jwtToken string) (claims map[string]interface{}, err error)
+}
+
+// discoveryDocument is the provider metadata of OpenID Connect Discovery
+// section 3.
+type discoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	// TokenEndpointAuthSigningAlgValuesSupported is for private_key_jwt.
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	// DeviceAuthorizationEndpoint is from RFC 8628 section 4.
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+}
+
+func (h *handler) discovery(w http.ResponseWriter, r *http.Request) {
+	provider := h.openID.OpenIDProvider()
+	issuer := strings.TrimSuffix(provider.Issuer, "/")
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, discoveryDocument{
+		Issuer:                                     provider.Issuer,
+		AuthorizationEndpoint:                      issuer + "/authorize",
+		TokenEndpoint:                              issuer + "/token",
+		UserinfoEndpoint:                           issuer + "/userinfo",
+		JWKSURI:                                    issuer + "/.well-known/jwks.json",
+		ScopesSupported:                            provider.Scopes,
+		ResponseTypesSupported:                     []string{"code"},
+		GrantTypesSupported:                        provider.GrantTypes,
+		SubjectTypesSupported:                      []string{"public"},
+		IDTokenSigningAlgValuesSupported:           provider.SigningAlgorithms,
+		TokenEndpointAuthMethodsSupported:          provider.TokenEndpointAuthMethods,
+		CodeChallengeMethodsSupported:              []string{"S256"},
+		ClaimsSupported:                            provider.Claims,
+		TokenEndpointAuthSigningAlgValuesSupported: provider.TokenEndpointAuthAlgorithms,
+		DeviceAuthorizationEndpoint:                issuer + "/device_authorization",
+	})
+}
+
+// userInfo is the userinfo endpoint of OpenID Connect Core section 5.3.
+func (h *handler) userInfo(w http.ResponseWriter, r *http.Request) {
+	jwtToken, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	claims, err := h.openID.UserInfo(r.Context(), jwtToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "invalid_token"})
+		case errors.Is(err, auth.ErrInsufficientScope):
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+			writeJSON(w, http.StatusForbidden, errorResponse{Error: "insufficient_scope"})
+		default:
+			h.log.Error("failed to load userinfo", slog.String("error", err.Error()))
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: auth.OAuthServerError})
+		}
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, claims)
+}
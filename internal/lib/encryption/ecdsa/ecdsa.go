@@ -34,6 +34,10 @@ func GenerateECDSAKey(path string) ([]byte, error) {
 	return SecretKey, nil
 }
 
+func NewECDSAKey(curve elliptic.Curve) (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(curve, rand.Reader)
+}
+
 func SaveECDSAKey(key *ecdsa.PrivateKey, filename string) error {
 	keyBytes, err := x509.MarshalECPrivateKey(key)
 
@@ -45,7 +49,7 @@ func SaveECDSAKey(key *ecdsa.PrivateKey, filename string) error {
 		Type:  "EC PRIVATE KEY",
 		Bytes: keyBytes,
 	}
-	file, err := os.Create(filename)
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 	if err != nil {
 		return err
 	}
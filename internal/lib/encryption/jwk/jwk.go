@@ -0,0 +1,212 @@
+package jwk
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Key is the public part of a signing key in JSON Web Key format (RFC 7517).
+type Key struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// FromPublicKey builds a signature JWK for the key used with alg. The kid is
+// the RFC 7638 thumbprint, so it is stable for as long as the key does not
+// change.
+func FromPublicKey(publicKey crypto.PublicKey, alg string) (Key, error) {
+	key, err := fromPublicKey(publicKey)
+	if err != nil {
+		return Key{}, err
+	}
+	key.Use = "sig"
+	key.Alg = alg
+	return key, nil
+}
+
+// KeyID returns the kid FromPublicKey would assign to the key.
+func KeyID(publicKey crypto.PublicKey) (string, error) {
+	key, err := fromPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	return key.Kid, nil
+}
+
+// ParseSet reads a JWK Set, e.g. the keys a client registered, and checks
+// that every key can be used.
+func ParseSet(data []byte) (Set, error) {
+	var set Set
+	if err := json.Unmarshal(data, &set); err != nil {
+		return Set{}, err
+	}
+	if len(set.Keys) == 0 {
+		return Set{}, errors.New("jwk set has no keys")
+	}
+	for _, key := range set.Keys {
+		if _, err := key.PublicKey(); err != nil {
+			return Set{}, err
+		}
+	}
+	return set, nil
+}
+
+// PublicKey returns the key the JWK describes.
+func (k Key) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("ec point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("bad ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if n.BitLen() < 2048 || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("unsupported rsa key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty jwk member")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func fromPublicKey(publicKey crypto.PublicKey) (Key, error) {
+	var key Key
+	switch pub := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		name := pub.Curve.Params().Name
+		if name != "P-256" && name != "P-384" {
+			return Key{}, fmt.Errorf("unsupported curve %s", name)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		key = Key{
+			Kty: "EC",
+			Crv: name,
+			X:   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size))),
+			Y:   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size))),
+		}
+	case ed25519.PublicKey:
+		key = Key{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		}
+	case *rsa.PublicKey:
+		key = Key{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}
+	default:
+		return Key{}, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+
+	kid, err := thumbprint(key)
+	if err != nil {
+		return Key{}, err
+	}
+	key.Kid = kid
+	return key, nil
+}
+
+func thumbprint(key Key) (string, error) {
+	// RFC 7638: required members only, in lexicographic order, no whitespace.
+	var members interface{}
+	switch key.Kty {
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{key.Crv, key.Kty, key.X, key.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{key.Crv, key.Kty, key.X}
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{key.E, key.Kty, key.N}
+	default:
+		return "", fmt.Errorf("unsupported key type %s", key.Kty)
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
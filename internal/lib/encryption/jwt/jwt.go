@@ -2,49 +2,299 @@ package jwt
 
 import (
 	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/signer"
 	"context"
-	"crypto/x509"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"slices"
+	"strconv"
 	"time"
 )
 
+var ErrInvalidToken = errors.New("invalid token")
+
 type AccessToken struct {
 	Token string
 	Uid   int64
+	Sid   string
+	Ver   int64
+	Sub   string
+	Iss   string
+	Aud   []string
 	Jti   string
+	Iat   time.Time
 	Nbf   time.Time
 	Exp   int64
 	Role  string
+	// ClientID and Scope are set on tokens issued to OAuth clients.
+	ClientID string
+	Scope    string
 }
 
-func GenerateToken(ctx context.Context, user models.User, duration time.Duration, secret []byte) (string, error) {
-	if requestID, ok := ctx.Value("requestID").(string); ok {
-		fmt.Println("Request ID:", requestID)
-	}
+// Options are the issuer and audiences stamped into issued tokens and
+// required from validated ones.
+type Options struct {
+	Issuer   string
+	Audience []string
+}
+
+// KeyResolver looks up the public key a token was signed with by its kid.
+type KeyResolver interface {
+	VerificationKey(kid string) (signer.Verifier, error)
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	Uid  int64  `json:"uid"`
+	Sid  string `json:"sid,omitempty"`
+	Ver  int64  `json:"ver"`
+	Role string `json:"role"`
+	// client_id and scope as in RFC 9068.
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// GenerateToken issues an access token for the user's session, carrying the
+// session's OAuth client and scope when it has them.
+func GenerateToken(ctx context.Context, user models.User, session models.Session, duration time.Duration, s signer.Signer, opts Options) (string, error) {
 	select {
 	case <-ctx.Done():
 		return "", ctx.Err()
 	default:
 	}
 
-	privateKey, err := x509.ParsePKCS8PrivateKey(secret)
-	if err != nil {
-		return "", err
+	method := s.Algorithm.SigningMethod()
+	if method == nil || !s.Algorithm.Accepts(s.Key.Public()) {
+		return "", fmt.Errorf("key %s cannot sign %s", s.KeyID, s.Algorithm)
 	}
-	token := jwt.New(jwt.SigningMethodES256)
-	token.Header["alg"] = "HS256"
-	token.Header["typ"] = "JWT"
-	claims := token.Claims.(jwt.MapClaims)
-	claims["uid"] = user.ID
-	claims["jti"] = uuid.New().String()
-	claims["nbf"] = time.Now()
-	claims["exp"] = time.Now().Add(duration).Unix()
-	claims["role"] = user.Role
-	signToken, err := token.SignedString(privateKey)
+
+	now := time.Now()
+	token := jwt.NewWithClaims(method, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    opts.Issuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Audience:  opts.Audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuid.New().String(),
+		},
+		Uid:      user.ID,
+		Sid:      session.ID,
+		Ver:      user.TokenVersion,
+		Role:     user.Role,
+		ClientID: session.ClientID,
+		Scope:    session.Scope,
+	})
+	token.Header["kid"] = s.KeyID
+	signToken, err := token.SignedString(s.Key)
 	if err != nil {
 		return "", err
 	}
 	return signToken, nil
 }
+
+// ParseToken verifies the signature and the registered claims of a token
+// issued by GenerateToken and returns its claims. The alg header must match
+// the type of the key the kid refers to.
+func ParseToken(ctx context.Context, tokenString string, keys KeyResolver, opts Options) (AccessToken, error) {
+	select {
+	case <-ctx.Done():
+		return AccessToken{}, ctx.Err()
+	default:
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+
+	var tokenClaims claims
+	token, err := jwt.ParseWithClaims(tokenString, &tokenClaims, func(token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("missing kid header")
+		}
+		verifier, err := keys.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != string(verifier.Algorithm) || !verifier.Algorithm.Accepts(verifier.Key) {
+			return nil, fmt.Errorf("alg %s does not match key %s", token.Method.Alg(), kid)
+		}
+		return verifier.Key, nil
+	}, parserOpts...)
+	if err != nil {
+		return AccessToken{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if !token.Valid {
+		return AccessToken{}, ErrInvalidToken
+	}
+	if len(opts.Audience) > 0 && !slices.ContainsFunc(tokenClaims.Audience, func(aud string) bool {
+		return slices.Contains(opts.Audience, aud)
+	}) {
+		return AccessToken{}, fmt.Errorf("%w: audience not accepted", ErrInvalidToken)
+	}
+
+	accessToken := AccessToken{
+		Token:    tokenString,
+		Uid:      tokenClaims.Uid,
+		Sid:      tokenClaims.Sid,
+		Ver:      tokenClaims.Ver,
+		Sub:      tokenClaims.Subject,
+		Iss:      tokenClaims.Issuer,
+		Aud:      tokenClaims.Audience,
+		Jti:      tokenClaims.ID,
+		Exp:      tokenClaims.ExpiresAt.Unix(),
+		Role:     tokenClaims.Role,
+		ClientID: tokenClaims.ClientID,
+		Scope:    tokenClaims.Scope,
+	}
+	if tokenClaims.IssuedAt != nil {
+		accessToken.Iat = tokenClaims.IssuedAt.Time
+	}
+	if tokenClaims.NotBefore != nil {
+		accessToken.Nbf = tokenClaims.NotBefore.Time
+	}
+	return accessToken, nil
+}
+
+// IDToken is an OpenID Connect ID token for a user signing in to a client.
+type IDToken struct {
+	Subject  string
+	ClientID string
+	Nonce    string
+	AuthTime time.Time
+	// Claims are the user's claims the granted scopes allow, e.g. email.
+	Claims map[string]interface{}
+}
+
+// GenerateIDToken signs an ID token as OpenID Connect Core section 2
+// describes it, for the client as its only audience.
+func GenerateIDToken(ctx context.Context, idToken IDToken, duration time.Duration, s signer.Signer, opts Options) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	default:
+	}
+
+	method := s.Algorithm.SigningMethod()
+	if method == nil || !s.Algorithm.Accepts(s.Key.Public()) {
+		return "", fmt.Errorf("key %s cannot sign %s", s.KeyID, s.Algorithm)
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{}
+	for name, value := range idToken.Claims {
+		idClaims[name] = value
+	}
+	idClaims["iss"] = opts.Issuer
+	idClaims["sub"] = idToken.Subject
+	idClaims["aud"] = idToken.ClientID
+	idClaims["exp"] = jwt.NewNumericDate(now.Add(duration))
+	idClaims["iat"] = jwt.NewNumericDate(now)
+	if idToken.Nonce != "" {
+		idClaims["nonce"] = idToken.Nonce
+	}
+	if !idToken.AuthTime.IsZero() {
+		idClaims["auth_time"] = jwt.NewNumericDate(idToken.AuthTime)
+	}
+
+	token := jwt.NewWithClaims(method, idClaims)
+	token.Header["kid"] = s.KeyID
+	return token.SignedString(s.Key)
+}
+
+// GenerateClientToken issues an access token to a client acting on its own
+// behalf, as the client credentials grant does. It names the client as its
+// subject and carries no user.
+func GenerateClientToken(ctx context.Context, clientID string, scope string, duration time.Duration, s signer.Signer, opts Options) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	default:
+	}
+
+	method := s.Algorithm.SigningMethod()
+	if method == nil || !s.Algorithm.Accepts(s.Key.Public()) {
+		return "", fmt.Errorf("key %s cannot sign %s", s.KeyID, s.Algorithm)
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(method, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    opts.Issuer,
+			Subject:   clientID,
+			Audience:  opts.Audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuid.New().String(),
+		},
+		ClientID: clientID,
+		Scope:    scope,
+	})
+	token.Header["kid"] = s.KeyID
+	return token.SignedString(s.Key)
+}
+
+// ClientAssertion is a verified private_key_jwt assertion.
+type ClientAssertion struct {
+	Jti string
+	Exp time.Time
+}
+
+// ParseClientAssertion verifies a client assertion of RFC 7523 section 3:
+// the client must be both its issuer and subject, and one of audience its
+// audience. The kid header may be left out when the client registered a
+// single key.
+func ParseClientAssertion(ctx context.Context, assertion string, clientID string, keys KeyResolver, audience []string) (ClientAssertion, error) {
+	select {
+	case <-ctx.Done():
+		return ClientAssertion{}, ctx.Err()
+	default:
+	}
+
+	var assertionClaims jwt.RegisteredClaims
+	token, err := jwt.ParseWithClaims(assertion, &assertionClaims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		verifier, err := keys.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != string(verifier.Algorithm) || !verifier.Algorithm.Accepts(verifier.Key) {
+			return nil, fmt.Errorf("alg %s does not match key %s", token.Method.Alg(), kid)
+		}
+		return verifier.Key, nil
+	}, jwt.WithExpirationRequired(), jwt.WithIssuer(clientID), jwt.WithSubject(clientID))
+	if err != nil {
+		return ClientAssertion{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if !token.Valid {
+		return ClientAssertion{}, ErrInvalidToken
+	}
+	if !slices.ContainsFunc(assertionClaims.Audience, func(aud string) bool {
+		return slices.Contains(audience, aud)
+	}) {
+		return ClientAssertion{}, fmt.Errorf("%w: audience not accepted", ErrInvalidToken)
+	}
+	if assertionClaims.ID == "" {
+		return ClientAssertion{}, fmt.Errorf("%w: missing jti", ErrInvalidToken)
+	}
+	return ClientAssertion{Jti: assertionClaims.ID, Exp: assertionClaims.ExpiresAt.Time}, nil
+}
+
+// AssertionSubject reads the subject of a client assertion without
+// verifying it, to find the client whose keys verify it.
+func AssertionSubject(assertion string) (string, error) {
+	var assertionClaims jwt.RegisteredClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(assertion, &assertionClaims); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return assertionClaims.Subject, nil
+}
@@ -0,0 +1,460 @@
+package keyring
+
+import (
+	"AuthGrpc/internal/lib/encryption/jwk"
+	"AuthGrpc/internal/lib/encryption/signer"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	// StateNext keys are published but not yet used for signing, so
+	// verifiers can pick them up before the rotation.
+	StateNext State = "next"
+	// StateActive is the single key new tokens are signed with.
+	StateActive State = "active"
+	// StateRetired keys no longer sign but still verify tokens issued
+	// before the rotation, until the retention period has passed.
+	StateRetired State = "retired"
+)
+
+const manifestFile = "keyring.json"
+
+var (
+	ErrNoActiveKey = errors.New("no active signing key")
+	ErrKeyNotFound = errors.New("signing key not found")
+)
+
+type Key struct {
+	ID          string
+	State       State
+	Algorithm   signer.Algorithm
+	PrivateKey  crypto.Signer
+	CreatedAt   time.Time
+	ActivatedAt time.Time
+	RetiredAt   time.Time
+}
+
+type manifestEntry struct {
+	ID          string           `json:"kid"`
+	File        string           `json:"file"`
+	Algorithm   signer.Algorithm `json:"alg"`
+	State       State            `json:"state"`
+	CreatedAt   time.Time        `json:"created_at"`
+	ActivatedAt time.Time        `json:"activated_at,omitempty"`
+	RetiredAt   time.Time        `json:"retired_at,omitempty"`
+}
+
+// Ring keeps the signing keys in a directory together with a manifest of
+// their states. Retired keys are removed once retention has passed, which
+// must be at least the lifetime of the longest-lived token they signed.
+type Ring struct {
+	sync.RWMutex
+	log              *slog.Logger
+	dir              string
+	algorithm        signer.Algorithm
+	rotationInterval time.Duration
+	retention        time.Duration
+	keys             []Key
+}
+
+// New loads the key ring from dir. New keys are generated for algorithm. An
+// empty ES256 ring is seeded from legacyKeyPath when that file exists, so the
+// key used before the ring was introduced keeps signing until the first
+// rotation.
+func New(log *slog.Logger, dir string, legacyKeyPath string, algorithm signer.Algorithm, rotationInterval, retention time.Duration) (*Ring, error) {
+	const op = "keyring.New"
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	r := &Ring{
+		log:              log,
+		dir:              dir,
+		algorithm:        algorithm,
+		rotationInterval: rotationInterval,
+		retention:        retention,
+	}
+	if err := r.load(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(r.keys) == 0 {
+		if err := r.seed(legacyKeyPath); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := r.replaceNext(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := r.Active(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return r, nil
+}
+
+// Active returns the key new tokens must be signed with.
+func (r *Ring) Active() (Key, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, key := range r.keys {
+		if key.State == StateActive {
+			return key, nil
+		}
+	}
+	return Key{}, ErrNoActiveKey
+}
+
+// Signer returns the key as a token signer.
+func (k Key) Signer() signer.Signer {
+	return signer.Signer{
+		KeyID:     k.ID,
+		Algorithm: k.Algorithm,
+		Key:       k.PrivateKey,
+	}
+}
+
+// VerificationKey returns the public key for kid if tokens signed with it are
+// still acceptable.
+func (r *Ring) VerificationKey(kid string) (signer.Verifier, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, key := range r.keys {
+		if key.ID != kid {
+			continue
+		}
+		if key.State == StateNext {
+			return signer.Verifier{}, ErrKeyNotFound
+		}
+		return signer.Verifier{Algorithm: key.Algorithm, Key: key.PrivateKey.Public()}, nil
+	}
+	return signer.Verifier{}, ErrKeyNotFound
+}
+
+// Keys returns every key in the ring, including next and retired ones, for
+// publishing in the JWKS.
+func (r *Ring) Keys() []Key {
+	r.RLock()
+	defer r.RUnlock()
+
+	keys := make([]Key, len(r.keys))
+	copy(keys, r.keys)
+	return keys
+}
+
+// Rotate promotes the next key to active, retires the current active key and
+// generates a new next key.
+func (r *Ring) Rotate() error {
+	const op = "keyring.Rotate"
+
+	r.Lock()
+	defer r.Unlock()
+
+	// Work on a copy, so a failed save leaves the ring as it was.
+	keys := make([]Key, len(r.keys))
+	copy(keys, r.keys)
+	var generated []Key
+
+	next := -1
+	for i := range keys {
+		if keys[i].State == StateNext {
+			next = i
+			break
+		}
+	}
+	if next == -1 {
+		key, err := r.generate(StateNext)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		generated = append(generated, key)
+		keys = append(keys, key)
+		next = len(keys) - 1
+	}
+
+	now := time.Now()
+	for i := range keys {
+		if keys[i].State == StateActive {
+			keys[i].State = StateRetired
+			keys[i].RetiredAt = now
+		}
+	}
+	keys[next].State = StateActive
+	keys[next].ActivatedAt = now
+
+	key, err := r.generate(StateNext)
+	if err != nil {
+		r.discard(generated)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	generated = append(generated, key)
+	keys = append(keys, key)
+
+	if err := r.save(keys); err != nil {
+		r.discard(generated)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	r.keys = keys
+	r.log.Info("signing key rotated", slog.Int("keys", len(r.keys)))
+	return nil
+}
+
+// Run rotates the active key every rotation interval and removes retired keys
+// whose retention has passed, until ctx is done.
+func (r *Ring) Run(ctx context.Context, checkInterval time.Duration) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if r.rotationDue() {
+			if err := r.Rotate(); err != nil {
+				r.log.Error("failed to rotate signing key", slog.String("error", err.Error()))
+			}
+		}
+		if err := r.prune(); err != nil {
+			r.log.Error("failed to prune retired signing keys", slog.String("error", err.Error()))
+		}
+	}
+}
+
+func (r *Ring) rotationDue() bool {
+	if r.rotationInterval <= 0 {
+		return false
+	}
+	active, err := r.Active()
+	if err != nil {
+		return true
+	}
+	return time.Since(active.ActivatedAt) >= r.rotationInterval
+}
+
+func (r *Ring) prune() error {
+	r.Lock()
+	defer r.Unlock()
+
+	var kept, removed []Key
+	for _, key := range r.keys {
+		if key.State == StateRetired && time.Since(key.RetiredAt) > r.retention {
+			removed = append(removed, key)
+			continue
+		}
+		kept = append(kept, key)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if err := r.save(kept); err != nil {
+		return err
+	}
+	r.keys = kept
+	for _, key := range removed {
+		if err := os.Remove(r.keyPath(key.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		r.log.Info("retired signing key removed", slog.String("kid", key.ID))
+	}
+	return nil
+}
+
+func (r *Ring) seed(legacyKeyPath string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	var keys []Key
+	if legacyKeyPath != "" && r.algorithm == signer.ES256 {
+		if _, err := os.Stat(legacyKeyPath); err == nil {
+			privateKey, err := signer.ES256.LoadKey(legacyKeyPath)
+			if err != nil {
+				return err
+			}
+			active, err := r.add(privateKey, signer.ES256, StateActive, now)
+			if err != nil {
+				return err
+			}
+			active.ActivatedAt = now
+			keys = append(keys, active)
+		}
+	}
+	if len(keys) == 0 {
+		active, err := r.generate(StateActive)
+		if err != nil {
+			return err
+		}
+		active.ActivatedAt = now
+		keys = append(keys, active)
+	}
+
+	next, err := r.generate(StateNext)
+	if err != nil {
+		return err
+	}
+	keys = append(keys, next)
+	if err := r.save(keys); err != nil {
+		return err
+	}
+	r.keys = keys
+	return nil
+}
+
+// replaceNext swaps out a next key generated for a different algorithm, so a
+// change of the configured algorithm takes effect at the next rotation.
+func (r *Ring) replaceNext() error {
+	r.Lock()
+	defer r.Unlock()
+
+	keys := make([]Key, len(r.keys))
+	copy(keys, r.keys)
+	var replaced, generated []Key
+	for i, key := range keys {
+		if key.State != StateNext || key.Algorithm == r.algorithm {
+			continue
+		}
+		next, err := r.generate(StateNext)
+		if err != nil {
+			r.discard(generated)
+			return err
+		}
+		keys[i] = next
+		replaced = append(replaced, key)
+		generated = append(generated, next)
+	}
+	if len(replaced) == 0 {
+		return nil
+	}
+	if err := r.save(keys); err != nil {
+		r.discard(generated)
+		return err
+	}
+	r.keys = keys
+	for _, key := range replaced {
+		if err := os.Remove(r.keyPath(key.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Ring) generate(state State) (Key, error) {
+	privateKey, err := r.algorithm.GenerateKey()
+	if err != nil {
+		return Key{}, err
+	}
+	return r.add(privateKey, r.algorithm, state, time.Now())
+}
+
+func (r *Ring) add(privateKey crypto.Signer, algorithm signer.Algorithm, state State, createdAt time.Time) (Key, error) {
+	kid, err := jwk.KeyID(privateKey.Public())
+	if err != nil {
+		return Key{}, err
+	}
+	if err := algorithm.SaveKey(privateKey, r.keyPath(kid)); err != nil {
+		return Key{}, err
+	}
+	return Key{
+		ID:         kid,
+		State:      state,
+		Algorithm:  algorithm,
+		PrivateKey: privateKey,
+		CreatedAt:  createdAt,
+	}, nil
+}
+
+func (r *Ring) load() error {
+	data, err := os.ReadFile(filepath.Join(r.dir, manifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var entries []manifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Algorithm == "" {
+			// Manifests written before algorithms were configurable.
+			entry.Algorithm = signer.ES256
+		}
+		privateKey, err := entry.Algorithm.LoadKey(filepath.Join(r.dir, entry.File))
+		if err != nil {
+			return fmt.Errorf("load key %s: %w", entry.ID, err)
+		}
+		r.keys = append(r.keys, Key{
+			ID:          entry.ID,
+			State:       entry.State,
+			Algorithm:   entry.Algorithm,
+			PrivateKey:  privateKey,
+			CreatedAt:   entry.CreatedAt,
+			ActivatedAt: entry.ActivatedAt,
+			RetiredAt:   entry.RetiredAt,
+		})
+	}
+	return nil
+}
+
+// save sorts keys and writes them as the manifest; callers must hold the
+// write lock and only replace r.keys once save succeeded.
+func (r *Ring) save(keys []Key) error {
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	entries := make([]manifestEntry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, manifestEntry{
+			ID:          key.ID,
+			File:        filepath.Base(r.keyPath(key.ID)),
+			Algorithm:   key.Algorithm,
+			State:       key.State,
+			CreatedAt:   key.CreatedAt,
+			ActivatedAt: key.ActivatedAt,
+			RetiredAt:   key.RetiredAt,
+		})
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(r.dir, manifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(r.dir, manifestFile))
+}
+
+// discard removes the files of keys that never made it into the manifest.
+func (r *Ring) discard(keys []Key) {
+	for _, key := range keys {
+		if err := os.Remove(r.keyPath(key.ID)); err != nil && !os.IsNotExist(err) {
+			r.log.Error("failed to remove unused signing key", slog.String("kid", key.ID), slog.String("error", err.Error()))
+		}
+	}
+}
+
+func (r *Ring) keyPath(kid string) string {
+	return filepath.Join(r.dir, kid+".pem")
+}
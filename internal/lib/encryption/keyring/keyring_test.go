@@ -0,0 +1,290 @@
+package keyring
+
+import (
+	"AuthGrpc/internal/lib/encryption/jwk"
+	"AuthGrpc/internal/lib/encryption/signer"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newTestRing(t *testing.T, dir string, rotationInterval, retention time.Duration) *Ring {
+	t.Helper()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	r, err := New(log, dir, "", signer.ES256, rotationInterval, retention)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return r
+}
+
+// keyIDs returns the kid of each key in the ring by state.
+func keyIDs(r *Ring) map[State][]string {
+	ids := map[State][]string{}
+	for _, key := range r.Keys() {
+		ids[key.State] = append(ids[key.State], key.ID)
+	}
+	return ids
+}
+
+// jwks returns the kids the ring publishes, as the JWKS endpoint builds them.
+func jwks(t *testing.T, r *Ring) []string {
+	t.Helper()
+	var kids []string
+	for _, key := range r.Keys() {
+		published, err := jwk.FromPublicKey(key.PrivateKey.Public(), string(key.Algorithm))
+		if err != nil {
+			t.Fatalf("FromPublicKey: %v", err)
+		}
+		if published.Kid != key.ID {
+			t.Fatalf("published kid %s for key %s", published.Kid, key.ID)
+		}
+		kids = append(kids, published.Kid)
+	}
+	return kids
+}
+
+// backdate moves the activation and retirement of every key d into the past.
+func backdate(r *Ring, d time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	for i := range r.keys {
+		if !r.keys[i].ActivatedAt.IsZero() {
+			r.keys[i].ActivatedAt = r.keys[i].ActivatedAt.Add(-d)
+		}
+		if !r.keys[i].RetiredAt.IsZero() {
+			r.keys[i].RetiredAt = r.keys[i].RetiredAt.Add(-d)
+		}
+	}
+}
+
+func TestNewSeedsActiveAndNextKeys(t *testing.T) {
+	r := newTestRing(t, t.TempDir(), time.Hour, time.Hour)
+
+	ids := keyIDs(r)
+	if len(ids[StateActive]) != 1 || len(ids[StateNext]) != 1 || len(ids[StateRetired]) != 0 {
+		t.Fatalf("new ring has keys %v, want one active and one next", ids)
+	}
+	active, err := r.Active()
+	if err != nil {
+		t.Fatalf("Active: %v", err)
+	}
+	if active.ID != ids[StateActive][0] || active.ActivatedAt.IsZero() {
+		t.Fatalf("Active() = %+v", active)
+	}
+	// The next key is published but does not verify tokens yet.
+	if _, err := r.VerificationKey(ids[StateNext][0]); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("VerificationKey(next) = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestRotationDue(t *testing.T) {
+	r := newTestRing(t, t.TempDir(), time.Hour, time.Hour)
+	if r.rotationDue() {
+		t.Fatal("rotation due right after the key was activated")
+	}
+	backdate(r, time.Hour)
+	if !r.rotationDue() {
+		t.Fatal("rotation not due once the interval has passed")
+	}
+
+	if newTestRing(t, t.TempDir(), 0, time.Hour).rotationDue() {
+		t.Fatal("rotation due with rotation turned off")
+	}
+}
+
+func TestRotateMovesKeysThroughStates(t *testing.T) {
+	r := newTestRing(t, t.TempDir(), time.Hour, time.Hour)
+	before := keyIDs(r)
+	publishedBefore := jwks(t, r)
+
+	if err := r.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	after := keyIDs(r)
+	if !reflect.DeepEqual(after[StateActive], before[StateNext]) {
+		t.Fatalf("active key after rotation is %v, want the next key %v", after[StateActive], before[StateNext])
+	}
+	if !reflect.DeepEqual(after[StateRetired], before[StateActive]) {
+		t.Fatalf("retired keys after rotation are %v, want the old active key %v", after[StateRetired], before[StateActive])
+	}
+	if len(after[StateNext]) != 1 || after[StateNext][0] == before[StateNext][0] {
+		t.Fatalf("next keys after rotation are %v, want a new one", after[StateNext])
+	}
+
+	// The JWKS keeps the old active key for tokens it signed and adds the
+	// new next key.
+	publishedAfter := jwks(t, r)
+	if len(publishedAfter) != 3 {
+		t.Fatalf("JWKS after rotation has %d keys, want 3", len(publishedAfter))
+	}
+	for _, kid := range publishedBefore {
+		if !slices.Contains(publishedAfter, kid) {
+			t.Fatalf("JWKS dropped %s at rotation", kid)
+		}
+	}
+	if !slices.Contains(publishedAfter, after[StateNext][0]) {
+		t.Fatal("JWKS does not publish the new next key")
+	}
+	if _, err := r.VerificationKey(before[StateActive][0]); err != nil {
+		t.Fatalf("VerificationKey(retired): %v", err)
+	}
+}
+
+func TestRotateLeavesRingUnchangedWhenSaveFails(t *testing.T) {
+	dir := t.TempDir()
+	r := newTestRing(t, dir, time.Hour, time.Hour)
+	before := keyIDs(r)
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+
+	// A directory in place of the temporary manifest makes save fail.
+	tmp := filepath.Join(dir, manifestFile+".tmp")
+	if err := os.Mkdir(tmp, 0o700); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	if err := r.Rotate(); err == nil {
+		t.Fatal("Rotate succeeded without saving the manifest")
+	}
+	if after := keyIDs(r); !reflect.DeepEqual(after, before) {
+		t.Fatalf("keys after a failed rotation are %v, want %v", after, before)
+	}
+	filesAfter, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	if !reflect.DeepEqual(filesAfter, files) {
+		t.Fatalf("key files after a failed rotation are %v, want %v", filesAfter, files)
+	}
+
+	if err := os.Remove(tmp); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := r.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if after := keyIDs(r); !reflect.DeepEqual(after[StateActive], before[StateNext]) {
+		t.Fatalf("active key after rotation is %v, want the next key %v", after[StateActive], before[StateNext])
+	}
+}
+
+func TestKeyFilesArePrivate(t *testing.T) {
+	for _, algorithm := range []signer.Algorithm{signer.ES256, signer.RS256, signer.EdDSA} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			dir := t.TempDir()
+			log := slog.New(slog.NewTextHandler(io.Discard, nil))
+			if _, err := New(log, dir, "", algorithm, time.Hour, time.Hour); err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			files, err := filepath.Glob(filepath.Join(dir, "*"))
+			if err != nil {
+				t.Fatalf("Glob: %v", err)
+			}
+			for _, file := range files {
+				info, err := os.Stat(file)
+				if err != nil {
+					t.Fatalf("Stat: %v", err)
+				}
+				if perm := info.Mode().Perm(); perm != 0o600 {
+					t.Fatalf("%s has mode %o, want 600", filepath.Base(file), perm)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneRemovesKeysPastRetention(t *testing.T) {
+	dir := t.TempDir()
+	r := newTestRing(t, dir, time.Hour, time.Hour)
+	retired := keyIDs(r)[StateActive][0]
+	if err := r.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	if err := r.prune(); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if _, err := r.VerificationKey(retired); err != nil {
+		t.Fatalf("retired key removed within retention: %v", err)
+	}
+
+	backdate(r, time.Hour+time.Minute)
+	if err := r.prune(); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if _, err := r.VerificationKey(retired); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("VerificationKey(pruned) = %v, want ErrKeyNotFound", err)
+	}
+	if slices.Contains(jwks(t, r), retired) {
+		t.Fatal("JWKS still publishes the pruned key")
+	}
+	if _, err := os.Stat(r.keyPath(retired)); !os.IsNotExist(err) {
+		t.Fatalf("pruned key file: %v, want it removed", err)
+	}
+	// Only retired keys are pruned, however old.
+	if ids := keyIDs(r); len(ids[StateActive]) != 1 || len(ids[StateNext]) != 1 {
+		t.Fatalf("keys after pruning are %v", ids)
+	}
+}
+
+func TestRunRotatesAndPrunes(t *testing.T) {
+	r := newTestRing(t, t.TempDir(), 20*time.Millisecond, 20*time.Millisecond)
+	first := keyIDs(r)[StateActive][0]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if _, err := r.VerificationKey(first); errors.Is(err, ErrKeyNotFound) {
+			return
+		}
+	}
+	t.Fatalf("first key still verifies, keys %v", keyIDs(r))
+}
+
+func TestNewReloadsKeysFromDir(t *testing.T) {
+	dir := t.TempDir()
+	r := newTestRing(t, dir, time.Hour, time.Hour)
+	if err := r.Rotate(); err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	want := keyIDs(r)
+	wantActive, err := r.Active()
+	if err != nil {
+		t.Fatalf("Active: %v", err)
+	}
+
+	reloaded := newTestRing(t, dir, time.Hour, time.Hour)
+	if got := keyIDs(reloaded); !reflect.DeepEqual(got, want) {
+		t.Fatalf("reloaded keys %v, want %v", got, want)
+	}
+	active, err := reloaded.Active()
+	if err != nil {
+		t.Fatalf("Active: %v", err)
+	}
+	if !active.ActivatedAt.Equal(wantActive.ActivatedAt) {
+		t.Fatalf("reloaded activation time %v, want %v", active.ActivatedAt, wantActive.ActivatedAt)
+	}
+	// The reloaded private key still produces the published kid.
+	kid, err := jwk.KeyID(active.PrivateKey.Public())
+	if err != nil || kid != active.ID {
+		t.Fatalf("reloaded active key has kid %s, %v, want %s", kid, err, active.ID)
+	}
+}
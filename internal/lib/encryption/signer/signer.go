@@ -0,0 +1,134 @@
+package signer
+
+import (
+	myecdsa "AuthGrpc/internal/lib/encryption/ecdsa"
+	myed25519 "AuthGrpc/internal/lib/encryption/ed25519"
+	myrsa "AuthGrpc/internal/lib/encryption/rsa"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithm is a JWS algorithm name. It decides which kind of key is
+// generated and loaded and how tokens are signed with it.
+type Algorithm string
+
+const (
+	ES256 Algorithm = "ES256"
+	ES384 Algorithm = "ES384"
+	EdDSA Algorithm = "EdDSA"
+	RS256 Algorithm = "RS256"
+	PS256 Algorithm = "PS256"
+)
+
+// Signer is a private key together with the algorithm and kid tokens signed
+// with it carry.
+type Signer struct {
+	KeyID     string
+	Algorithm Algorithm
+	Key       crypto.Signer
+}
+
+// Verifier is the public half of a Signer.
+type Verifier struct {
+	Algorithm Algorithm
+	Key       crypto.PublicKey
+}
+
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch alg := Algorithm(name); alg {
+	case ES256, ES384, EdDSA, RS256, PS256:
+		return alg, nil
+	default:
+		return "", fmt.Errorf("unsupported signing algorithm %q", name)
+	}
+}
+
+func (a Algorithm) SigningMethod() jwt.SigningMethod {
+	switch a {
+	case ES256:
+		return jwt.SigningMethodES256
+	case ES384:
+		return jwt.SigningMethodES384
+	case EdDSA:
+		return jwt.SigningMethodEdDSA
+	case RS256:
+		return jwt.SigningMethodRS256
+	case PS256:
+		return jwt.SigningMethodPS256
+	default:
+		return nil
+	}
+}
+
+// Accepts reports whether publicKey is of the type and size the algorithm
+// requires.
+func (a Algorithm) Accepts(publicKey crypto.PublicKey) bool {
+	switch pub := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		return (a == ES256 && pub.Curve == elliptic.P256()) ||
+			(a == ES384 && pub.Curve == elliptic.P384())
+	case ed25519.PublicKey:
+		return a == EdDSA
+	case *rsa.PublicKey:
+		return a == RS256 || a == PS256
+	default:
+		return false
+	}
+}
+
+func (a Algorithm) GenerateKey() (crypto.Signer, error) {
+	switch a {
+	case ES256:
+		return myecdsa.NewECDSAKey(elliptic.P256())
+	case ES384:
+		return myecdsa.NewECDSAKey(elliptic.P384())
+	case EdDSA:
+		return myed25519.GenerateEd25519Key()
+	case RS256, PS256:
+		return myrsa.GenerateRSAKey()
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", a)
+	}
+}
+
+func (a Algorithm) SaveKey(key crypto.Signer, filename string) error {
+	switch privateKey := key.(type) {
+	case *ecdsa.PrivateKey:
+		return myecdsa.SaveECDSAKey(privateKey, filename)
+	case ed25519.PrivateKey:
+		return myed25519.SaveEd25519Key(privateKey, filename)
+	case *rsa.PrivateKey:
+		return myrsa.SaveRSAKey(privateKey, filename)
+	default:
+		return fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+func (a Algorithm) LoadKey(filePath string) (crypto.Signer, error) {
+	var (
+		key crypto.Signer
+		err error
+	)
+	switch a {
+	case ES256, ES384:
+		key, err = myecdsa.LoadECDSAKey(filePath)
+	case EdDSA:
+		key, err = myed25519.LoadEd25519Key(filePath)
+	case RS256, PS256:
+		key, err = myrsa.LoadRSAKey(filePath)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", a)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !a.Accepts(key.Public()) {
+		return nil, fmt.Errorf("key %s cannot be used with %s", filePath, a)
+	}
+	return key, nil
+}
@@ -0,0 +1,77 @@
+package symmetric
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const keySize = 32
+
+// GenerateKey loads a base64-encoded AES-256 key from path, creating the file
+// with a random key when it does not exist.
+func GenerateKey(path string) ([]byte, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		key := make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		data := base64.StdEncoding.EncodeToString(key) + "\n"
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key %s: %w", path, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key %s must be %d bytes", path, keySize)
+	}
+	return key, nil
+}
+
+// Encrypt seals plaintext with AES-GCM. additionalData is authenticated but
+// not encrypted; the same value must be passed to Decrypt.
+func Encrypt(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func Decrypt(key, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,190 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/md4"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Hash functions breach lists come in.
+const (
+	HashSHA1 = "sha1"
+	HashNTLM = "ntlm"
+)
+
+// prefixLength is the number of hex characters the HIBP range files are
+// named after.
+const prefixLength = 5
+
+// maxLineLength bounds a "HASH:COUNT" line, so finding the line around an
+// offset never reads more than twice this.
+const maxLineLength = 128
+
+var ErrUnknownHash = errors.New("unknown hash function")
+
+// BreachList looks passwords up in a breached password list in the Have I
+// Been Pwned download format, read from disk on every lookup rather than
+// loaded into memory. The list is either one file of "HASH:COUNT" lines
+// sorted by hash, searched by bisection, or a directory of range files named
+// after the first five hex characters of the hash, each holding
+// "SUFFIX:COUNT" lines.
+type BreachList struct {
+	path string
+	hash string
+	dir  bool
+}
+
+// OpenBreachList checks that path exists and returns a list hashing
+// passwords with hash, either HashSHA1 or HashNTLM.
+func OpenBreachList(path string, hash string) (*BreachList, error) {
+	if hash != HashSHA1 && hash != HashNTLM {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownHash, hash)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &BreachList{path: path, hash: hash, dir: info.IsDir()}, nil
+}
+
+// Count returns how many times the password appears in the list, zero when
+// it does not.
+func (b *BreachList) Count(password string) (int, error) {
+	key := b.hashPassword(password)
+	if b.dir {
+		return b.countInRange(key)
+	}
+	return b.countInFile(key)
+}
+
+func (b *BreachList) hashPassword(password string) string {
+	if b.hash == HashNTLM {
+		h := md4.New()
+		for _, u := range utf16.Encode([]rune(password)) {
+			h.Write([]byte{byte(u), byte(u >> 8)})
+		}
+		return strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+	}
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// countInRange scans the range file for the hash's prefix.
+func (b *BreachList) countInRange(key string) (int, error) {
+	prefix, suffix := key[:prefixLength], key[prefixLength:]
+	f, err := os.Open(filepath.Join(b.path, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = os.Open(filepath.Join(b.path, prefix+".txt"))
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, count, err := parseBreachLine(scanner.Bytes())
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		if strings.EqualFold(hash, suffix) {
+			return count, nil
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// countInFile bisects the sorted file by byte offset, reading only the line
+// around each probe.
+func (b *BreachList) countInFile(key string) (int, error) {
+	f, err := os.Open(b.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// The line for key, if any, starts in [lo, hi).
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		start, line, err := lineAt(f, lo+(hi-lo)/2, info.Size())
+		if err != nil {
+			return 0, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			// A trailing newline; everything left is before it.
+			hi = start
+			continue
+		}
+		hash, count, err := parseBreachLine(line)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", b.path, err)
+		}
+		switch c := strings.Compare(strings.ToUpper(hash), key); {
+		case c == 0:
+			return count, nil
+		case c < 0:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = start
+		}
+	}
+	return 0, nil
+}
+
+// lineAt returns the line containing the byte at off and where it starts,
+// without its newline.
+func lineAt(f *os.File, off int64, size int64) (int64, []byte, error) {
+	from := off - maxLineLength
+	if from < 0 {
+		from = 0
+	}
+	to := off + maxLineLength
+	if to > size {
+		to = size
+	}
+	buf := make([]byte, to-from)
+	if _, err := f.ReadAt(buf, from); err != nil {
+		return 0, nil, err
+	}
+
+	rel := off - from
+	start := int64(bytes.LastIndexByte(buf[:rel], '\n') + 1)
+	if start == 0 && from > 0 {
+		return 0, nil, fmt.Errorf("line at offset %d is longer than %d bytes", off, maxLineLength)
+	}
+	end := int64(len(buf))
+	if i := bytes.IndexByte(buf[rel:], '\n'); i >= 0 {
+		end = rel + int64(i)
+	} else if to < size {
+		return 0, nil, fmt.Errorf("line at offset %d is longer than %d bytes", off, maxLineLength)
+	}
+	return from + start, buf[start:end], nil
+}
+
+func parseBreachLine(line []byte) (string, int, error) {
+	hash, count, ok := strings.Cut(strings.TrimSpace(string(line)), ":")
+	if !ok {
+		return "", 0, fmt.Errorf("malformed line %q", line)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed line %q", line)
+	}
+	return hash, n, nil
+}
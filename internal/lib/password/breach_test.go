@@ -0,0 +1,141 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// breachFixtures are the lists in testdata, with the passwords on their
+// first and last lines.
+var breachFixtures = []struct {
+	hash  string
+	file  string
+	first string
+	last  string
+}{
+	{HashSHA1, "testdata/pwned-sha1.txt", "password", "letmein"},
+	{HashNTLM, "testdata/pwned-ntlm.txt", "qwerty", "dragon"},
+}
+
+// breachCounts are the counts of the passwords in both fixtures.
+var breachCounts = map[string]int{
+	"password":     9545824,
+	"123456":       37359195,
+	"qwerty":       3946737,
+	"letmein":      411987,
+	"dragon":       1032234,
+	"monkey":       1117457,
+	"sunshine":     482324,
+	"not-breached": 0,
+}
+
+// breachLayouts write a fixture to dir in the ways a list may come and
+// return the path to open.
+var breachLayouts = []struct {
+	name  string
+	write func(t *testing.T, dir string, list string) string
+}{
+	{"file", func(t *testing.T, dir string, list string) string {
+		return writeBreachFile(t, dir, list)
+	}},
+	{"file without trailing newline", func(t *testing.T, dir string, list string) string {
+		return writeBreachFile(t, dir, strings.TrimSuffix(list, "\n"))
+	}},
+	{"file with CRLF", func(t *testing.T, dir string, list string) string {
+		return writeBreachFile(t, dir, strings.ReplaceAll(list, "\n", "\r\n"))
+	}},
+	{"range directory", func(t *testing.T, dir string, list string) string {
+		return writeBreachRanges(t, dir, list, "")
+	}},
+	{"range directory with .txt", func(t *testing.T, dir string, list string) string {
+		return writeBreachRanges(t, dir, list, ".txt")
+	}},
+	{"range directory with CRLF", func(t *testing.T, dir string, list string) string {
+		return writeBreachRanges(t, dir, strings.ReplaceAll(list, "\n", "\r\n"), "")
+	}},
+}
+
+func writeBreachFile(t *testing.T, dir string, list string) string {
+	t.Helper()
+	path := filepath.Join(dir, "pwned.txt")
+	if err := os.WriteFile(path, []byte(list), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+// writeBreachRanges splits list into range files named after the first five
+// characters of each hash, with ext appended.
+func writeBreachRanges(t *testing.T, dir string, list string, ext string) string {
+	t.Helper()
+	ranges := map[string]string{}
+	for _, line := range strings.SplitAfter(list, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		ranges[line[:prefixLength]] += line[prefixLength:]
+	}
+	for prefix, lines := range ranges {
+		if err := os.WriteFile(filepath.Join(dir, prefix+ext), []byte(lines), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestBreachListCount(t *testing.T) {
+	for _, fixture := range breachFixtures {
+		list, err := os.ReadFile(fixture.file)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		for _, layout := range breachLayouts {
+			t.Run(fixture.hash+"/"+layout.name, func(t *testing.T) {
+				b, err := OpenBreachList(layout.write(t, t.TempDir(), string(list)), fixture.hash)
+				if err != nil {
+					t.Fatalf("OpenBreachList: %v", err)
+				}
+				for password, want := range breachCounts {
+					got, err := b.Count(password)
+					if err != nil || got != want {
+						t.Fatalf("Count(%q) = %d, %v, want %d, nil", password, got, err, want)
+					}
+				}
+				// The bisection has to reach both ends of the file.
+				for _, password := range []string{fixture.first, fixture.last} {
+					if got, err := b.Count(password); err != nil || got != breachCounts[password] {
+						t.Fatalf("Count(%q) = %d, %v, want %d, nil", password, got, err, breachCounts[password])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBreachListLineTooLong(t *testing.T) {
+	list, err := os.ReadFile("testdata/pwned-sha1.txt")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	// Put a line longer than maxLineLength in the middle, where the first
+	// probe lands.
+	lines := strings.SplitAfter(string(list), "\n")
+	long := "9" + strings.Repeat("0", 4*maxLineLength) + ":1\n"
+	lines = append(lines[:3], append([]string{long}, lines[3:]...)...)
+	b, err := OpenBreachList(writeBreachFile(t, t.TempDir(), strings.Join(lines, "")), HashSHA1)
+	if err != nil {
+		t.Fatalf("OpenBreachList: %v", err)
+	}
+	if _, err := b.Count("password"); err == nil {
+		t.Fatal("Count over a line longer than maxLineLength succeeded")
+	}
+}
+
+func TestOpenBreachListUnknownHash(t *testing.T) {
+	if _, err := OpenBreachList("testdata/pwned-sha1.txt", "md5"); !errors.Is(err, ErrUnknownHash) {
+		t.Fatalf("got %v, want ErrUnknownHash", err)
+	}
+}
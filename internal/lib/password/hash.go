@@ -0,0 +1,163 @@
+package password
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+var ErrUnknownFormat = errors.New("unknown password hash format")
+
+// Hasher hashes passwords into self-describing strings. Every Hasher verifies
+// hashes of every supported algorithm, so the algorithm or its parameters can
+// change while old hashes keep working until they are replaced.
+type Hasher interface {
+	Hash(password string) ([]byte, error)
+	Verify(hash []byte, password string) (bool, error)
+	// NeedsRehash reports whether hash was made with another algorithm or
+	// other parameters than Hash would use now.
+	NeedsRehash(hash []byte) bool
+}
+
+// Argon2Params are the argon2id cost parameters. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the OWASP recommendation of 19 MiB of memory and
+// two passes.
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var encoding = base64.RawStdEncoding
+
+// Argon2idHasher hashes into PHC strings such as
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>.
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+func NewArgon2idHasher(params Argon2Params) *Argon2idHasher {
+	return &Argon2idHasher{params: params}
+}
+
+func (h *Argon2idHasher) Hash(password string) ([]byte, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+	return []byte(fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		h.params.Memory, h.params.Iterations, h.params.Parallelism,
+		encoding.EncodeToString(salt), encoding.EncodeToString(key))), nil
+}
+
+func (h *Argon2idHasher) Verify(hash []byte, password string) (bool, error) {
+	return verify(hash, password)
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash []byte) bool {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		uint32(len(salt)) != h.params.SaltLength ||
+		uint32(len(key)) != h.params.KeyLength
+}
+
+// BcryptHasher hashes into the modular crypt format bcrypt uses, which PHC
+// strings extend.
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher returns a hasher with the given cost, or bcrypt's default
+// cost when it is below the minimum.
+func NewBcryptHasher(cost int) *BcryptHasher {
+	if cost < bcrypt.MinCost {
+		cost = bcrypt.DefaultCost
+	}
+	return &BcryptHasher{cost: cost}
+}
+
+func (h *BcryptHasher) Hash(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), h.cost)
+}
+
+func (h *BcryptHasher) Verify(hash []byte, password string) (bool, error) {
+	return verify(hash, password)
+}
+
+func (h *BcryptHasher) NeedsRehash(hash []byte) bool {
+	cost, err := bcrypt.Cost(hash)
+	return err != nil || cost != h.cost
+}
+
+// verify checks a password against a hash of any supported algorithm.
+func verify(hash []byte, password string) (bool, error) {
+	switch {
+	case bytes.HasPrefix(hash, []byte("$argon2id$")):
+		params, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(computed, key) == 1, nil
+	case bytes.HasPrefix(hash, []byte("$2a$")), bytes.HasPrefix(hash, []byte("$2b$")), bytes.HasPrefix(hash, []byte("$2y$")):
+		err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+		// bcrypt cannot have hashed a password longer than 72 bytes, so
+		// one cannot match.
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) || errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, ErrUnknownFormat
+}
+
+func parseArgon2id(hash []byte) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: argon2 version %q", ErrUnknownFormat, parts[2])
+	}
+	var params Argon2Params
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: argon2 parameters %q", ErrUnknownFormat, parts[3])
+	}
+	salt, err := encoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: argon2 salt", ErrUnknownFormat)
+	}
+	key, err := encoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, fmt.Errorf("%w: argon2 hash", ErrUnknownFormat)
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,41 @@
+package password
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	hashers := map[string]Hasher{
+		"argon2id": NewArgon2idHasher(DefaultArgon2Params),
+		"bcrypt":   NewBcryptHasher(4),
+	}
+	for name, hasher := range hashers {
+		t.Run(name, func(t *testing.T) {
+			hash, err := hasher.Hash("Corr3ct-Horse-Battery!")
+			if err != nil {
+				t.Fatalf("Hash: %v", err)
+			}
+			tests := []struct {
+				password string
+				want     bool
+			}{
+				{"Corr3ct-Horse-Battery!", true},
+				{"wrong", false},
+				{strings.Repeat("a", 100), false},
+			}
+			for _, tt := range tests {
+				ok, err := hasher.Verify(hash, tt.password)
+				if err != nil || ok != tt.want {
+					t.Fatalf("Verify(%.10q) = %v, %v, want %v, nil", tt.password, ok, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyUnknownFormat(t *testing.T) {
+	if _, err := verify([]byte("plain"), "plain"); err != ErrUnknownFormat {
+		t.Fatalf("got %v, want ErrUnknownFormat", err)
+	}
+}
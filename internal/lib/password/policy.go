@@ -0,0 +1,206 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules a password can break, reported in Violation.Rule.
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleLower     = "lowercase"
+	RuleUpper     = "uppercase"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleUserInfo  = "user_info"
+	RuleBlocklist = "blocklist"
+	RuleBreached  = "breached"
+)
+
+// BcryptMaxBytes is the longest password bcrypt hashes in full.
+const BcryptMaxBytes = 72
+
+// defaultMaxBytes bounds the work of hashing a password with argon2id.
+const defaultMaxBytes = 256
+
+// minUserInfoLength keeps short logins such as "al" from ruling out every
+// password containing them.
+const minUserInfoLength = 3
+
+//go:embed blocklist.txt
+var defaultBlocklist string
+
+// Policy describes the passwords users may choose. MinLength counts
+// characters, MaxBytes bytes of UTF-8; zero disables either. Blocklist holds
+// lower-cased passwords that are refused outright, Breaches, when set, known
+// breached passwords.
+type Policy struct {
+	MinLength     int
+	MaxBytes      int
+	RequireLower  bool
+	RequireUpper  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// ForbidUserInfo refuses passwords containing the login or the local
+	// part of the email address.
+	ForbidUserInfo bool
+	Blocklist      map[string]struct{}
+	Breaches       *BreachList
+}
+
+// Violation is one rule a password breaks. Message is a format string for
+// Args, kept apart so it can be translated.
+type Violation struct {
+	Rule    string
+	Message string
+	Args    []interface{}
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf(v.Message, v.Args...)
+}
+
+// PolicyError lists every rule a password breaks.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.String())
+	}
+	return "password " + strings.Join(messages, ", ")
+}
+
+// DefaultPolicy asks for eight to a few hundred characters that are neither a
+// common password nor the user's own name.
+func DefaultPolicy() Policy {
+	blocklist, _ := ReadBlocklist(strings.NewReader(defaultBlocklist))
+	return Policy{
+		MinLength:      8,
+		MaxBytes:       defaultMaxBytes,
+		ForbidUserInfo: true,
+		Blocklist:      blocklist,
+	}
+}
+
+// Check returns a *PolicyError when the password breaks any rule, or the
+// error reading the breach list. userInfo are the login and email address of
+// the user it is for.
+func (p Policy) Check(password string, userInfo ...string) error {
+	var violations []Violation
+
+	if p.MinLength > 0 && utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{
+			Rule: RuleMinLength, Message: "must be at least %d characters long", Args: []interface{}{p.MinLength},
+		})
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		violations = append(violations, Violation{
+			Rule: RuleMaxLength, Message: "must be at most %d bytes long", Args: []interface{}{p.MaxBytes},
+		})
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, Violation{Rule: RuleLower, Message: "must contain a lowercase letter"})
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, Violation{Rule: RuleUpper, Message: "must contain an uppercase letter"})
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, Violation{Rule: RuleDigit, Message: "must contain a digit"})
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, Violation{Rule: RuleSymbol, Message: "must contain a symbol"})
+	}
+
+	folded := strings.ToLower(password)
+	if p.ForbidUserInfo && containsUserInfo(folded, userInfo) {
+		violations = append(violations, Violation{Rule: RuleUserInfo, Message: "must not contain the login or email address"})
+	}
+	if _, ok := p.Blocklist[folded]; ok {
+		violations = append(violations, Violation{Rule: RuleBlocklist, Message: "is too common"})
+	}
+	if p.Breaches != nil {
+		count, err := p.Breaches.Count(password)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			violations = append(violations, Violation{Rule: RuleBreached, Message: "has appeared in a data breach"})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func containsUserInfo(folded string, userInfo []string) bool {
+	for _, info := range userInfo {
+		info = strings.ToLower(info)
+		if local, _, ok := strings.Cut(info, "@"); ok {
+			info = local
+		}
+		if utf8.RuneCountInString(info) >= minUserInfoLength && strings.Contains(folded, info) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadBlocklist reads a file of refused passwords, one per line, adding them
+// to the built-in list.
+func LoadBlocklist(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	blocklist, err := ReadBlocklist(io.MultiReader(strings.NewReader(defaultBlocklist+"\n"), f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return blocklist, nil
+}
+
+// ReadBlocklist reads refused passwords, one per line. Blank lines and lines
+// starting with # are skipped.
+func ReadBlocklist(r io.Reader) (map[string]struct{}, error) {
+	blocklist := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return blocklist, nil
+}
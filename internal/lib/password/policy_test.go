@@ -0,0 +1,158 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// violatedRules returns the rules err reports, in order, or nil when the
+// password passed.
+func violatedRules(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("got %v, want a *PolicyError", err)
+	}
+	rules := make([]string, 0, len(policyErr.Violations))
+	for _, v := range policyErr.Violations {
+		rules = append(rules, v.Rule)
+	}
+	return rules
+}
+
+func TestPolicyLength(t *testing.T) {
+	policy := Policy{MinLength: 8, MaxBytes: BcryptMaxBytes}
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"empty", "", []string{RuleMinLength}},
+		{"one short", "1234567", []string{RuleMinLength}},
+		{"min length", "12345678", nil},
+		{"min length counts characters", "пароль12", nil},
+		{"bcrypt limit", strings.Repeat("a", BcryptMaxBytes), nil},
+		{"past the bcrypt limit", strings.Repeat("a", BcryptMaxBytes+1), []string{RuleMaxLength}},
+		{"max length counts bytes", strings.Repeat("ж", BcryptMaxBytes/2+1), []string{RuleMaxLength}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := violatedRules(t, policy.Check(tt.password)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyCharacterClasses(t *testing.T) {
+	policy := Policy{RequireLower: true, RequireUpper: true, RequireDigit: true, RequireSymbol: true}
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		{"aB3$", nil},
+		{"AB3$", []string{RuleLower}},
+		{"ab3$", []string{RuleUpper}},
+		{"aB$$", []string{RuleDigit}},
+		{"aB3 ", []string{RuleSymbol}},
+		{"пАроль9!", nil},
+		{"", []string{RuleLower, RuleUpper, RuleDigit, RuleSymbol}},
+	}
+	for _, tt := range tests {
+		if got := violatedRules(t, policy.Check(tt.password)); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("Check(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyUserInfo(t *testing.T) {
+	policy := Policy{ForbidUserInfo: true}
+	tests := []struct {
+		password string
+		userInfo []string
+		want     []string
+	}{
+		{"my-alice-password", []string{"alice", "a.smith@example.com"}, []string{RuleUserInfo}},
+		{"MY-ALICE-PASSWORD", []string{"alice", "a.smith@example.com"}, []string{RuleUserInfo}},
+		{"hello-a.smith-1", []string{"alice", "a.smith@example.com"}, []string{RuleUserInfo}},
+		{"i-work-at-example.com", []string{"alice", "a.smith@example.com"}, nil},
+		{"albatross-albatross", []string{"al", "al@example.com"}, nil},
+		{"my-alice-password", nil, nil},
+	}
+	for _, tt := range tests {
+		if got := violatedRules(t, policy.Check(tt.password, tt.userInfo...)); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("Check(%q, %q) = %v, want %v", tt.password, tt.userInfo, got, tt.want)
+		}
+	}
+
+	if err := (Policy{}).Check("my-alice-password", "alice"); err != nil {
+		t.Fatalf("Check without ForbidUserInfo = %v, want nil", err)
+	}
+}
+
+func TestPolicyBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("# ours\n\n  Hunter2-Hunter2  \n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	blocklist, err := LoadBlocklist(path)
+	if err != nil {
+		t.Fatalf("LoadBlocklist: %v", err)
+	}
+	if _, ok := blocklist["# ours"]; ok {
+		t.Fatal("comment was read as a password")
+	}
+	policy := DefaultPolicy()
+	policy.Blocklist = blocklist
+
+	tests := []struct {
+		password string
+		want     []string
+	}{
+		{"administrator", []string{RuleBlocklist}},
+		{"AdMiNiStRaToR", []string{RuleBlocklist}},
+		{"hunter2-hunter2", []string{RuleBlocklist}},
+		{"administrator1", nil},
+	}
+	for _, tt := range tests {
+		if got := violatedRules(t, policy.Check(tt.password)); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("Check(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+
+	if got := violatedRules(t, DefaultPolicy().Check("hunter2-hunter2")); got != nil {
+		t.Fatalf("default policy refuses a password only on the loaded list: %v", got)
+	}
+}
+
+func TestPolicyErrorDetails(t *testing.T) {
+	policy := Policy{MinLength: 12, RequireDigit: true, ForbidUserInfo: true}
+	err := policy.Check("alice", "alice")
+
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("got %v, want a *PolicyError", err)
+	}
+	want := []Violation{
+		{Rule: RuleMinLength, Message: "must be at least %d characters long", Args: []interface{}{12}},
+		{Rule: RuleDigit, Message: "must contain a digit"},
+		{Rule: RuleUserInfo, Message: "must not contain the login or email address"},
+	}
+	if !reflect.DeepEqual(policyErr.Violations, want) {
+		t.Fatalf("violations = %+v, want %+v", policyErr.Violations, want)
+	}
+	if got := policyErr.Violations[0].String(); got != "must be at least 12 characters long" {
+		t.Fatalf("String() = %q", got)
+	}
+	const wantErr = "password must be at least 12 characters long, must contain a digit, must not contain the login or email address"
+	if err.Error() != wantErr {
+		t.Fatalf("Error() = %q, want %q", err.Error(), wantErr)
+	}
+}
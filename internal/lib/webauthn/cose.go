@@ -0,0 +1,110 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+)
+
+// COSE algorithm identifiers accepted for credentials, in order of preference.
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// COSE_Key labels, RFC 9053.
+const (
+	coseKty = 1
+	coseAlg = 3
+
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	coseN   = -1
+	coseE   = -2
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parsePublicKey decodes a COSE_Key and returns it with the bytes that follow
+// it.
+func parsePublicKey(data []byte) (publicKey, []byte, error) {
+	value, rest, err := decodeCBOR(data)
+	if err != nil {
+		return publicKey{}, nil, err
+	}
+	fields, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return publicKey{}, nil, ErrUnsupportedKey
+	}
+	kty, _ := fields[int64(coseKty)].(int64)
+	alg, _ := fields[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == coseKtyEC2 && alg == AlgES256:
+		crv, _ := fields[int64(coseCrv)].(int64)
+		x, _ := fields[int64(coseX)].([]byte)
+		y, _ := fields[int64(coseY)].([]byte)
+		if crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return publicKey{}, nil, ErrUnsupportedKey
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return publicKey{}, nil, ErrUnsupportedKey
+		}
+		return publicKey{alg: alg, key: key}, rest, nil
+	case kty == coseKtyOKP && alg == AlgEdDSA:
+		crv, _ := fields[int64(coseCrv)].(int64)
+		x, _ := fields[int64(coseX)].([]byte)
+		if crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, nil, ErrUnsupportedKey
+		}
+		return publicKey{alg: alg, key: ed25519.PublicKey(x)}, rest, nil
+	case kty == coseKtyRSA && alg == AlgRS256:
+		n, _ := fields[int64(coseN)].([]byte)
+		e, _ := fields[int64(coseE)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return publicKey{}, nil, ErrUnsupportedKey
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return publicKey{alg: alg, key: key}, rest, nil
+	default:
+		return publicKey{}, nil, ErrUnsupportedKey
+	}
+}
+
+func (k publicKey) verify(data, signature []byte) error {
+	if verifySignature(k.alg, k.key, data, signature) {
+		return nil
+	}
+	return ErrInvalidSignature
+}
+
+func verifySignature(alg int64, key crypto.PublicKey, data, signature []byte) bool {
+	digest := sha256.Sum256(data)
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		return alg == AlgES256 && pub.Curve == elliptic.P256() && ecdsa.VerifyASN1(pub, digest[:], signature)
+	case ed25519.PublicKey:
+		return alg == AlgEdDSA && ed25519.Verify(pub, data, signature)
+	case *rsa.PublicKey:
+		return alg == AlgRS256 && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil
+	default:
+		return false
+	}
+}
@@ -0,0 +1,207 @@
+// Package virtual is a software WebAuthn authenticator for exercising the
+// relying party code without a browser or security key.
+package virtual
+
+import (
+	"AuthGrpc/internal/lib/webauthn"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+var ErrNoCredential = errors.New("no matching credential")
+
+// Authenticator holds ES256 credentials for a single relying party.
+type Authenticator struct {
+	RPID   string
+	Origin string
+	// Format is the attestation format, "none" or "packed" (self attestation).
+	Format       string
+	UserVerified bool
+	// AAGUID identifies the authenticator model; zero by default.
+	AAGUID [16]byte
+
+	credentials map[string]*credential
+}
+
+type credential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+func New(rpID, origin string) *Authenticator {
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		Format:       "none",
+		UserVerified: true,
+		credentials:  make(map[string]*credential),
+	}
+}
+
+// Create performs navigator.credentials.create and returns the new
+// credential ID together with clientDataJSON and attestationObject.
+func (a *Authenticator) Create(challenge, userHandle []byte) (credentialID, clientDataJSON, attestationObject []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, nil, nil, err
+	}
+	cred := &credential{id: id, key: key, userHandle: userHandle}
+	a.credentials[string(id)] = cred
+
+	clientDataJSON, err = a.clientData(webauthn.TypeCreate, challenge)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	authData := a.authData(cred, 0x40)
+	authData = append(authData, a.AAGUID[:]...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(id)))
+	authData = append(authData, id...)
+	authData = append(authData, coseKey(&key.PublicKey)...)
+
+	statement := appendHead(nil, 5, 0)
+	if a.Format == "packed" {
+		signature, err := sign(key, authData, clientDataJSON)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		statement = appendHead(nil, 5, 2)
+		statement = appendText(statement, "alg")
+		statement = appendInt(statement, webauthn.AlgES256)
+		statement = appendText(statement, "sig")
+		statement = appendBytes(statement, signature)
+	}
+
+	attestationObject = appendHead(nil, 5, 3)
+	attestationObject = appendText(attestationObject, "fmt")
+	attestationObject = appendText(attestationObject, a.Format)
+	attestationObject = appendText(attestationObject, "attStmt")
+	attestationObject = append(attestationObject, statement...)
+	attestationObject = appendText(attestationObject, "authData")
+	attestationObject = appendBytes(attestationObject, authData)
+
+	return id, clientDataJSON, attestationObject, nil
+}
+
+// Get performs navigator.credentials.get. An empty allow list picks any
+// credential, as a discoverable credential login would.
+func (a *Authenticator) Get(challenge []byte, allow ...[]byte) (webauthn.Assertion, error) {
+	cred := a.find(allow)
+	if cred == nil {
+		return webauthn.Assertion{}, ErrNoCredential
+	}
+	cred.signCount++
+
+	clientDataJSON, err := a.clientData(webauthn.TypeGet, challenge)
+	if err != nil {
+		return webauthn.Assertion{}, err
+	}
+	authData := a.authData(cred, 0)
+	signature, err := sign(cred.key, authData, clientDataJSON)
+	if err != nil {
+		return webauthn.Assertion{}, err
+	}
+	return webauthn.Assertion{
+		CredentialID:      cred.id,
+		ClientDataJSON:    clientDataJSON,
+		AuthenticatorData: authData,
+		Signature:         signature,
+		UserHandle:        cred.userHandle,
+	}, nil
+}
+
+func (a *Authenticator) find(allow [][]byte) *credential {
+	for _, id := range allow {
+		if cred, ok := a.credentials[string(id)]; ok {
+			return cred
+		}
+	}
+	if len(allow) == 0 {
+		for _, cred := range a.credentials {
+			return cred
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge []byte) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (a *Authenticator) authData(cred *credential, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	flags |= 0x01
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, cred.signCount)
+}
+
+func sign(key *ecdsa.PrivateKey, authData, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	return ecdsa.SignASN1(rand.Reader, key, digest[:])
+}
+
+func coseKey(pub *ecdsa.PublicKey) []byte {
+	key := appendHead(nil, 5, 5)
+	key = appendInt(key, 1)
+	key = appendInt(key, 2)
+	key = appendInt(key, 3)
+	key = appendInt(key, webauthn.AlgES256)
+	key = appendInt(key, -1)
+	key = appendInt(key, 1)
+	key = appendInt(key, -2)
+	key = appendBytes(key, pub.X.FillBytes(make([]byte, 32)))
+	key = appendInt(key, -3)
+	key = appendBytes(key, pub.Y.FillBytes(make([]byte, 32)))
+	return key
+}
+
+func appendHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major<<5|byte(n))
+	case n <= 0xff:
+		return append(b, major<<5|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major<<5|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major<<5|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, major<<5|27), n)
+	}
+}
+
+func appendInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendHead(b, 1, uint64(-1-v))
+	}
+	return appendHead(b, 0, uint64(v))
+}
+
+func appendBytes(b []byte, v []byte) []byte {
+	return append(appendHead(b, 2, uint64(len(v))), v...)
+}
+
+func appendText(b []byte, v string) []byte {
+	return append(appendHead(b, 3, uint64(len(v))), v...)
+}
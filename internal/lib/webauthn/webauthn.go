@@ -0,0 +1,402 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+)
+
+// Ceremony types carried in the client data.
+const (
+	TypeCreate = "webauthn.create"
+	TypeGet    = "webauthn.get"
+)
+
+// User verification requirements, as in the WebAuthn options.
+const (
+	VerificationRequired    = "required"
+	VerificationPreferred   = "preferred"
+	VerificationDiscouraged = "discouraged"
+)
+
+const (
+	challengeSize  = 32
+	defaultTimeout = 5 * time.Minute
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+	flagExtensions   = 0x80
+)
+
+var (
+	ErrInvalidClientData      = errors.New("invalid client data")
+	ErrChallengeMismatch      = errors.New("challenge mismatch")
+	ErrOriginMismatch         = errors.New("origin not allowed")
+	ErrInvalidAuthData        = errors.New("invalid authenticator data")
+	ErrRPIDMismatch           = errors.New("rp id mismatch")
+	ErrUserNotPresent         = errors.New("user not present")
+	ErrUserNotVerified        = errors.New("user not verified")
+	ErrInvalidAttestation     = errors.New("invalid attestation")
+	ErrUnsupportedAttestation = errors.New("unsupported attestation format")
+	ErrUnsupportedKey         = errors.New("unsupported credential key")
+	ErrInvalidSignature       = errors.New("invalid signature")
+	// ErrSignCountRegression means the authenticator's counter did not grow,
+	// which is a sign the credential was cloned.
+	ErrSignCountRegression = errors.New("sign count did not increase")
+)
+
+var encoding = base64.RawURLEncoding
+
+// Config describes the relying party.
+type Config struct {
+	RPID   string
+	RPName string
+	// Origins are the web origins allowed to run ceremonies, e.g.
+	// "https://example.com".
+	Origins []string
+	// UserVerification is required, preferred or discouraged; only required
+	// is enforced. Empty means preferred.
+	UserVerification string
+	Timeout          time.Duration
+}
+
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type User struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is the publicKey argument of navigator.credentials.create
+// with binary fields base64url-encoded.
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RelyingParty           `json:"rp"`
+	User                   User                   `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is the publicKey argument of navigator.credentials.get with
+// binary fields base64url-encoded.
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// ClientData is the part of CollectedClientData the relying party checks.
+type ClientData struct {
+	Type      string
+	Challenge []byte
+	Origin    string
+}
+
+// Credential is a public key credential accepted by VerifyRegistration.
+type Credential struct {
+	ID []byte
+	// PublicKey is the credential public key as a COSE_Key.
+	PublicKey         []byte
+	SignCount         uint32
+	AAGUID            []byte
+	AttestationFormat string
+}
+
+// Assertion is what navigator.credentials.get returns.
+type Assertion struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	UserHandle        []byte
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// NewChallenge returns a random ceremony challenge.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (c Config) TimeoutOrDefault() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultTimeout
+}
+
+func (c Config) userVerification() string {
+	if c.UserVerification == "" {
+		return VerificationPreferred
+	}
+	return c.UserVerification
+}
+
+// NewCreationOptions builds the options for registering a credential for user.
+// exclude lists the IDs of the user's existing credentials so an
+// authenticator is not registered twice.
+func (c Config) NewCreationOptions(challenge []byte, user User, exclude [][]byte) CreationOptions {
+	return CreationOptions{
+		Challenge: encoding.EncodeToString(challenge),
+		RP:        RelyingParty{ID: c.RPID, Name: c.RPName},
+		User:      user,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            c.TimeoutOrDefault().Milliseconds(),
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: c.userVerification(),
+		},
+		Attestation: "none",
+	}
+}
+
+// NewRequestOptions builds the options for an assertion. An empty allow list
+// lets the authenticator offer any discoverable credential.
+func (c Config) NewRequestOptions(challenge []byte, allow [][]byte) RequestOptions {
+	return RequestOptions{
+		Challenge:        encoding.EncodeToString(challenge),
+		Timeout:          c.TimeoutOrDefault().Milliseconds(),
+		RPID:             c.RPID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: c.userVerification(),
+	}
+}
+
+// EncodeUserID returns the base64url form of a user handle used in
+// CreationOptions.
+func EncodeUserID(userHandle []byte) string {
+	return encoding.EncodeToString(userHandle)
+}
+
+// ParseClientData decodes clientDataJSON without checking it, so the caller
+// can find the ceremony its challenge belongs to.
+func ParseClientData(clientDataJSON []byte) (ClientData, error) {
+	var raw struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(clientDataJSON, &raw); err != nil {
+		return ClientData{}, ErrInvalidClientData
+	}
+	challenge, err := encoding.DecodeString(raw.Challenge)
+	if err != nil {
+		return ClientData{}, ErrInvalidClientData
+	}
+	return ClientData{Type: raw.Type, Challenge: challenge, Origin: raw.Origin}, nil
+}
+
+// VerifyRegistration checks the response of navigator.credentials.create to
+// a ceremony started with challenge and returns the new credential.
+func (c Config) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte) (Credential, error) {
+	if err := c.verifyClientData(TypeCreate, challenge, clientDataJSON); err != nil {
+		return Credential{}, err
+	}
+
+	value, rest, err := decodeCBOR(attestationObject)
+	if err != nil || len(rest) != 0 {
+		return Credential{}, ErrInvalidAttestation
+	}
+	object, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return Credential{}, ErrInvalidAttestation
+	}
+	format, _ := object["fmt"].(string)
+	statement, _ := object["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := object["authData"].([]byte)
+	if statement == nil || rawAuthData == nil {
+		return Credential{}, ErrInvalidAttestation
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+	if err := c.verifyAuthenticatorData(authData); err != nil {
+		return Credential{}, err
+	}
+	if authData.flags&flagAttestedData == 0 {
+		return Credential{}, ErrInvalidAuthData
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	if err := verifyAttestation(format, statement, rawAuthData, clientDataHash[:], authData); err != nil {
+		return Credential{}, err
+	}
+
+	return Credential{
+		ID:                authData.credentialID,
+		PublicKey:         authData.publicKey,
+		SignCount:         authData.signCount,
+		AAGUID:            authData.aaguid,
+		AttestationFormat: format,
+	}, nil
+}
+
+// VerifyAssertion checks the response of navigator.credentials.get to a
+// ceremony started with challenge against a stored credential public key and
+// sign count. It returns the new sign count to store and whether the
+// authenticator verified the user, not only their presence.
+func (c Config) VerifyAssertion(challenge, credentialPublicKey []byte, signCount uint32, assertion Assertion) (uint32, bool, error) {
+	if err := c.verifyClientData(TypeGet, challenge, assertion.ClientDataJSON); err != nil {
+		return 0, false, err
+	}
+
+	authData, err := parseAuthenticatorData(assertion.AuthenticatorData)
+	if err != nil {
+		return 0, false, err
+	}
+	if err := c.verifyAuthenticatorData(authData); err != nil {
+		return 0, false, err
+	}
+
+	key, rest, err := parsePublicKey(credentialPublicKey)
+	if err != nil || len(rest) != 0 {
+		return 0, false, ErrUnsupportedKey
+	}
+	clientDataHash := sha256.Sum256(assertion.ClientDataJSON)
+	signed := append(append([]byte(nil), assertion.AuthenticatorData...), clientDataHash[:]...)
+	if err := key.verify(signed, assertion.Signature); err != nil {
+		return 0, false, err
+	}
+
+	// Authenticators without a counter always report zero.
+	if (authData.signCount != 0 || signCount != 0) && authData.signCount <= signCount {
+		return 0, false, ErrSignCountRegression
+	}
+	return authData.signCount, authData.flags&flagUserVerified != 0, nil
+}
+
+func (c Config) verifyClientData(ceremony string, challenge, clientDataJSON []byte) error {
+	clientData, err := ParseClientData(clientDataJSON)
+	if err != nil {
+		return err
+	}
+	if clientData.Type != ceremony {
+		return ErrInvalidClientData
+	}
+	if !bytes.Equal(clientData.Challenge, challenge) {
+		return ErrChallengeMismatch
+	}
+	if !slices.Contains(c.Origins, clientData.Origin) {
+		return ErrOriginMismatch
+	}
+	return nil
+}
+
+func (c Config) verifyAuthenticatorData(authData authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return ErrRPIDMismatch
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+	if c.UserVerification == VerificationRequired && authData.flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+func parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	if len(data) < 37 {
+		return authenticatorData{}, ErrInvalidAuthData
+	}
+	authData := authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.flags&flagAttestedData != 0 {
+		if len(rest) < 18 {
+			return authenticatorData{}, ErrInvalidAuthData
+		}
+		authData.aaguid = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return authenticatorData{}, ErrInvalidAuthData
+		}
+		authData.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, afterKey, err := parsePublicKey(rest)
+		if err != nil {
+			return authenticatorData{}, err
+		}
+		authData.publicKey = rest[:len(rest)-len(afterKey)]
+		rest = afterKey
+	}
+
+	if authData.flags&flagExtensions != 0 {
+		extensions, afterExtensions, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, ErrInvalidAuthData
+		}
+		if _, ok := extensions.(map[interface{}]interface{}); !ok {
+			return authenticatorData{}, ErrInvalidAuthData
+		}
+		rest = afterExtensions
+	}
+	if len(rest) != 0 {
+		return authenticatorData{}, ErrInvalidAuthData
+	}
+	return authData, nil
+}
+
+func descriptors(ids [][]byte) []CredentialDescriptor {
+	var result []CredentialDescriptor
+	for _, id := range ids {
+		result = append(result, CredentialDescriptor{Type: "public-key", ID: encoding.EncodeToString(id)})
+	}
+	return result
+}
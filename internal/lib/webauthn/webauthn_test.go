@@ -0,0 +1,201 @@
+package webauthn_test
+
+import (
+	"AuthGrpc/internal/lib/webauthn"
+	"AuthGrpc/internal/lib/webauthn/virtual"
+	"errors"
+	"testing"
+)
+
+const (
+	rpID   = "example.com"
+	origin = "https://example.com"
+)
+
+var config = webauthn.Config{
+	RPID:             rpID,
+	RPName:           "Example",
+	Origins:          []string{origin},
+	UserVerification: webauthn.VerificationRequired,
+}
+
+func challenge(t *testing.T) []byte {
+	t.Helper()
+	c, err := webauthn.NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge: %v", err)
+	}
+	return c
+}
+
+// register creates a credential on the authenticator and verifies it.
+func register(t *testing.T, authenticator *virtual.Authenticator) webauthn.Credential {
+	t.Helper()
+	c := challenge(t)
+	id, clientDataJSON, attestationObject, err := authenticator.Create(c, []byte("user-1"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	credential, err := config.VerifyRegistration(c, clientDataJSON, attestationObject)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	if string(credential.ID) != string(id) {
+		t.Fatalf("credential ID %x, want %x", credential.ID, id)
+	}
+	return credential
+}
+
+func TestRegistration(t *testing.T) {
+	for _, format := range []string{"none", "packed"} {
+		t.Run(format, func(t *testing.T) {
+			authenticator := virtual.New(rpID, origin)
+			authenticator.Format = format
+			credential := register(t, authenticator)
+			if credential.AttestationFormat != format {
+				t.Fatalf("attestation format %q, want %q", credential.AttestationFormat, format)
+			}
+		})
+	}
+}
+
+func TestRegistrationRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *virtual.Authenticator)
+		want   error
+	}{
+		{"wrong origin", func(a *virtual.Authenticator) { a.Origin = "https://evil.example" }, webauthn.ErrOriginMismatch},
+		{"wrong rp id", func(a *virtual.Authenticator) { a.RPID = "evil.example" }, webauthn.ErrRPIDMismatch},
+		{"user not verified", func(a *virtual.Authenticator) { a.UserVerified = false }, webauthn.ErrUserNotVerified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := virtual.New(rpID, origin)
+			tt.modify(authenticator)
+			c := challenge(t)
+			_, clientDataJSON, attestationObject, err := authenticator.Create(c, []byte("user-1"))
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if _, err := config.VerifyRegistration(c, clientDataJSON, attestationObject); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("other challenge", func(t *testing.T) {
+		authenticator := virtual.New(rpID, origin)
+		_, clientDataJSON, attestationObject, err := authenticator.Create(challenge(t), []byte("user-1"))
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if _, err := config.VerifyRegistration(challenge(t), clientDataJSON, attestationObject); !errors.Is(err, webauthn.ErrChallengeMismatch) {
+			t.Fatalf("got %v, want ErrChallengeMismatch", err)
+		}
+	})
+}
+
+func TestAssertion(t *testing.T) {
+	authenticator := virtual.New(rpID, origin)
+	credential := register(t, authenticator)
+
+	signCount := credential.SignCount
+	for i := 0; i < 2; i++ {
+		c := challenge(t)
+		assertion, err := authenticator.Get(c, credential.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		next, _, err := config.VerifyAssertion(c, credential.PublicKey, signCount, assertion)
+		if err != nil {
+			t.Fatalf("VerifyAssertion: %v", err)
+		}
+		if next <= signCount {
+			t.Fatalf("sign count %d did not grow from %d", next, signCount)
+		}
+		signCount = next
+	}
+}
+
+func TestAssertionReportsUserVerification(t *testing.T) {
+	preferred := config
+	preferred.UserVerification = webauthn.VerificationPreferred
+	for _, verified := range []bool{true, false} {
+		authenticator := virtual.New(rpID, origin)
+		credential := register(t, authenticator)
+		authenticator.UserVerified = verified
+		c := challenge(t)
+		assertion, err := authenticator.Get(c, credential.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		_, userVerified, err := preferred.VerifyAssertion(c, credential.PublicKey, credential.SignCount, assertion)
+		if err != nil {
+			t.Fatalf("VerifyAssertion: %v", err)
+		}
+		if userVerified != verified {
+			t.Fatalf("user verified = %v, want %v", userVerified, verified)
+		}
+	}
+}
+
+func TestAssertionRejectsSignCountRegression(t *testing.T) {
+	authenticator := virtual.New(rpID, origin)
+	credential := register(t, authenticator)
+
+	c := challenge(t)
+	assertion, err := authenticator.Get(c, credential.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	signCount, _, err := config.VerifyAssertion(c, credential.PublicKey, credential.SignCount, assertion)
+	if err != nil {
+		t.Fatalf("VerifyAssertion: %v", err)
+	}
+	// A clone replaying the same counter is caught.
+	if _, _, err := config.VerifyAssertion(c, credential.PublicKey, signCount, assertion); !errors.Is(err, webauthn.ErrSignCountRegression) {
+		t.Fatalf("got %v, want ErrSignCountRegression", err)
+	}
+}
+
+func TestAssertionRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(a *virtual.Authenticator)
+		want   error
+	}{
+		{"wrong origin", func(a *virtual.Authenticator) { a.Origin = "https://evil.example" }, webauthn.ErrOriginMismatch},
+		{"wrong rp id", func(a *virtual.Authenticator) { a.RPID = "evil.example" }, webauthn.ErrRPIDMismatch},
+		{"user not verified", func(a *virtual.Authenticator) { a.UserVerified = false }, webauthn.ErrUserNotVerified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := virtual.New(rpID, origin)
+			credential := register(t, authenticator)
+			tt.modify(authenticator)
+			c := challenge(t)
+			assertion, err := authenticator.Get(c, credential.ID)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if _, _, err := config.VerifyAssertion(c, credential.PublicKey, credential.SignCount, assertion); !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("other key", func(t *testing.T) {
+		authenticator := virtual.New(rpID, origin)
+		credential := register(t, authenticator)
+		other := register(t, virtual.New(rpID, origin))
+		c := challenge(t)
+		assertion, err := authenticator.Get(c, credential.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if _, _, err := config.VerifyAssertion(c, other.PublicKey, credential.SignCount, assertion); !errors.Is(err, webauthn.ErrInvalidSignature) {
+			t.Fatalf("got %v, want ErrInvalidSignature", err)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package i18n
+
+import "context"
+
+type contextKey struct{}
+
+type localizer struct {
+	catalog *Catalog
+	locale  string
+}
+
+// NewContext returns a context carrying the catalog and the locale the
+// request asked for. The locale may be empty when the request did not say.
+func NewContext(ctx context.Context, catalog *Catalog, locale string) context.Context {
+	return context.WithValue(ctx, contextKey{}, localizer{catalog: catalog, locale: locale})
+}
+
+// LocaleFromContext returns the locale the request asked for, or "".
+func LocaleFromContext(ctx context.Context) string {
+	l, _ := ctx.Value(contextKey{}).(localizer)
+	return l.locale
+}
+
+// T translates msg into the request's locale, returning it unchanged when
+// the context carries no catalog.
+func T(ctx context.Context, msg string) string {
+	l, ok := ctx.Value(contextKey{}).(localizer)
+	if !ok {
+		return msg
+	}
+	return l.catalog.Translate(l.catalog.Match(l.locale), msg)
+}
@@ -0,0 +1,316 @@
+package i18n
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template files in a locale directory. A notification named name consists of
+// name.subject.tmpl, name.txt.tmpl and name.html.tmpl; only the text body is
+// required. messages.yaml maps English messages to their translation.
+const (
+	messagesFile  = "messages.yaml"
+	subjectSuffix = ".subject.tmpl"
+	textSuffix    = ".txt.tmpl"
+	htmlSuffix    = ".html.tmpl"
+)
+
+var ErrUnknownTemplate = errors.New("unknown template")
+
+//go:embed templates
+var defaults embed.FS
+
+// Catalog holds the messages and notification templates of every locale.
+type Catalog struct {
+	defaultLocale string
+	locales       map[string]*locale
+}
+
+type locale struct {
+	messages map[string]string
+	subjects map[string]*texttemplate.Template
+	texts    map[string]*texttemplate.Template
+	htmls    map[string]*htmltemplate.Template
+}
+
+// Rendered is a notification rendered for one locale. HTML is empty when the
+// notification has no HTML body.
+type Rendered struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Load reads the built-in locales and then the locale directories in dir, if
+// any, whose files replace the built-in ones of the same name.
+func Load(dir string, defaultLocale string) (*Catalog, error) {
+	c := &Catalog{
+		defaultLocale: normalize(defaultLocale),
+		locales:       make(map[string]*locale),
+	}
+
+	builtIn, err := fs.Sub(defaults, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.load(builtIn); err != nil {
+		return nil, fmt.Errorf("built-in templates: %w", err)
+	}
+	if dir != "" {
+		if err := c.load(os.DirFS(dir)); err != nil {
+			return nil, fmt.Errorf("templates in %s: %w", dir, err)
+		}
+	}
+
+	if _, ok := c.locales[c.defaultLocale]; !ok {
+		return nil, fmt.Errorf("no templates for default locale %q", defaultLocale)
+	}
+	return c, nil
+}
+
+// MustLoad is Load for the built-in templates only, which cannot fail.
+func MustLoad(defaultLocale string) *Catalog {
+	c, err := Load("", defaultLocale)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (c *Catalog) DefaultLocale() string {
+	return c.defaultLocale
+}
+
+// Supports reports whether Match would pick tag itself or its base language
+// rather than falling back to the default locale.
+func (c *Catalog) Supports(tag string) bool {
+	_, ok := c.lookup(tag)
+	return ok
+}
+
+// Match returns the first of candidates the catalog supports, trying each
+// one's base language too, or the default locale. Empty candidates are
+// skipped.
+func (c *Catalog) Match(candidates ...string) string {
+	for _, candidate := range candidates {
+		if name, ok := c.lookup(candidate); ok {
+			return name
+		}
+	}
+	return c.defaultLocale
+}
+
+// Translate returns msg in the given locale, or msg itself when there is no
+// translation.
+func (c *Catalog) Translate(tag string, msg string) string {
+	for _, l := range c.chain(tag) {
+		if translated, ok := l.messages[msg]; ok {
+			return translated
+		}
+	}
+	return msg
+}
+
+// Render executes the notification templates called name for the locale,
+// taking each part from the default locale when the locale lacks it.
+func (c *Catalog) Render(tag string, name string, data interface{}) (Rendered, error) {
+	var (
+		rendered Rendered
+		found    bool
+	)
+	for _, l := range c.chain(tag) {
+		if t, ok := l.texts[name]; ok {
+			text, err := executeText(t, data)
+			if err != nil {
+				return Rendered{}, err
+			}
+			rendered.Text, found = text, true
+			break
+		}
+	}
+	if !found {
+		return Rendered{}, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	for _, l := range c.chain(tag) {
+		if t, ok := l.subjects[name]; ok {
+			subject, err := executeText(t, data)
+			if err != nil {
+				return Rendered{}, err
+			}
+			rendered.Subject = strings.TrimSpace(subject)
+			break
+		}
+	}
+	for _, l := range c.chain(tag) {
+		if t, ok := l.htmls[name]; ok {
+			var b bytes.Buffer
+			if err := t.Execute(&b, data); err != nil {
+				return Rendered{}, err
+			}
+			rendered.HTML = b.String()
+			break
+		}
+	}
+	return rendered, nil
+}
+
+func (c *Catalog) lookup(tag string) (string, bool) {
+	tag = normalize(tag)
+	if tag == "" {
+		return "", false
+	}
+	if _, ok := c.locales[tag]; ok {
+		return tag, true
+	}
+	if base, _, ok := strings.Cut(tag, "-"); ok {
+		if _, ok := c.locales[base]; ok {
+			return base, true
+		}
+	}
+	return "", false
+}
+
+// chain lists the locales to look a message up in, most specific first.
+func (c *Catalog) chain(tag string) []*locale {
+	var chain []*locale
+	if name, ok := c.lookup(tag); ok {
+		chain = append(chain, c.locales[name])
+	}
+	if name, ok := c.lookup(tag); !ok || name != c.defaultLocale {
+		chain = append(chain, c.locales[c.defaultLocale])
+	}
+	return chain
+}
+
+func (c *Catalog) load(fsys fs.FS) error {
+	dirs, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		name := normalize(dir.Name())
+		l, ok := c.locales[name]
+		if !ok {
+			l = &locale{
+				messages: make(map[string]string),
+				subjects: make(map[string]*texttemplate.Template),
+				texts:    make(map[string]*texttemplate.Template),
+				htmls:    make(map[string]*htmltemplate.Template),
+			}
+			c.locales[name] = l
+		}
+		if err := l.load(fsys, dir.Name()); err != nil {
+			return fmt.Errorf("%s: %w", dir.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (l *locale) load(fsys fs.FS, dir string) error {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		filename := file.Name()
+		data, err := fs.ReadFile(fsys, path.Join(dir, filename))
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case filename == messagesFile:
+			var messages map[string]string
+			if err := yaml.Unmarshal(data, &messages); err != nil {
+				return fmt.Errorf("%s: %w", filename, err)
+			}
+			for msg, translated := range messages {
+				l.messages[msg] = translated
+			}
+		case strings.HasSuffix(filename, subjectSuffix):
+			t, err := texttemplate.New(filename).Parse(string(data))
+			if err != nil {
+				return err
+			}
+			l.subjects[strings.TrimSuffix(filename, subjectSuffix)] = t
+		case strings.HasSuffix(filename, textSuffix):
+			t, err := texttemplate.New(filename).Parse(string(data))
+			if err != nil {
+				return err
+			}
+			l.texts[strings.TrimSuffix(filename, textSuffix)] = t
+		case strings.HasSuffix(filename, htmlSuffix):
+			t, err := htmltemplate.New(filename).Parse(string(data))
+			if err != nil {
+				return err
+			}
+			l.htmls[strings.TrimSuffix(filename, htmlSuffix)] = t
+		}
+	}
+	return nil
+}
+
+func executeText(t *texttemplate.Template, data interface{}) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ParseAcceptLanguage returns the language tags of an Accept-Language header
+// ordered by preference.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	result := make([]string, 0, len(tags))
+	for _, t := range tags {
+		result = append(result, t.tag)
+	}
+	return result
+}
+
+func normalize(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
@@ -0,0 +1,100 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCatalogMatch(t *testing.T) {
+	c := MustLoad("en")
+	tests := []struct {
+		name       string
+		candidates []string
+		want       string
+	}{
+		{"exact", []string{"ru"}, "ru"},
+		{"region falls back to the base language", []string{"ru-RU"}, "ru"},
+		{"case and underscores", []string{"RU_ru"}, "ru"},
+		{"first supported wins", []string{"fr", "ru", "en"}, "ru"},
+		{"empty candidates are skipped", []string{"", "ru"}, "ru"},
+		{"unsupported", []string{"fr", "de-DE"}, "en"},
+		{"no candidates", nil, "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Match(tt.candidates...); got != tt.want {
+				t.Fatalf("Match(%q) = %q, want %q", tt.candidates, got, tt.want)
+			}
+		})
+	}
+
+	if got := MustLoad("ru").Match("fr"); got != "ru" {
+		t.Fatalf("Match with default locale ru = %q, want ru", got)
+	}
+}
+
+func TestCatalogTranslate(t *testing.T) {
+	c := MustLoad("en")
+	if got := c.Translate("ru-RU", "invalid reset token"); got != "недействительный токен сброса пароля" {
+		t.Fatalf("Translate(ru-RU) = %q", got)
+	}
+	if got := c.Translate("ru", "no such message"); got != "no such message" {
+		t.Fatalf("Translate of an unknown message = %q", got)
+	}
+	if got := c.Translate("fr", "invalid reset token"); got != "invalid reset token" {
+		t.Fatalf("Translate(fr) = %q", got)
+	}
+}
+
+func TestLoadOverridesBuiltInTemplates(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"en/phone_code.txt.tmpl": "Code: {{.Code}}",
+		"de/messages.yaml":       `"internal error": "interner Fehler"`,
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	c, err := Load(dir, "en")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	rendered, err := c.Render("de", "phone_code", map[string]string{"Code": "123456"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if rendered.Text != "Code: 123456" {
+		t.Fatalf("Render(de) took %q, want the overridden English template", rendered.Text)
+	}
+	if got := c.Translate("de", "internal error"); got != "interner Fehler" {
+		t.Fatalf("Translate(de) = %q", got)
+	}
+	if _, err := c.Render("en", "no_such_template", nil); err == nil {
+		t.Fatal("Render of an unknown template succeeded")
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"ru-RU,ru;q=0.9,en-US;q=0.8,en;q=0.7", []string{"ru-RU", "ru", "en-US", "en"}},
+		{"en;q=0.5, ru", []string{"ru", "en"}},
+		{"fr;q=0, *, de;q=bad, ru", []string{"ru"}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		if got := ParseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("ParseAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileSender writes messages to a file or stdout instead of delivering them.
+// It is meant for development.
+type FileSender struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewFileSender appends messages to the file at path, or writes them to
+// stdout when path is empty.
+func NewFileSender(path string) (*FileSender, error) {
+	if path == "" {
+		return NewWriterSender(os.Stdout), nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriterSender(f), nil
+}
+
+func NewWriterSender(w io.Writer) *FileSender {
+	return &FileSender{w: w}
+}
+
+func (s *FileSender) Send(_ context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "--- %s %s to %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339), msg.Channel, msg.To, msg.Subject, msg.Body)
+	return err
+}
@@ -0,0 +1,42 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type Channel string
+
+const (
+	Email Channel = "email"
+	SMS   Channel = "sms"
+)
+
+var ErrUnsupportedChannel = errors.New("unsupported channel")
+
+// Message is a notification for one recipient. To is an email address or a
+// phone number depending on Channel; Subject and HTML are ignored for SMS.
+// Body is plain text; HTML, when set, is sent alongside it as an alternative.
+type Message struct {
+	Channel Channel
+	To      string
+	Subject string
+	Body    string
+	HTML    string
+}
+
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Mux sends every message with the notifier registered for its channel.
+type Mux map[Channel]Notifier
+
+func (m Mux) Send(ctx context.Context, msg Message) error {
+	n, ok := m[msg.Channel]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedChannel, msg.Channel)
+	}
+	return n.Send(ctx, msg)
+}
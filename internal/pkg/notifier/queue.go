@@ -0,0 +1,103 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultQueueSize    = 100
+	defaultMaxAttempts  = 5
+	defaultRetryBackoff = 2 * time.Second
+	sendTimeout         = 30 * time.Second
+)
+
+var ErrQueueFull = errors.New("notification queue is full")
+
+// Queue delivers messages in the background, retrying failed deliveries with
+// exponential backoff. Send only enqueues; Run does the delivery.
+type Queue struct {
+	log          *slog.Logger
+	next         Notifier
+	messages     chan Message
+	maxAttempts  int
+	retryBackoff time.Duration
+}
+
+func NewQueue(log *slog.Logger, next Notifier, size int, maxAttempts int, retryBackoff time.Duration) *Queue {
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	if retryBackoff <= 0 {
+		retryBackoff = defaultRetryBackoff
+	}
+	return &Queue{
+		log:          log,
+		next:         next,
+		messages:     make(chan Message, size),
+		maxAttempts:  maxAttempts,
+		retryBackoff: retryBackoff,
+	}
+}
+
+func (q *Queue) Send(_ context.Context, msg Message) error {
+	select {
+	case q.messages <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run delivers queued messages until ctx is done. It may be started in
+// several goroutines to deliver in parallel.
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			if pending := len(q.messages); pending > 0 {
+				q.log.Warn("notification queue stopped with undelivered messages", slog.Int("pending", pending))
+			}
+			return
+		case msg := <-q.messages:
+			q.deliver(ctx, msg)
+		}
+	}
+}
+
+func (q *Queue) deliver(ctx context.Context, msg Message) {
+	backoff := q.retryBackoff
+	for attempt := 1; ; attempt++ {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := q.next.Send(sendCtx, msg)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt >= q.maxAttempts || errors.Is(err, ErrUnsupportedChannel) {
+			q.log.Error("failed to deliver notification",
+				slog.String("channel", string(msg.Channel)),
+				slog.Int("attempts", attempt),
+				slog.String("error", err.Error()),
+			)
+			return
+		}
+		q.log.Warn("notification delivery failed, retrying",
+			slog.String("channel", string(msg.Channel)),
+			slog.Int("attempt", attempt),
+			slog.String("error", err.Error()),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
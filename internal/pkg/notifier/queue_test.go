@@ -0,0 +1,123 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// flakyNotifier returns err until it has failed failures times, and reports
+// the time of every send on calls.
+type flakyNotifier struct {
+	failures int
+	err      error
+	calls    chan time.Time
+}
+
+func (n *flakyNotifier) Send(_ context.Context, _ Message) error {
+	n.calls <- time.Now()
+	if n.failures > 0 {
+		n.failures--
+		return n.err
+	}
+	return nil
+}
+
+func runQueue(t *testing.T, q *Queue) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitCalls returns the times of the next n sends.
+func waitCalls(t *testing.T, calls <-chan time.Time, n int) []time.Time {
+	t.Helper()
+	times := make([]time.Time, 0, n)
+	for len(times) < n {
+		select {
+		case at := <-calls:
+			times = append(times, at)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d sends, want %d", len(times), n)
+		}
+	}
+	return times
+}
+
+// expectNoCalls fails if another send happens within wait.
+func expectNoCalls(t *testing.T, calls <-chan time.Time, wait time.Duration) {
+	t.Helper()
+	select {
+	case <-calls:
+		t.Fatal("unexpected send")
+	case <-time.After(wait):
+	}
+}
+
+var discardLog = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestQueueRetriesWithBackoff(t *testing.T) {
+	const backoff = 20 * time.Millisecond
+	next := &flakyNotifier{failures: 2, err: errors.New("relay down"), calls: make(chan time.Time, 10)}
+	q := NewQueue(discardLog, next, 10, 5, backoff)
+	runQueue(t, q)
+
+	if err := q.Send(context.Background(), Message{Channel: Email, To: "alice@example.com"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	times := waitCalls(t, next.calls, 3)
+	// The wait doubles after every failure.
+	for i, want := range []time.Duration{backoff, 2 * backoff} {
+		if got := times[i+1].Sub(times[i]); got < want {
+			t.Fatalf("retry %d after %v, want at least %v", i+1, got, want)
+		}
+	}
+	expectNoCalls(t, next.calls, 4*backoff)
+}
+
+func TestQueueGivesUpAfterMaxAttempts(t *testing.T) {
+	next := &flakyNotifier{failures: 100, err: errors.New("relay down"), calls: make(chan time.Time, 10)}
+	q := NewQueue(discardLog, next, 10, 3, time.Millisecond)
+	runQueue(t, q)
+
+	if err := q.Send(context.Background(), Message{Channel: Email, To: "alice@example.com"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitCalls(t, next.calls, 3)
+	expectNoCalls(t, next.calls, 50*time.Millisecond)
+}
+
+func TestQueueDoesNotRetryUnsupportedChannels(t *testing.T) {
+	next := &flakyNotifier{failures: 100, err: fmt.Errorf("%w: sms", ErrUnsupportedChannel), calls: make(chan time.Time, 10)}
+	q := NewQueue(discardLog, next, 10, 3, time.Millisecond)
+	runQueue(t, q)
+
+	if err := q.Send(context.Background(), Message{Channel: SMS, To: "+15550100"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	waitCalls(t, next.calls, 1)
+	expectNoCalls(t, next.calls, 50*time.Millisecond)
+}
+
+func TestQueueFull(t *testing.T) {
+	q := NewQueue(discardLog, &flakyNotifier{calls: make(chan time.Time, 10)}, 1, 1, time.Millisecond)
+
+	if err := q.Send(context.Background(), Message{Channel: Email}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := q.Send(context.Background(), Message{Channel: Email}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Send to a full queue = %v, want ErrQueueFull", err)
+	}
+}
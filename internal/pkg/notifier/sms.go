@@ -0,0 +1,64 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const defaultSMSTimeout = 10 * time.Second
+
+// HTTPSMSSender posts text messages to an SMS gateway as JSON
+// {"from": ..., "to": ..., "text": ...}, authenticating with a bearer token.
+type HTTPSMSSender struct {
+	url    string
+	token  string
+	from   string
+	client *http.Client
+}
+
+func NewHTTPSMSSender(url, token, from string) *HTTPSMSSender {
+	return &HTTPSMSSender{
+		url:    url,
+		token:  token,
+		from:   from,
+		client: &http.Client{Timeout: defaultSMSTimeout},
+	}
+}
+
+func (s *HTTPSMSSender) Send(ctx context.Context, msg Message) error {
+	const op = "notifier.HTTPSMSSender.Send"
+
+	payload, err := json.Marshal(map[string]string{
+		"from": s.from,
+		"to":   msg.To,
+		"text": msg.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: gateway responded %s", op, resp.Status)
+	}
+	return nil
+}
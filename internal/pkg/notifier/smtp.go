@@ -0,0 +1,184 @@
+package notifier
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPSecurity says how an SMTPSender protects its connection to the relay.
+type SMTPSecurity string
+
+const (
+	// SMTPStartTLS upgrades the connection with STARTTLS and refuses to
+	// send when the server does not offer it.
+	SMTPStartTLS SMTPSecurity = "starttls"
+	// SMTPImplicitTLS speaks TLS from the first byte, as on port 465.
+	SMTPImplicitTLS SMTPSecurity = "implicit"
+	// SMTPPlaintext sends everything, credentials included, in the clear.
+	// It is only meant for relays on the same host or network.
+	SMTPPlaintext SMTPSecurity = "none"
+)
+
+var ErrStartTLSUnsupported = errors.New("smtp server does not support STARTTLS")
+
+// SMTPSender delivers email through an SMTP relay over TLS, unless plaintext
+// was asked for explicitly.
+type SMTPSender struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+	security SMTPSecurity
+	// rootCAs verifies the server certificate; nil uses the system pool.
+	rootCAs *x509.CertPool
+}
+
+// NewSMTPSender returns a sender for the relay at host:port. An empty
+// security picks implicit TLS on port 465 and STARTTLS on any other port.
+func NewSMTPSender(host string, port int, username, password, from string, security SMTPSecurity) *SMTPSender {
+	if security == "" {
+		security = SMTPStartTLS
+		if port == 465 {
+			security = SMTPImplicitTLS
+		}
+	}
+	return &SMTPSender{host: host, port: port, username: username, password: password, from: from, security: security}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	const op = "notifier.SMTPSender.Send"
+
+	tlsConfig := &tls.Config{ServerName: s.host, RootCAs: s.rootCAs}
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	var (
+		dialer net.Dialer
+		conn   net.Conn
+		err    error
+	)
+	if s.security == SMTPImplicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: &dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer client.Close()
+
+	if s.security == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s: %w", op, ErrStartTLSUnsupported)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if s.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	from, err := mail.ParseAddress(s.from)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	body, err := s.message(msg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return client.Quit()
+}
+
+func (s *SMTPSender) message(msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return nil, fmt.Errorf("invalid recipient %q", msg.To)
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString("From: " + s.from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("Message-ID: <" + hex.EncodeToString(id) + "@" + s.host + ">\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if msg.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+		b.WriteString("\r\n")
+		b.WriteString(crlf(msg.Body))
+		return []byte(b.String()), nil
+	}
+
+	// Clients show the last part they can render, so HTML goes second.
+	w := multipart.NewWriter(&b)
+	b.WriteString("Content-Type: multipart/alternative; boundary=" + w.Boundary() + "\r\n")
+	b.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Body},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pw.Write([]byte(crlf(part.body))); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
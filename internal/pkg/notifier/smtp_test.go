@@ -0,0 +1,258 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"mime"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpServer is a minimal SMTP relay on 127.0.0.1 that accepts every message.
+type smtpServer struct {
+	port      int
+	tlsConfig *tls.Config
+	// startTLS makes the server offer STARTTLS.
+	startTLS bool
+
+	mu sync.Mutex
+	// received holds whether each accepted message came over TLS.
+	received []bool
+}
+
+// newSMTPServer starts a relay that speaks TLS from the first byte when
+// implicit is set. The returned pool trusts its certificate.
+func newSMTPServer(t *testing.T, implicit, startTLS bool) (*smtpServer, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	s := &smtpServer{
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}},
+		startTLS:  startTLS,
+	}
+	var l net.Listener
+	if implicit {
+		l, err = tls.Listen("tcp", "127.0.0.1:0", s.tlsConfig)
+	} else {
+		l, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	s.port = l.Addr().(*net.TCPAddr).Port
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, implicit)
+		}
+	}()
+	return s, pool
+}
+
+func (s *smtpServer) serve(conn net.Conn, secure bool) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		_, _ = conn.Write([]byte(strings.Join(lines, "\r\n") + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "EHLO"):
+			if s.startTLS && !secure {
+				reply("250-localhost", "250-STARTTLS", "250 8BITMIME")
+			} else {
+				reply("250-localhost", "250 8BITMIME")
+			}
+		case cmd == "STARTTLS" && s.startTLS && !secure:
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, r, secure = tlsConn, bufio.NewReader(tlsConn), true
+		case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+			}
+			s.mu.Lock()
+			s.received = append(s.received, secure)
+			s.mu.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) messages() []bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]bool(nil), s.received...)
+}
+
+func sendTestMail(server *smtpServer, pool *x509.CertPool, security SMTPSecurity) error {
+	sender := NewSMTPSender("127.0.0.1", server.port, "", "", "auth@example.com", security)
+	sender.rootCAs = pool
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return sender.Send(ctx, Message{Channel: Email, To: "alice@example.com", Subject: "Hi", Body: "hello"})
+}
+
+func TestSMTPMessage(t *testing.T) {
+	s := NewSMTPSender("smtp.example.com", 587, "", "", "Auth <auth@example.com>", "")
+	body, err := s.message(Message{
+		Channel: Email,
+		To:      "alice@example.com",
+		Subject: "Сброс пароля",
+		Body:    "line one\nline two\r\nline three",
+	})
+	if err != nil {
+		t.Fatalf("message: %v", err)
+	}
+
+	// Every line ends in CRLF, including those of the body.
+	if strings.Contains(strings.ReplaceAll(string(body), "\r\n", ""), "\n") {
+		t.Fatalf("message has a bare LF:\n%q", body)
+	}
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(body))))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	headers := map[string]string{
+		"From":         "Auth <auth@example.com>",
+		"To":           "alice@example.com",
+		"Content-Type": "text/plain; charset=utf-8",
+		"MIME-Version": "1.0",
+	}
+	for name, want := range headers {
+		if got := msg.Header.Get(name); got != want {
+			t.Fatalf("%s = %q, want %q", name, got, want)
+		}
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Сброс пароля" {
+		t.Fatalf("Subject decodes to %q, %v", subject, err)
+	}
+	if id := msg.Header.Get("Message-ID"); !strings.HasSuffix(id, "@smtp.example.com>") {
+		t.Fatalf("Message-ID = %q", id)
+	}
+	if _, err := msg.Header.Date(); err != nil {
+		t.Fatalf("Date: %v", err)
+	}
+}
+
+func TestSMTPMessageRejectsHeaderInjection(t *testing.T) {
+	s := NewSMTPSender("smtp.example.com", 587, "", "", "auth@example.com", "")
+	for _, to := range []string{"alice@example.com\r\nBcc: eve@example.com", "alice@example.com\nBcc: eve@example.com"} {
+		if _, err := s.message(Message{Channel: Email, To: to}); err == nil {
+			t.Fatalf("message to %q succeeded", to)
+		}
+	}
+}
+
+func TestNewSMTPSenderDefaultSecurity(t *testing.T) {
+	for port, want := range map[int]SMTPSecurity{25: SMTPStartTLS, 587: SMTPStartTLS, 465: SMTPImplicitTLS} {
+		if got := NewSMTPSender("smtp.example.com", port, "", "", "auth@example.com", "").security; got != want {
+			t.Fatalf("port %d: security = %q, want %q", port, got, want)
+		}
+	}
+}
+
+func TestSMTPSendUpgradesWithStartTLS(t *testing.T) {
+	server, pool := newSMTPServer(t, false, true)
+	if err := sendTestMail(server, pool, ""); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := server.messages(); len(got) != 1 || !got[0] {
+		t.Fatalf("messages received over TLS = %v, want [true]", got)
+	}
+}
+
+func TestSMTPSendRequiresStartTLS(t *testing.T) {
+	server, pool := newSMTPServer(t, false, false)
+	if err := sendTestMail(server, pool, ""); !errors.Is(err, ErrStartTLSUnsupported) {
+		t.Fatalf("Send without STARTTLS = %v, want ErrStartTLSUnsupported", err)
+	}
+	if got := server.messages(); len(got) != 0 {
+		t.Fatalf("%d messages sent in the clear", len(got))
+	}
+}
+
+func TestSMTPSendImplicitTLS(t *testing.T) {
+	server, pool := newSMTPServer(t, true, false)
+	if err := sendTestMail(server, pool, SMTPImplicitTLS); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := server.messages(); len(got) != 1 || !got[0] {
+		t.Fatalf("messages received over TLS = %v, want [true]", got)
+	}
+	// The certificate is checked.
+	if err := sendTestMail(server, nil, SMTPImplicitTLS); err == nil {
+		t.Fatal("Send trusted an unknown certificate")
+	}
+}
+
+func TestSMTPSendPlaintextOnlyWhenAskedFor(t *testing.T) {
+	server, _ := newSMTPServer(t, false, false)
+	if err := sendTestMail(server, nil, SMTPPlaintext); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := server.messages(); len(got) != 1 || got[0] {
+		t.Fatalf("messages received over TLS = %v, want [false]", got)
+	}
+}
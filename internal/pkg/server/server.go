@@ -53,6 +53,10 @@ func New(httpHandler http.Handler, opts ...Option) *Server {
 	return server
 }
 
+func (s *Server) Addr() string {
+	return s.httpServer.Addr
+}
+
 func (s *Server) Start() error {
 	if err := s.httpServer.ListenAndServe(); err != nil {
 		return fmt.Errorf("start http server: %w", err)
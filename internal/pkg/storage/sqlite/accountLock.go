@@ -0,0 +1,171 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GetAccountLock returns the user's failed login record, which is empty when
+// they have not failed since their last successful login.
+func (s *Storage) GetAccountLock(ctx context.Context, userID int64) (models.AccountLock, error) {
+	const op = "storage.sqlite.GetAccountLock"
+	query := "SELECT user_id, failed_attempts, lockouts, locked_until, last_failed_at FROM accountLocks WHERE user_id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.AccountLock{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	lock, err := scanAccountLock(stmt.QueryRowContext(ctx, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AccountLock{UserID: userID}, nil
+		}
+		return models.AccountLock{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return lock, nil
+}
+
+// ReserveLoginAttempt counts a login attempt against the user before it is
+// checked and returns the updated record. The count is taken in one statement,
+// so concurrent attempts each see a different value.
+func (s *Storage) ReserveLoginAttempt(ctx context.Context, userID int64) (models.AccountLock, error) {
+	const op = "storage.sqlite.ReserveLoginAttempt"
+	query := `INSERT INTO accountLocks (user_id, failed_attempts) VALUES (?, 1)
+		ON CONFLICT(user_id) DO UPDATE SET failed_attempts = failed_attempts + 1
+		RETURNING user_id, failed_attempts, lockouts, locked_until, last_failed_at`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.AccountLock{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	lock, err := scanAccountLock(stmt.QueryRowContext(ctx, userID))
+	if err != nil {
+		return models.AccountLock{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return lock, nil
+}
+
+// ReleaseLoginAttempt gives back an attempt reserved with ReserveLoginAttempt
+// that did not fail.
+func (s *Storage) ReleaseLoginAttempt(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.ReleaseLoginAttempt"
+	query := "UPDATE accountLocks SET failed_attempts = failed_attempts - 1 WHERE user_id = ? AND failed_attempts > 0"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RecordFailedLogin notes when a reserved attempt failed.
+func (s *Storage) RecordFailedLogin(ctx context.Context, userID int64, at time.Time) error {
+	const op = "storage.sqlite.RecordFailedLogin"
+	query := "UPDATE accountLocks SET last_failed_at = ? WHERE user_id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, at.UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// LockAccount locks the user out until the given time, counting one more
+// lockout and starting the failure count over.
+func (s *Storage) LockAccount(ctx context.Context, userID int64, until time.Time) error {
+	const op = "storage.sqlite.LockAccount"
+	query := "UPDATE accountLocks SET failed_attempts = 0, lockouts = lockouts + 1, locked_until = ? WHERE user_id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, until.UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UnlockAccount forgets the user's failed logins and lifts any lock. It
+// reports whether there was anything to forget.
+func (s *Storage) UnlockAccount(ctx context.Context, userID int64) (bool, error) {
+	const op = "storage.sqlite.UnlockAccount"
+	query := "DELETE FROM accountLocks WHERE user_id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected > 0, nil
+}
+
+func scanAccountLock(row rowScanner) (models.AccountLock, error) {
+	var (
+		lock                      models.AccountLock
+		lockedUntil, lastFailedAt sql.NullTime
+	)
+	err := row.Scan(&lock.UserID, &lock.FailedAttempts, &lock.Lockouts, &lockedUntil, &lastFailedAt)
+	if err != nil {
+		return models.AccountLock{}, err
+	}
+	lock.LockedUntil = lockedUntil.Time
+	lock.LastFailedAt = lastFailedAt.Time
+	return lock, nil
+}
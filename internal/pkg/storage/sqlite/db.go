@@ -16,6 +16,10 @@ func createTables(ctx context.Context, db *sql.DB) error {
 			email VARCHAR(255) NOT NULL,
 			pass_hash VARCHAR(255) NOT NULL,
 			role VARCHAR(50) DEFAULT 'user',
+			token_version INTEGER NOT NULL DEFAULT 0,
+			email_verified BOOLEAN NOT NULL DEFAULT 0,
+			phone_verified BOOLEAN NOT NULL DEFAULT 0,
+			locale VARCHAR(35) NOT NULL DEFAULT '',
 			UNIQUE(email),
 			UNIQUE(login)
 		)`
@@ -25,6 +29,45 @@ func createTables(ctx context.Context, db *sql.DB) error {
 		return fmt.Errorf("error creating users table: %v", err)
 	}
 
+	err = addColumn(ctx, db, "users", "token_version", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+
+	// Users who signed up before emails were verified count as verified, so
+	// turning on emailVerification.required does not lock them out.
+	emailVerified, err := hasColumn(ctx, db, "users", "email_verified")
+	if err != nil {
+		return err
+	}
+	err = addColumn(ctx, db, "users", "email_verified", "BOOLEAN NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	if !emailVerified {
+		_, err = db.ExecContext(ctx, "UPDATE users SET email_verified = 1")
+		if err != nil {
+			return fmt.Errorf("error marking existing users verified: %v", err)
+		}
+	}
+
+	err = addColumn(ctx, db, "users", "phone_verified", "BOOLEAN NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+
+	err = addColumn(ctx, db, "users", "locale", "VARCHAR(35) NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+
+	// A number can be verified by one account only.
+	_, err = db.ExecContext(ctx, "CREATE UNIQUE INDEX IF NOT EXISTS idx_users_verified_phone ON users(phone) WHERE phone_verified = 1")
+	if err != nil {
+		return fmt.Errorf("error creating users phone index: %v", err)
+	}
+
+	// resetTokens.token holds the SHA-256 hash of the token, not the token.
 	queryResetTokens := `CREATE TABLE IF NOT EXISTS resetTokens (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			user_id INTEGER NOT NULL,
@@ -38,9 +81,365 @@ func createTables(ctx context.Context, db *sql.DB) error {
 	if err != nil {
 		return fmt.Errorf("error creating resetTokens table: %v", err)
 	}
+
+	queryVerificationTokens := `CREATE TABLE IF NOT EXISTS verificationTokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			email VARCHAR(255) NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryVerificationTokens)
+	if err != nil {
+		return fmt.Errorf("error creating verificationTokens table: %v", err)
+	}
+
+	queryMagicLinks := `CREATE TABLE IF NOT EXISTS magicLinks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			email VARCHAR(255) NOT NULL,
+			device_hash TEXT NOT NULL DEFAULT '',
+			used BOOLEAN NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryMagicLinks)
+	if err != nil {
+		return fmt.Errorf("error creating magicLinks table: %v", err)
+	}
+
+	queryPhoneCodes := `CREATE TABLE IF NOT EXISTS phoneCodes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			phone VARCHAR(30) NOT NULL,
+			code_hash TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			UNIQUE(user_id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryPhoneCodes)
+	if err != nil {
+		return fmt.Errorf("error creating phoneCodes table: %v", err)
+	}
+
+	queryRefreshTokens := `CREATE TABLE IF NOT EXISTS refreshTokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			token_hash TEXT NOT NULL,
+			family_id TEXT NOT NULL,
+			used BOOLEAN NOT NULL DEFAULT 0,
+			revoked BOOLEAN NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			UNIQUE(token_hash),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryRefreshTokens)
+	if err != nil {
+		return fmt.Errorf("error creating refreshTokens table: %v", err)
+	}
+
+	_, err = db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_refreshTokens_family ON refreshTokens(family_id)")
+	if err != nil {
+		return fmt.Errorf("error creating refreshTokens index: %v", err)
+	}
+
+	querySessions := `CREATE TABLE IF NOT EXISTS sessions (
+			id TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			device_name VARCHAR(255) NOT NULL DEFAULT '',
+			user_agent VARCHAR(512) NOT NULL DEFAULT '',
+			ip VARCHAR(64) NOT NULL DEFAULT '',
+			client_id TEXT NOT NULL DEFAULT '',
+			scope TEXT NOT NULL DEFAULT '',
+			revoked BOOLEAN NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_seen_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, querySessions)
+	if err != nil {
+		return fmt.Errorf("error creating sessions table: %v", err)
+	}
+
+	err = addColumn(ctx, db, "sessions", "client_id", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+
+	err = addColumn(ctx, db, "sessions", "scope", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id)")
+	if err != nil {
+		return fmt.Errorf("error creating sessions index: %v", err)
+	}
+
+	queryMFAFactors := `CREATE TABLE IF NOT EXISTS mfaFactors (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			secret BLOB NOT NULL,
+			confirmed BOOLEAN NOT NULL DEFAULT 0,
+			last_used_step INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryMFAFactors)
+	if err != nil {
+		return fmt.Errorf("error creating mfaFactors table: %v", err)
+	}
+
+	queryMFAChallenges := `CREATE TABLE IF NOT EXISTS mfaChallenges (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			token_hash TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			device_name TEXT NOT NULL DEFAULT '',
+			user_agent TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT '',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			UNIQUE(token_hash),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryMFAChallenges)
+	if err != nil {
+		return fmt.Errorf("error creating mfaChallenges table: %v", err)
+	}
+
+	queryRecoveryCodes := `CREATE TABLE IF NOT EXISTS recoveryCodes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			code_hash BLOB NOT NULL,
+			used BOOLEAN NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryRecoveryCodes)
+	if err != nil {
+		return fmt.Errorf("error creating recoveryCodes table: %v", err)
+	}
+
+	_, err = db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_recoveryCodes_user ON recoveryCodes(user_id)")
+	if err != nil {
+		return fmt.Errorf("error creating recoveryCodes index: %v", err)
+	}
+
+	queryWebAuthnCredentials := `CREATE TABLE IF NOT EXISTS webauthnCredentials (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			credential_id BLOB NOT NULL UNIQUE,
+			public_key BLOB NOT NULL,
+			sign_count INTEGER NOT NULL DEFAULT 0,
+			aaguid BLOB,
+			name TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_used_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryWebAuthnCredentials)
+	if err != nil {
+		return fmt.Errorf("error creating webauthnCredentials table: %v", err)
+	}
+
+	_, err = db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_webauthnCredentials_user ON webauthnCredentials(user_id)")
+	if err != nil {
+		return fmt.Errorf("error creating webauthnCredentials index: %v", err)
+	}
+
+	queryAccountLocks := `CREATE TABLE IF NOT EXISTS accountLocks (
+			user_id INTEGER PRIMARY KEY,
+			failed_attempts INTEGER NOT NULL DEFAULT 0,
+			lockouts INTEGER NOT NULL DEFAULT 0,
+			locked_until DATETIME,
+			last_failed_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryAccountLocks)
+	if err != nil {
+		return fmt.Errorf("error creating accountLocks table: %v", err)
+	}
+
+	queryOAuthClients := `CREATE TABLE IF NOT EXISTS oauthClients (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			redirect_uris TEXT NOT NULL DEFAULT '',
+			scopes TEXT NOT NULL DEFAULT '',
+			grant_types TEXT NOT NULL DEFAULT 'authorization_code refresh_token',
+			auth_method TEXT NOT NULL DEFAULT 'none',
+			secret_hash TEXT NOT NULL DEFAULT '',
+			jwks TEXT NOT NULL DEFAULT '',
+			disabled BOOLEAN NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`
+
+	_, err = db.ExecContext(ctx, queryOAuthClients)
+	if err != nil {
+		return fmt.Errorf("error creating oauthClients table: %v", err)
+	}
+
+	err = addColumn(ctx, db, "oauthClients", "grant_types", "TEXT NOT NULL DEFAULT 'authorization_code refresh_token'")
+	if err != nil {
+		return err
+	}
+
+	err = addColumn(ctx, db, "oauthClients", "auth_method", "TEXT NOT NULL DEFAULT 'none'")
+	if err != nil {
+		return err
+	}
+
+	err = addColumn(ctx, db, "oauthClients", "secret_hash", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+
+	err = addColumn(ctx, db, "oauthClients", "jwks", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+
+	err = addColumn(ctx, db, "oauthClients", "disabled", "BOOLEAN NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+
+	queryAuthorizationCodes := `CREATE TABLE IF NOT EXISTS authorizationCodes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			code_hash TEXT NOT NULL UNIQUE,
+			client_id TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			redirect_uri TEXT NOT NULL,
+			scope TEXT NOT NULL DEFAULT '',
+			code_challenge TEXT NOT NULL,
+			nonce TEXT NOT NULL DEFAULT '',
+			auth_time DATETIME,
+			session_id TEXT NOT NULL DEFAULT '',
+			used BOOLEAN NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			FOREIGN KEY (client_id) REFERENCES oauthClients(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryAuthorizationCodes)
+	if err != nil {
+		return fmt.Errorf("error creating authorizationCodes table: %v", err)
+	}
+
+	err = addColumn(ctx, db, "authorizationCodes", "nonce", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+
+	err = addColumn(ctx, db, "authorizationCodes", "auth_time", "DATETIME")
+	if err != nil {
+		return err
+	}
+
+	queryOAuthConsents := `CREATE TABLE IF NOT EXISTS oauthConsents (
+			user_id INTEGER NOT NULL,
+			client_id TEXT NOT NULL,
+			scope TEXT NOT NULL DEFAULT '',
+			granted_at DATETIME NOT NULL,
+			PRIMARY KEY (user_id, client_id),
+			FOREIGN KEY (user_id) REFERENCES users(id),
+			FOREIGN KEY (client_id) REFERENCES oauthClients(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryOAuthConsents)
+	if err != nil {
+		return fmt.Errorf("error creating oauthConsents table: %v", err)
+	}
+
+	queryDeviceAuthorizations := `CREATE TABLE IF NOT EXISTS deviceAuthorizations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			device_code_hash TEXT NOT NULL UNIQUE,
+			user_code_hash TEXT NOT NULL UNIQUE,
+			client_id TEXT NOT NULL,
+			scope TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'pending',
+			user_id INTEGER,
+			auth_time DATETIME,
+			session_id TEXT NOT NULL DEFAULT '',
+			interval_seconds INTEGER NOT NULL,
+			last_polled_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			FOREIGN KEY (client_id) REFERENCES oauthClients(id),
+			FOREIGN KEY (user_id) REFERENCES users(id)
+		)`
+
+	_, err = db.ExecContext(ctx, queryDeviceAuthorizations)
+	if err != nil {
+		return fmt.Errorf("error creating deviceAuthorizations table: %v", err)
+	}
+	return nil
+}
+
+// addColumn adds a column to a table created before the column existed.
+func addColumn(ctx context.Context, db *sql.DB, table, column, definition string) error {
+	exists, err := hasColumn(ctx, db, table, column)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("error adding %s.%s column: %v", table, column, err)
+	}
 	return nil
 }
 
+func hasColumn(ctx context.Context, db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("error reading %s columns: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid          int
+			name, kind   string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &kind, &notNull, &defaultValue, &pk); err != nil {
+			return false, fmt.Errorf("error reading %s columns: %v", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("error reading %s columns: %v", table, err)
+	}
+	return false, nil
+}
+
 func ClearTokens(ctx context.Context, db *sql.DB) {
 	for {
 		time.Sleep(1 * time.Hour)
@@ -48,5 +447,37 @@ func ClearTokens(ctx context.Context, db *sql.DB) {
 		if err != nil {
 			log.Printf("Failed to clean up expired tokens: %v", err)
 		}
+		_, err = db.ExecContext(ctx, "DELETE FROM verificationTokens WHERE expires_at < DATETIME('now')")
+		if err != nil {
+			log.Printf("Failed to clean up expired verification tokens: %v", err)
+		}
+		_, err = db.ExecContext(ctx, "DELETE FROM magicLinks WHERE expires_at < DATETIME('now')")
+		if err != nil {
+			log.Printf("Failed to clean up expired magic links: %v", err)
+		}
+		_, err = db.ExecContext(ctx, "DELETE FROM authorizationCodes WHERE expires_at < DATETIME('now')")
+		if err != nil {
+			log.Printf("Failed to clean up expired authorization codes: %v", err)
+		}
+		_, err = db.ExecContext(ctx, "DELETE FROM deviceAuthorizations WHERE expires_at < DATETIME('now')")
+		if err != nil {
+			log.Printf("Failed to clean up expired device authorizations: %v", err)
+		}
+		_, err = db.ExecContext(ctx, "DELETE FROM phoneCodes WHERE expires_at < DATETIME('now')")
+		if err != nil {
+			log.Printf("Failed to clean up expired phone codes: %v", err)
+		}
+		_, err = db.ExecContext(ctx, "DELETE FROM refreshTokens WHERE expires_at < DATETIME('now')")
+		if err != nil {
+			log.Printf("Failed to clean up expired refresh tokens: %v", err)
+		}
+		_, err = db.ExecContext(ctx, "DELETE FROM sessions WHERE expires_at < DATETIME('now')")
+		if err != nil {
+			log.Printf("Failed to clean up expired sessions: %v", err)
+		}
+		_, err = db.ExecContext(ctx, "DELETE FROM mfaChallenges WHERE expires_at < DATETIME('now')")
+		if err != nil {
+			log.Printf("Failed to clean up expired mfa challenges: %v", err)
+		}
 	}
 }
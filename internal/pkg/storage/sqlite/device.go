@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const deviceAuthorizationColumns = `id, device_code_hash, user_code_hash, client_id, scope, status, user_id, auth_time, session_id,
+	interval_seconds, last_polled_at, created_at, expires_at`
+
+func (s *Storage) SaveDeviceAuthorization(ctx context.Context, auth models.DeviceAuthorization) error {
+	const op = "storage.sqlite.SaveDeviceAuthorization"
+	query := `INSERT INTO deviceAuthorizations (device_code_hash, user_code_hash, client_id, scope, interval_seconds, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx,
+		auth.DeviceCodeHash,
+		auth.UserCodeHash,
+		auth.ClientID,
+		auth.Scope,
+		int64(auth.Interval/time.Second),
+		auth.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, ErrUserCodeTaken)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// GetDeviceAuthorization looks up the authorization a device polls for.
+func (s *Storage) GetDeviceAuthorization(ctx context.Context, deviceCodeHash string) (models.DeviceAuthorization, error) {
+	const op = "storage.sqlite.GetDeviceAuthorization"
+	return s.getDeviceAuthorization(ctx, op, "device_code_hash", deviceCodeHash)
+}
+
+// GetDeviceAuthorizationByUserCode looks up the authorization the user
+// entered the code of.
+func (s *Storage) GetDeviceAuthorizationByUserCode(ctx context.Context, userCodeHash string) (models.DeviceAuthorization, error) {
+	const op = "storage.sqlite.GetDeviceAuthorizationByUserCode"
+	return s.getDeviceAuthorization(ctx, op, "user_code_hash", userCodeHash)
+}
+
+func (s *Storage) getDeviceAuthorization(ctx context.Context, op string, column string, hash string) (models.DeviceAuthorization, error) {
+	query := fmt.Sprintf("SELECT %s FROM deviceAuthorizations WHERE %s = ?", deviceAuthorizationColumns, column)
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	var (
+		auth         models.DeviceAuthorization
+		userID       sql.NullInt64
+		authTime     sql.NullTime
+		interval     int64
+		lastPolledAt sql.NullTime
+	)
+	err = stmt.QueryRowContext(ctx, hash).Scan(
+		&auth.ID,
+		&auth.DeviceCodeHash,
+		&auth.UserCodeHash,
+		&auth.ClientID,
+		&auth.Scope,
+		&auth.Status,
+		&userID,
+		&authTime,
+		&auth.SessionID,
+		&interval,
+		&lastPolledAt,
+		&auth.CreatedAt,
+		&auth.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, ErrCodeNotFound)
+		}
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	auth.UserID = userID.Int64
+	auth.AuthTime = authTime.Time
+	auth.Interval = time.Duration(interval) * time.Second
+	auth.LastPolledAt = lastPolledAt.Time
+	return auth, nil
+}
+
+// DecideDeviceAuthorization records whether the user approved or denied a
+// pending authorization. It reports false if it was no longer pending.
+func (s *Storage) DecideDeviceAuthorization(ctx context.Context, id int64, status string, userID int64, authTime time.Time) (bool, error) {
+	const op = "storage.sqlite.DecideDeviceAuthorization"
+	query := "UPDATE deviceAuthorizations SET status = ?, user_id = ?, auth_time = ? WHERE id = ? AND status = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, status, userID, authTime.UTC(), id, models.DeviceAuthorizationPending)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
+
+// PollDeviceAuthorization records a poll and the interval the device has
+// to wait before the next one.
+func (s *Storage) PollDeviceAuthorization(ctx context.Context, id int64, polledAt time.Time, interval time.Duration) error {
+	const op = "storage.sqlite.PollDeviceAuthorization"
+	query := "UPDATE deviceAuthorizations SET last_polled_at = ?, interval_seconds = ? WHERE id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, polledAt.UTC(), int64(interval/time.Second), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UseDeviceAuthorization marks an approved authorization used by the
+// session it started. It reports false if it already was, so the device
+// code is exchanged at most once.
+func (s *Storage) UseDeviceAuthorization(ctx context.Context, id int64, sessionID string) (bool, error) {
+	const op = "storage.sqlite.UseDeviceAuthorization"
+	query := "UPDATE deviceAuthorizations SET status = ?, session_id = ? WHERE id = ? AND status = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, models.DeviceAuthorizationUsed, sessionID, id, models.DeviceAuthorizationApproved)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func (s *Storage) SaveMagicLink(ctx context.Context, link models.MagicLink) error {
+	const op = "storage.sqlite.SaveMagicLink"
+	query := "INSERT INTO magicLinks (user_id, token_hash, email, device_hash, expires_at) VALUES (?, ?, ?, ?, ?)"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, link.UserID, link.TokenHash, link.Email, link.DeviceHash, link.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetMagicLink(ctx context.Context, tokenHash string) (models.MagicLink, error) {
+	const op = "storage.sqlite.GetMagicLink"
+	query := "SELECT id, user_id, token_hash, email, device_hash, used, created_at, expires_at FROM magicLinks WHERE token_hash = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.MagicLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	row := stmt.QueryRowContext(ctx, tokenHash)
+
+	var link models.MagicLink
+	err = row.Scan(&link.ID, &link.UserID, &link.TokenHash, &link.Email, &link.DeviceHash, &link.Used, &link.CreatedAt, &link.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MagicLink{}, fmt.Errorf("%s: %w", op, ErrTokenNotFound)
+		}
+		return models.MagicLink{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return link, nil
+}
+
+// UseMagicLink marks the link used. It reports false if it already was, so
+// a link signs in at most once even when followed twice at the same time.
+func (s *Storage) UseMagicLink(ctx context.Context, id int64) (bool, error) {
+	const op = "storage.sqlite.UseMagicLink"
+	query := "UPDATE magicLinks SET used = 1 WHERE id = ? AND used = 0"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
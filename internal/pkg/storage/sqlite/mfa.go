@@ -0,0 +1,110 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SaveMFAFactor stores a new unconfirmed TOTP secret for the user, replacing
+// an earlier enrollment that was never confirmed.
+func (s *Storage) SaveMFAFactor(ctx context.Context, userID int64, secret []byte) error {
+	const op = "storage.sqlite.SaveMFAFactor"
+	query := `INSERT INTO mfaFactors (user_id, secret) VALUES (?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+		WHERE mfaFactors.confirmed = 0`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, userID, secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetMFAFactor(ctx context.Context, userID int64) (models.MFAFactor, error) {
+	const op = "storage.sqlite.GetMFAFactor"
+	query := "SELECT id, user_id, secret, confirmed, last_used_step, created_at FROM mfaFactors WHERE user_id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.MFAFactor{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	row := stmt.QueryRowContext(ctx, userID)
+
+	var factor models.MFAFactor
+	err = row.Scan(&factor.ID, &factor.UserID, &factor.Secret, &factor.Confirmed, &factor.LastUsedStep, &factor.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFAFactor{}, fmt.Errorf("%s: %w", op, ErrFactorNotFound)
+		}
+		return models.MFAFactor{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return factor, nil
+}
+
+func (s *Storage) ConfirmMFAFactor(ctx context.Context, id int64, step int64) error {
+	const op = "storage.sqlite.ConfirmMFAFactor"
+	query := "UPDATE mfaFactors SET confirmed = 1, last_used_step = ? WHERE id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, step, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UseMFAStep records that the code for step has been used. It reports false
+// when that step or a later one was already used, so a code cannot be
+// replayed.
+func (s *Storage) UseMFAStep(ctx context.Context, id int64, step int64) (bool, error) {
+	const op = "storage.sqlite.UseMFAStep"
+	query := "UPDATE mfaFactors SET last_used_step = ? WHERE id = ? AND last_used_step < ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, step, id, step)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func (s *Storage) SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error {
+	const op = "storage.sqlite.SaveMFAChallenge"
+	query := `INSERT INTO mfaChallenges (token_hash, user_id, device_name, user_agent, ip, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx,
+		challenge.TokenHash,
+		challenge.UserID,
+		challenge.Client.DeviceName,
+		challenge.Client.UserAgent,
+		challenge.Client.IP,
+		challenge.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetMFAChallenge(ctx context.Context, tokenHash string) (models.MFAChallenge, error) {
+	const op = "storage.sqlite.GetMFAChallenge"
+	query := `SELECT id, token_hash, user_id, device_name, user_agent, ip, attempts, expires_at
+		FROM mfaChallenges WHERE token_hash = ?`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	var challenge models.MFAChallenge
+	err = stmt.QueryRowContext(ctx, tokenHash).Scan(
+		&challenge.ID,
+		&challenge.TokenHash,
+		&challenge.UserID,
+		&challenge.Client.DeviceName,
+		&challenge.Client.UserAgent,
+		&challenge.Client.IP,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, ErrCodeNotFound)
+		}
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return challenge, nil
+}
+
+// AddMFAChallengeAttempt counts a wrong code. It reports false once
+// maxAttempts have been made, without counting further.
+func (s *Storage) AddMFAChallengeAttempt(ctx context.Context, id int64, maxAttempts int) (bool, error) {
+	const op = "storage.sqlite.AddMFAChallengeAttempt"
+	query := "UPDATE mfaChallenges SET attempts = attempts + 1 WHERE id = ? AND attempts < ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, id, maxAttempts)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
+
+// DeleteMFAChallenge removes a challenge. It reports false if it was already
+// gone, so a challenge is completed at most once.
+func (s *Storage) DeleteMFAChallenge(ctx context.Context, id int64) (bool, error) {
+	const op = "storage.sqlite.DeleteMFAChallenge"
+	query := "DELETE FROM mfaChallenges WHERE id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
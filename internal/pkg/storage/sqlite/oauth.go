@@ -0,0 +1,303 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SaveOAuthClient registers the client, or replaces the metadata of the one
+// with the same ID. The secret and the disabled flag of an existing client
+// are kept; they only change through UpdateOAuthClientSecret and
+// DisableOAuthClient.
+func (s *Storage) SaveOAuthClient(ctx context.Context, client models.OAuthClient) error {
+	const op = "storage.sqlite.SaveOAuthClient"
+	query := `INSERT INTO oauthClients (id, name, redirect_uris, scopes, grant_types, auth_method, secret_hash, jwks) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, redirect_uris = excluded.redirect_uris, scopes = excluded.scopes,
+			grant_types = excluded.grant_types, auth_method = excluded.auth_method, jwks = excluded.jwks`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx,
+		client.ID,
+		client.Name,
+		strings.Join(client.RedirectURIs, " "),
+		strings.Join(client.Scopes, " "),
+		strings.Join(client.GrantTypes, " "),
+		client.AuthMethod,
+		client.SecretHash,
+		client.JWKS,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetOAuthClient(ctx context.Context, id string) (models.OAuthClient, error) {
+	const op = "storage.sqlite.GetOAuthClient"
+	query := `SELECT id, name, redirect_uris, scopes, grant_types, auth_method, secret_hash, jwks, disabled, created_at
+		FROM oauthClients WHERE id = ?`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.OAuthClient{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	var (
+		client       models.OAuthClient
+		redirectURIs string
+		scopes       string
+		grantTypes   string
+	)
+	err = stmt.QueryRowContext(ctx, id).Scan(
+		&client.ID,
+		&client.Name,
+		&redirectURIs,
+		&scopes,
+		&grantTypes,
+		&client.AuthMethod,
+		&client.SecretHash,
+		&client.JWKS,
+		&client.Disabled,
+		&client.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OAuthClient{}, fmt.Errorf("%s: %w", op, ErrClientNotFound)
+		}
+		return models.OAuthClient{}, fmt.Errorf("%s: %w", op, err)
+	}
+	client.RedirectURIs = strings.Fields(redirectURIs)
+	client.Scopes = strings.Fields(scopes)
+	client.GrantTypes = strings.Fields(grantTypes)
+	return client, nil
+}
+
+// UpdateOAuthClientSecret replaces the client's secret hash. It reports false
+// if there is no such client.
+func (s *Storage) UpdateOAuthClientSecret(ctx context.Context, id string, secretHash string) (bool, error) {
+	const op = "storage.sqlite.UpdateOAuthClientSecret"
+	query := "UPDATE oauthClients SET secret_hash = ? WHERE id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, secretHash, id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
+
+// DisableOAuthClient marks the client disabled. It reports false if there is
+// no such client.
+func (s *Storage) DisableOAuthClient(ctx context.Context, id string) (bool, error) {
+	const op = "storage.sqlite.DisableOAuthClient"
+	query := "UPDATE oauthClients SET disabled = 1 WHERE id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
+
+func (s *Storage) SaveAuthorizationCode(ctx context.Context, code models.AuthorizationCode) error {
+	const op = "storage.sqlite.SaveAuthorizationCode"
+	query := `INSERT INTO authorizationCodes (code_hash, client_id, user_id, redirect_uri, scope, code_challenge, nonce, auth_time, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		code.Scope,
+		code.CodeChallenge,
+		code.Nonce,
+		code.AuthTime.UTC(),
+		code.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetAuthorizationCode(ctx context.Context, codeHash string) (models.AuthorizationCode, error) {
+	const op = "storage.sqlite.GetAuthorizationCode"
+	query := `SELECT id, code_hash, client_id, user_id, redirect_uri, scope, code_challenge, nonce, auth_time, session_id, used, created_at, expires_at
+		FROM authorizationCodes WHERE code_hash = ?`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.AuthorizationCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	var (
+		code     models.AuthorizationCode
+		authTime sql.NullTime
+	)
+	err = stmt.QueryRowContext(ctx, codeHash).Scan(
+		&code.ID,
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		&code.Scope,
+		&code.CodeChallenge,
+		&code.Nonce,
+		&authTime,
+		&code.SessionID,
+		&code.Used,
+		&code.CreatedAt,
+		&code.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.AuthorizationCode{}, fmt.Errorf("%s: %w", op, ErrCodeNotFound)
+		}
+		return models.AuthorizationCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+	code.AuthTime = authTime.Time
+	return code, nil
+}
+
+// UseAuthorizationCode marks the code used by the session it started. It
+// reports false if it already was, so a code is exchanged at most once.
+func (s *Storage) UseAuthorizationCode(ctx context.Context, id int64, sessionID string) (bool, error) {
+	const op = "storage.sqlite.UseAuthorizationCode"
+	query := "UPDATE authorizationCodes SET used = 1, session_id = ? WHERE id = ? AND used = 0"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, sessionID, id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
+
+// GetOAuthConsent returns the scopes the user granted the client, which are
+// empty when they never did.
+func (s *Storage) GetOAuthConsent(ctx context.Context, userID int64, clientID string) (models.OAuthConsent, error) {
+	const op = "storage.sqlite.GetOAuthConsent"
+	query := "SELECT user_id, client_id, scope, granted_at FROM oauthConsents WHERE user_id = ? AND client_id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.OAuthConsent{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	var consent models.OAuthConsent
+	err = stmt.QueryRowContext(ctx, userID, clientID).Scan(&consent.UserID, &consent.ClientID, &consent.Scope, &consent.GrantedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.OAuthConsent{UserID: userID, ClientID: clientID}, nil
+		}
+		return models.OAuthConsent{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return consent, nil
+}
+
+// SaveOAuthConsent replaces the scopes the user granted the client.
+func (s *Storage) SaveOAuthConsent(ctx context.Context, consent models.OAuthConsent) error {
+	const op = "storage.sqlite.SaveOAuthConsent"
+	query := `INSERT INTO oauthConsents (user_id, client_id, scope, granted_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, client_id) DO UPDATE SET scope = excluded.scope, granted_at = excluded.granted_at`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	grantedAt := consent.GrantedAt
+	if grantedAt.IsZero() {
+		grantedAt = time.Now()
+	}
+	_, err = stmt.ExecContext(ctx, consent.UserID, consent.ClientID, consent.Scope, grantedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SavePhoneCode stores a new code for the user, replacing any earlier one.
+// Attempts made on an earlier code that has not expired yet carry over, so
+// asking for a new code does not buy more guesses.
+func (s *Storage) SavePhoneCode(ctx context.Context, code models.PhoneCode) error {
+	const op = "storage.sqlite.SavePhoneCode"
+	query := `INSERT INTO phoneCodes (user_id, phone, code_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET phone = excluded.phone, code_hash = excluded.code_hash,
+		attempts = CASE WHEN phoneCodes.expires_at > excluded.created_at THEN phoneCodes.attempts ELSE 0 END,
+		created_at = excluded.created_at, expires_at = excluded.expires_at`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, code.UserID, code.Phone, code.CodeHash, code.CreatedAt.UTC(), code.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetPhoneCode(ctx context.Context, userID int64) (models.PhoneCode, error) {
+	const op = "storage.sqlite.GetPhoneCode"
+	query := "SELECT id, user_id, phone, code_hash, attempts, created_at, expires_at FROM phoneCodes WHERE user_id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.PhoneCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	row := stmt.QueryRowContext(ctx, userID)
+
+	var code models.PhoneCode
+	err = row.Scan(&code.ID, &code.UserID, &code.Phone, &code.CodeHash, &code.Attempts, &code.CreatedAt, &code.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PhoneCode{}, fmt.Errorf("%s: %w", op, ErrCodeNotFound)
+		}
+		return models.PhoneCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return code, nil
+}
+
+// AddPhoneCodeAttempt counts an attempt to enter the code. It reports false
+// once maxAttempts have been made, without counting further.
+func (s *Storage) AddPhoneCodeAttempt(ctx context.Context, id int64, maxAttempts int) (bool, error) {
+	const op = "storage.sqlite.AddPhoneCodeAttempt"
+	query := "UPDATE phoneCodes SET attempts = attempts + 1 WHERE id = ? AND attempts < ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, id, maxAttempts)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
+
+func (s *Storage) DeletePhoneCode(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.DeletePhoneCode"
+	query := "DELETE FROM phoneCodes WHERE user_id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
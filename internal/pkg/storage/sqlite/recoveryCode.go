@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ReplaceRecoveryCodes drops every recovery code of the user and stores the
+// new set in their place.
+func (s *Storage) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes [][]byte) error {
+	const op = "storage.sqlite.ReplaceRecoveryCodes"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM recoveryCodes WHERE user_id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO recoveryCodes (user_id, code_hash) VALUES (?, ?)")
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	for _, codeHash := range codeHashes {
+		if _, err := stmt.ExecContext(ctx, userID, codeHash); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ListRecoveryCodes returns the user's recovery codes that have not been used.
+func (s *Storage) ListRecoveryCodes(ctx context.Context, userID int64) ([]models.RecoveryCode, error) {
+	const op = "storage.sqlite.ListRecoveryCodes"
+	query := "SELECT id, user_id, code_hash, used, created_at FROM recoveryCodes WHERE user_id = ? AND used = 0"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var codes []models.RecoveryCode
+	for rows.Next() {
+		var code models.RecoveryCode
+		if err := rows.Scan(&code.ID, &code.UserID, &code.CodeHash, &code.Used, &code.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		codes = append(codes, code)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode marks the user's code with the given hash as used. It
+// reports false when the user has no such unused code, so two concurrent
+// requests cannot both spend it.
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int64, codeHash []byte) (bool, error) {
+	const op = "storage.sqlite.UseRecoveryCode"
+	query := "UPDATE recoveryCodes SET used = 1 WHERE user_id = ? AND code_hash = ? AND used = 0"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
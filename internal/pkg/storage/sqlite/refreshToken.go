@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (s *Storage) SaveRefreshToken(ctx context.Context, userID int64, tokenHash string, familyID string, expiresAt time.Time) error {
+	const op = "storage.sqlite.SaveRefreshToken"
+	query := "INSERT INTO refreshTokens (user_id, token_hash, family_id, expires_at) VALUES (?, ?, ?, ?)"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, userID, tokenHash, familyID, expiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	const op = "storage.sqlite.GetRefreshToken"
+	query := "SELECT id, user_id, token_hash, family_id, used, revoked, created_at, expires_at FROM refreshTokens WHERE token_hash = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	row := stmt.QueryRowContext(ctx, tokenHash)
+
+	var refreshToken models.RefreshToken
+	err = row.Scan(
+		&refreshToken.ID,
+		&refreshToken.UserID,
+		&refreshToken.TokenHash,
+		&refreshToken.FamilyID,
+		&refreshToken.Used,
+		&refreshToken.Revoked,
+		&refreshToken.CreatedAt,
+		&refreshToken.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.RefreshToken{}, fmt.Errorf("%s: %w", op, ErrTokenNotFound)
+		}
+		return models.RefreshToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return refreshToken, nil
+}
+
+// UseRefreshToken marks the token as used. It reports false when the token
+// was already used or revoked, so concurrent refreshes cannot both succeed.
+func (s *Storage) UseRefreshToken(ctx context.Context, id int64) (bool, error) {
+	const op = "storage.sqlite.UseRefreshToken"
+	query := "UPDATE refreshTokens SET used = 1 WHERE id = ? AND used = 0 AND revoked = 0"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
+
+func (s *Storage) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	const op = "storage.sqlite.RevokeRefreshTokenFamily"
+	query := "UPDATE refreshTokens SET revoked = 1 WHERE family_id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, familyID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
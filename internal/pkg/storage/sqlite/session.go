@@ -0,0 +1,274 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (s *Storage) SaveSession(ctx context.Context, session models.Session) error {
+	const op = "storage.sqlite.SaveSession"
+	query := `INSERT INTO sessions (id, user_id, device_name, user_agent, ip, client_id, scope, created_at, last_seen_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx,
+		session.ID,
+		session.UserID,
+		session.DeviceName,
+		session.UserAgent,
+		session.IP,
+		session.ClientID,
+		session.Scope,
+		session.CreatedAt.UTC(),
+		session.LastSeenAt.UTC(),
+		session.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetSession(ctx context.Context, id string) (models.Session, error) {
+	const op = "storage.sqlite.GetSession"
+	query := `SELECT id, user_id, device_name, user_agent, ip, client_id, scope, revoked, created_at, last_seen_at, expires_at
+		FROM sessions WHERE id = ?`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	session, err := scanSession(stmt.QueryRowContext(ctx, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Session{}, fmt.Errorf("%s: %w", op, ErrSessionNotFound)
+		}
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return session, nil
+}
+
+// ListSessions returns the user's sessions that are neither revoked nor
+// expired, most recently used first.
+func (s *Storage) ListSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	const op = "storage.sqlite.ListSessions"
+	query := `SELECT id, user_id, device_name, user_agent, ip, client_id, scope, revoked, created_at, last_seen_at, expires_at
+		FROM sessions WHERE user_id = ? AND revoked = 0 AND expires_at > ?
+		ORDER BY last_seen_at DESC`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	rows, err := stmt.QueryContext(ctx, userID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return sessions, nil
+}
+
+func (s *Storage) TouchSession(ctx context.Context, id string, ip string, expiresAt time.Time) error {
+	const op = "storage.sqlite.TouchSession"
+	query := "UPDATE sessions SET last_seen_at = ?, ip = ?, expires_at = ? WHERE id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, time.Now().UTC(), ip, expiresAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RevokeSession revokes one of the user's sessions together with its refresh
+// tokens. It reports false when the user has no such active session.
+func (s *Storage) RevokeSession(ctx context.Context, userID int64, id string) (bool, error) {
+	const op = "storage.sqlite.RevokeSession"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked = 1 WHERE id = ? AND user_id = ? AND revoked = 0", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE refreshTokens SET revoked = 1 WHERE family_id = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return true, nil
+}
+
+// RevokeAllSessions revokes every active session of the user except exceptID
+// together with their refresh tokens, and returns the IDs of the sessions it
+// revoked. An empty exceptID revokes all of them.
+func (s *Storage) RevokeAllSessions(ctx context.Context, userID int64, exceptID string) ([]string, error) {
+	const op = "storage.sqlite.RevokeAllSessions"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM sessions WHERE user_id = ? AND revoked = 0 AND id != ?", userID, exceptID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE sessions SET revoked = 1 WHERE user_id = ? AND id != ?", userID, exceptID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE refreshTokens SET revoked = 1 WHERE user_id = ? AND family_id != ?", userID, exceptID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ids, nil
+}
+
+// RevokeClientSessions revokes every active session of the OAuth client
+// together with their refresh tokens, and returns the IDs of the sessions it
+// revoked.
+func (s *Storage) RevokeClientSessions(ctx context.Context, clientID string) ([]string, error) {
+	const op = "storage.sqlite.RevokeClientSessions"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM sessions WHERE client_id = ? AND revoked = 0", clientID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE sessions SET revoked = 1 WHERE client_id = ?", clientID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE refreshTokens SET revoked = 1 WHERE family_id IN (SELECT id FROM sessions WHERE client_id = ?)", clientID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return ids, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row rowScanner) (models.Session, error) {
+	var session models.Session
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.DeviceName,
+		&session.UserAgent,
+		&session.IP,
+		&session.ClientID,
+		&session.Scope,
+		&session.Revoked,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.ExpiresAt,
+	)
+	return session, err
+}
@@ -6,19 +6,32 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/mattn/go-sqlite3"
 	"time"
 )
 
+const userColumns = "id, login, phone, email, pass_hash, role, token_version, email_verified, phone_verified, locale"
+
 var (
-	ErrUserNotFound = errors.New("user not found")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrTokenNotFound      = errors.New("token not found")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrFactorNotFound     = errors.New("mfa factor not found")
+	ErrCredentialNotFound = errors.New("credential not found")
+	ErrCredentialExists   = errors.New("credential already registered")
+	ErrPhoneTaken         = errors.New("phone already verified by another user")
+	ErrCodeNotFound       = errors.New("code not found")
+	ErrClientNotFound     = errors.New("client not found")
+	ErrUserCodeTaken      = errors.New("user code already in use")
 )
 
-func (s *Storage) SaveUser(ctx context.Context, email string, login string, phone string, passHash []byte) error {
+// SaveUser stores a new user and returns their ID.
+func (s *Storage) SaveUser(ctx context.Context, email string, login string, phone string, passHash []byte) (int64, error) {
 	const op = "storage.sqlite.SaveUser"
 	query := "INSERT INTO users(email, login, phone, pass_hash) VALUES (?, ?, ?, ?)"
 	stmt, err := s.db.Prepare(query)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 	defer func(stmt *sql.Stmt) {
 		err := stmt.Close()
@@ -26,16 +39,22 @@ func (s *Storage) SaveUser(ctx context.Context, email string, login string, phon
 
 		}
 	}(stmt)
-	_, err = stmt.ExecContext(ctx, email, login, phone, passHash)
+	res, err := stmt.ExecContext(ctx, email, login, phone, passHash)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return 0, fmt.Errorf("%s: %w", op, err)
 	}
-	return nil
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
 }
 
+// GetUser finds a user by email, login or phone. Only verified phone numbers
+// identify a user.
 func (s *Storage) GetUser(ctx context.Context, login string) (models.User, error) {
 	const op = "storage.sqlite.GetUser"
-	query := "SELECT id, login, phone, email, pass_hash, role FROM users WHERE email = ? OR login = ? OR phone = ?"
+	query := "SELECT " + userColumns + " FROM users WHERE email = ? OR login = ? OR (phone = ? AND phone_verified = 1)"
 	stmt, err := s.db.Prepare(query)
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
@@ -47,10 +66,32 @@ func (s *Storage) GetUser(ctx context.Context, login string) (models.User, error
 		}
 	}(stmt)
 
-	row := stmt.QueryRowContext(ctx, login, login, login)
+	user, err := scanUser(stmt.QueryRowContext(ctx, login, login, login))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
 
-	var user models.User
-	err = row.Scan(&user.ID, &user.Login, &user.Phone, &user.Email, &user.PassHash, &user.Role)
+	return user, nil
+}
+
+func (s *Storage) GetUserByID(ctx context.Context, id int64) (models.User, error) {
+	const op = "storage.sqlite.GetUserByID"
+	query := "SELECT " + userColumns + " FROM users WHERE id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	user, err := scanUser(stmt.QueryRowContext(ctx, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
@@ -71,6 +112,7 @@ func (s *Storage) UpdateUser(ctx context.Context, id int64, updates map[string]i
 		"pass_hash": true,
 		"email":     true,
 		"phone":     true,
+		"locale":    true,
 	}
 
 	for column, value := range updates {
@@ -114,7 +156,107 @@ func (s *Storage) UpdateUser(ctx context.Context, id int64, updates map[string]i
 	return true, nil
 }
 
-func (s *Storage) SaveToken(ctx context.Context, id int64, token string, expiresAt time.Time) error {
+// UpdatePassword replaces the password hash and bumps the token version, which
+// invalidates every token issued with the old password.
+func (s *Storage) UpdatePassword(ctx context.Context, id int64, passHash []byte) (bool, error) {
+	const op = "storage.sqlite.UpdatePassword"
+	query := "UPDATE users SET pass_hash = ?, token_version = token_version + 1 WHERE id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, passHash, id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected == 1, nil
+}
+
+// RehashPassword replaces the hash of an unchanged password. Unlike
+// UpdatePassword it keeps the user's tokens valid, and it does nothing if the
+// password was changed since oldHash was read.
+func (s *Storage) RehashPassword(ctx context.Context, id int64, oldHash []byte, newHash []byte) error {
+	const op = "storage.sqlite.RehashPassword"
+	query := "UPDATE users SET pass_hash = ? WHERE id = ? AND pass_hash = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, newHash, id, oldHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) SetEmailVerified(ctx context.Context, id int64, verified bool) error {
+	const op = "storage.sqlite.SetEmailVerified"
+	query := "UPDATE users SET email_verified = ? WHERE id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, verified, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SetPhoneVerified marks the user's phone number as verified or not. It fails
+// with ErrPhoneTaken when another user has already verified the number.
+func (s *Storage) SetPhoneVerified(ctx context.Context, id int64, verified bool) error {
+	const op = "storage.sqlite.SetPhoneVerified"
+	query := "UPDATE users SET phone_verified = ? WHERE id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, verified, id)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, ErrPhoneTaken)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SaveToken stores the hash of a password reset token issued to a user.
+func (s *Storage) SaveToken(ctx context.Context, id int64, tokenHash string, expiresAt time.Time) error {
 	const op = "storage.sqlite.SaveToken"
 	query := "INSERT INTO resetTokens (user_id, token, expires_at) VALUES (?, ?, ?)"
 	stmt, err := s.db.Prepare(query)
@@ -128,14 +270,15 @@ func (s *Storage) SaveToken(ctx context.Context, id int64, token string, expires
 		}
 	}(stmt)
 
-	_, err = stmt.ExecContext(ctx, id, token, expiresAt)
+	_, err = stmt.ExecContext(ctx, id, tokenHash, expiresAt)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
 
-func (s *Storage) GetToken(ctx context.Context, token string) (models.ResetToken, error) {
+// GetToken finds a password reset token by its hash.
+func (s *Storage) GetToken(ctx context.Context, tokenHash string) (models.ResetToken, error) {
 	const op = "storage.sqlite.GetToken"
 	query := "SELECT id, user_id, token, expires_at FROM resetTokens WHERE token = ?"
 	stmt, err := s.db.Prepare(query)
@@ -149,23 +292,51 @@ func (s *Storage) GetToken(ctx context.Context, token string) (models.ResetToken
 		}
 	}(stmt)
 
-	row := stmt.QueryRowContext(ctx, token)
+	row := stmt.QueryRowContext(ctx, tokenHash)
 
 	var resetToken models.ResetToken
-	err = row.Scan(&resetToken.ID, &resetToken.UserID, &resetToken.Token, &resetToken.ExpiresAt)
+	err = row.Scan(&resetToken.ID, &resetToken.UserID, &resetToken.TokenHash, &resetToken.ExpiresAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return models.ResetToken{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+			return models.ResetToken{}, fmt.Errorf("%s: %w", op, ErrTokenNotFound)
 		}
 		return models.ResetToken{}, fmt.Errorf("%s: %w", op, err)
 	}
 	return resetToken, nil
 }
 
-func (s *Storage) DeleteToken(ctx context.Context, id int64) error {
+// DeleteToken deletes a password reset token. It reports whether the token
+// was still there, so of several concurrent callers only one spends it.
+func (s *Storage) DeleteToken(ctx context.Context, id int64) (bool, error) {
 	const op = "storage.sqlite.DeleteToken"
 	query := "DELETE FROM resetTokens WHERE id = ?"
 	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	res, err := stmt.ExecContext(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return affected > 0, nil
+}
+
+// DeleteTokens deletes every password reset token issued to a user.
+func (s *Storage) DeleteTokens(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.DeleteTokens"
+	query := "DELETE FROM resetTokens WHERE user_id = ?"
+	stmt, err := s.db.Prepare(query)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -176,7 +347,7 @@ func (s *Storage) DeleteToken(ctx context.Context, id int64) error {
 		}
 	}(stmt)
 
-	_, err = stmt.ExecContext(ctx, id)
+	_, err = stmt.ExecContext(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -195,3 +366,20 @@ func isEmpty(value interface{}) bool {
 		return false
 	}
 }
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID,
+		&user.Login,
+		&user.Phone,
+		&user.Email,
+		&user.PassHash,
+		&user.Role,
+		&user.TokenVersion,
+		&user.EmailVerified,
+		&user.PhoneVerified,
+		&user.Locale,
+	)
+	return user, err
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func (s *Storage) SaveVerificationToken(ctx context.Context, token models.VerificationToken) error {
+	const op = "storage.sqlite.SaveVerificationToken"
+	query := "INSERT INTO verificationTokens (user_id, token_hash, email, expires_at) VALUES (?, ?, ?, ?)"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, token.UserID, token.TokenHash, token.Email, token.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetVerificationToken(ctx context.Context, tokenHash string) (models.VerificationToken, error) {
+	const op = "storage.sqlite.GetVerificationToken"
+	query := "SELECT id, user_id, token_hash, email, created_at, expires_at FROM verificationTokens WHERE token_hash = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.VerificationToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	row := stmt.QueryRowContext(ctx, tokenHash)
+
+	var token models.VerificationToken
+	err = row.Scan(&token.ID, &token.UserID, &token.TokenHash, &token.Email, &token.CreatedAt, &token.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.VerificationToken{}, fmt.Errorf("%s: %w", op, ErrTokenNotFound)
+		}
+		return models.VerificationToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return token, nil
+}
+
+// DeleteVerificationTokens drops every outstanding verification token of the
+// user.
+func (s *Storage) DeleteVerificationTokens(ctx context.Context, userID int64) error {
+	const op = "storage.sqlite.DeleteVerificationTokens"
+	query := "DELETE FROM verificationTokens WHERE user_id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
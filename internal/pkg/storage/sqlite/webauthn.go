@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"time"
+)
+
+const webauthnColumns = "id, user_id, credential_id, public_key, sign_count, aaguid, name, created_at, last_used_at"
+
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, credential models.WebAuthnCredential) error {
+	const op = "storage.sqlite.SaveWebAuthnCredential"
+	query := `INSERT INTO webauthnCredentials (user_id, credential_id, public_key, sign_count, aaguid, name)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx,
+		credential.UserID,
+		credential.CredentialID,
+		credential.PublicKey,
+		credential.SignCount,
+		credential.AAGUID,
+		credential.Name,
+	)
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return fmt.Errorf("%s: %w", op, ErrCredentialExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetWebAuthnCredential(ctx context.Context, credentialID []byte) (models.WebAuthnCredential, error) {
+	const op = "storage.sqlite.GetWebAuthnCredential"
+	query := "SELECT " + webauthnColumns + " FROM webauthnCredentials WHERE credential_id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	credential, err := scanWebAuthnCredential(stmt.QueryRowContext(ctx, credentialID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, ErrCredentialNotFound)
+		}
+		return models.WebAuthnCredential{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return credential, nil
+}
+
+func (s *Storage) ListWebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error) {
+	const op = "storage.sqlite.ListWebAuthnCredentials"
+	query := "SELECT " + webauthnColumns + " FROM webauthnCredentials WHERE user_id = ? ORDER BY created_at"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var credentials []models.WebAuthnCredential
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		credentials = append(credentials, credential)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return credentials, nil
+}
+
+func (s *Storage) UpdateWebAuthnSignCount(ctx context.Context, id int64, signCount uint32) error {
+	const op = "storage.sqlite.UpdateWebAuthnSignCount"
+	query := "UPDATE webauthnCredentials SET sign_count = ?, last_used_at = ? WHERE id = ?"
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, signCount, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func scanWebAuthnCredential(row rowScanner) (models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := row.Scan(
+		&credential.ID,
+		&credential.UserID,
+		&credential.CredentialID,
+		&credential.PublicKey,
+		&credential.SignCount,
+		&credential.AAGUID,
+		&credential.Name,
+		&credential.CreatedAt,
+		&credential.LastUsedAt,
+	)
+	return credential, err
+}
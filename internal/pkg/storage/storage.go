@@ -8,25 +8,199 @@ import (
 
 type (
 	UserSaver interface {
-		SaveUser(ctx context.Context, email string, login string, phone string, passHash []byte) error
+		SaveUser(ctx context.Context, email string, login string, phone string, passHash []byte) (int64, error)
 	}
 
 	UserProvider interface {
 		GetUser(ctx context.Context, login string) (models.User, error)
+		GetUserByID(ctx context.Context, id int64) (models.User, error)
 	}
 
 	UserUpdater interface {
 		UpdateUser(ctx context.Context, id int64, updates map[string]interface{}) (bool, error)
+		UpdatePassword(ctx context.Context, id int64, passHash []byte) (bool, error)
+		RehashPassword(ctx context.Context, id int64, oldHash []byte, newHash []byte) error
+		SetEmailVerified(ctx context.Context, id int64, verified bool) error
+		SetPhoneVerified(ctx context.Context, id int64, verified bool) error
 	}
 
 	TokenSaver interface {
-		SaveToken(ctx context.Context, id int64, token string, expiresAt time.Time) error
+		SaveToken(ctx context.Context, id int64, tokenHash string, expiresAt time.Time) error
 	}
 
 	TokenProvider interface {
-		GetToken(ctx context.Context, token string) (models.ResetToken, error)
+		GetToken(ctx context.Context, tokenHash string) (models.ResetToken, error)
 	}
 	TokenUpdater interface {
-		DeleteToken(ctx context.Context, id int64) error
+		DeleteToken(ctx context.Context, id int64) (bool, error)
+		DeleteTokens(ctx context.Context, userID int64) error
+	}
+
+	RefreshTokenSaver interface {
+		SaveRefreshToken(ctx context.Context, userID int64, tokenHash string, familyID string, expiresAt time.Time) error
+	}
+
+	RefreshTokenProvider interface {
+		GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	}
+
+	RefreshTokenUpdater interface {
+		UseRefreshToken(ctx context.Context, id int64) (bool, error)
+		RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	}
+
+	SessionSaver interface {
+		SaveSession(ctx context.Context, session models.Session) error
+	}
+
+	SessionProvider interface {
+		GetSession(ctx context.Context, id string) (models.Session, error)
+		ListSessions(ctx context.Context, userID int64) ([]models.Session, error)
+	}
+
+	SessionUpdater interface {
+		TouchSession(ctx context.Context, id string, ip string, expiresAt time.Time) error
+		RevokeSession(ctx context.Context, userID int64, id string) (bool, error)
+		RevokeAllSessions(ctx context.Context, userID int64, exceptID string) ([]string, error)
+		RevokeClientSessions(ctx context.Context, clientID string) ([]string, error)
+	}
+
+	MFASaver interface {
+		SaveMFAFactor(ctx context.Context, userID int64, secret []byte) error
+		SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error
+	}
+
+	MFAProvider interface {
+		GetMFAFactor(ctx context.Context, userID int64) (models.MFAFactor, error)
+		GetMFAChallenge(ctx context.Context, tokenHash string) (models.MFAChallenge, error)
+	}
+
+	MFAUpdater interface {
+		ConfirmMFAFactor(ctx context.Context, id int64, step int64) error
+		UseMFAStep(ctx context.Context, id int64, step int64) (bool, error)
+		AddMFAChallengeAttempt(ctx context.Context, id int64, maxAttempts int) (bool, error)
+		DeleteMFAChallenge(ctx context.Context, id int64) (bool, error)
+	}
+
+	VerificationTokenSaver interface {
+		SaveVerificationToken(ctx context.Context, token models.VerificationToken) error
+	}
+
+	VerificationTokenProvider interface {
+		GetVerificationToken(ctx context.Context, tokenHash string) (models.VerificationToken, error)
+	}
+
+	VerificationTokenUpdater interface {
+		DeleteVerificationTokens(ctx context.Context, userID int64) error
+	}
+
+	PhoneCodeSaver interface {
+		SavePhoneCode(ctx context.Context, code models.PhoneCode) error
+	}
+
+	PhoneCodeProvider interface {
+		GetPhoneCode(ctx context.Context, userID int64) (models.PhoneCode, error)
+	}
+
+	PhoneCodeUpdater interface {
+		AddPhoneCodeAttempt(ctx context.Context, id int64, maxAttempts int) (bool, error)
+		DeletePhoneCode(ctx context.Context, userID int64) error
+	}
+
+	RecoveryCodeSaver interface {
+		ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes [][]byte) error
+	}
+
+	RecoveryCodeProvider interface {
+		ListRecoveryCodes(ctx context.Context, userID int64) ([]models.RecoveryCode, error)
+	}
+
+	RecoveryCodeUpdater interface {
+		UseRecoveryCode(ctx context.Context, userID int64, codeHash []byte) (bool, error)
+	}
+
+	WebAuthnSaver interface {
+		SaveWebAuthnCredential(ctx context.Context, credential models.WebAuthnCredential) error
+	}
+
+	WebAuthnProvider interface {
+		GetWebAuthnCredential(ctx context.Context, credentialID []byte) (models.WebAuthnCredential, error)
+		ListWebAuthnCredentials(ctx context.Context, userID int64) ([]models.WebAuthnCredential, error)
+	}
+
+	WebAuthnUpdater interface {
+		UpdateWebAuthnSignCount(ctx context.Context, id int64, signCount uint32) error
+	}
+
+	MagicLinkSaver interface {
+		SaveMagicLink(ctx context.Context, link models.MagicLink) error
+	}
+
+	MagicLinkProvider interface {
+		GetMagicLink(ctx context.Context, tokenHash string) (models.MagicLink, error)
+	}
+
+	MagicLinkUpdater interface {
+		UseMagicLink(ctx context.Context, id int64) (bool, error)
+	}
+
+	AccountLockProvider interface {
+		GetAccountLock(ctx context.Context, userID int64) (models.AccountLock, error)
+	}
+
+	AccountLockUpdater interface {
+		ReserveLoginAttempt(ctx context.Context, userID int64) (models.AccountLock, error)
+		ReleaseLoginAttempt(ctx context.Context, userID int64) error
+		RecordFailedLogin(ctx context.Context, userID int64, at time.Time) error
+		LockAccount(ctx context.Context, userID int64, until time.Time) error
+		UnlockAccount(ctx context.Context, userID int64) (bool, error)
+	}
+
+	OAuthClientSaver interface {
+		SaveOAuthClient(ctx context.Context, client models.OAuthClient) error
+	}
+
+	OAuthClientProvider interface {
+		GetOAuthClient(ctx context.Context, id string) (models.OAuthClient, error)
+	}
+
+	OAuthClientUpdater interface {
+		UpdateOAuthClientSecret(ctx context.Context, id string, secretHash string) (bool, error)
+		DisableOAuthClient(ctx context.Context, id string) (bool, error)
+	}
+
+	AuthorizationCodeSaver interface {
+		SaveAuthorizationCode(ctx context.Context, code models.AuthorizationCode) error
+	}
+
+	AuthorizationCodeProvider interface {
+		GetAuthorizationCode(ctx context.Context, codeHash string) (models.AuthorizationCode, error)
+	}
+
+	AuthorizationCodeUpdater interface {
+		UseAuthorizationCode(ctx context.Context, id int64, sessionID string) (bool, error)
+	}
+
+	OAuthConsentSaver interface {
+		SaveOAuthConsent(ctx context.Context, consent models.OAuthConsent) error
+	}
+
+	OAuthConsentProvider interface {
+		GetOAuthConsent(ctx context.Context, userID int64, clientID string) (models.OAuthConsent, error)
+	}
+
+	DeviceAuthorizationSaver interface {
+		SaveDeviceAuthorization(ctx context.Context, auth models.DeviceAuthorization) error
+	}
+
+	DeviceAuthorizationProvider interface {
+		GetDeviceAuthorization(ctx context.Context, deviceCodeHash string) (models.DeviceAuthorization, error)
+		GetDeviceAuthorizationByUserCode(ctx context.Context, userCodeHash string) (models.DeviceAuthorization, error)
+	}
+
+	DeviceAuthorizationUpdater interface {
+		DecideDeviceAuthorization(ctx context.Context, id int64, status string, userID int64, authTime time.Time) (bool, error)
+		PollDeviceAuthorization(ctx context.Context, id int64, polledAt time.Time, interval time.Duration) error
+		UseDeviceAuthorization(ctx context.Context, id int64, sessionID string) (bool, error)
 	}
 )
@@ -3,190 +3,684 @@ package auth
 import (
 	"AuthGrpc/internal/cache"
 	"AuthGrpc/internal/cache/local"
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/jwk"
 	"AuthGrpc/internal/lib/encryption/jwt"
+	"AuthGrpc/internal/lib/encryption/keyring"
 	"AuthGrpc/internal/lib/encryption/token"
+	"AuthGrpc/internal/lib/password"
+	"AuthGrpc/internal/lib/webauthn"
+	"AuthGrpc/internal/pkg/i18n"
+	"AuthGrpc/internal/pkg/notifier"
 	"AuthGrpc/internal/pkg/storage"
 	"AuthGrpc/internal/pkg/storage/sqlite"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"golang.org/x/crypto/bcrypt"
 	"log/slog"
+	"os"
+	"strconv"
 	"time"
 )
 
+var (
+	ErrInvalidToken        = errors.New("invalid token")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrInvalidResetToken   = errors.New("invalid reset token")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
 type Auth struct {
-	log             *slog.Logger
-	userProvider    storage.UserProvider
-	userSaver       storage.UserSaver
-	userUpdater     storage.UserUpdater
-	tokenSaver      storage.TokenSaver
-	tokenProvider   storage.TokenProvider
-	tokenUpdater    storage.TokenUpdater
-	cache           cache.Cacher
-	secretKey       []byte
-	accessTokenTTL  time.Duration
-	refreshTokenTTL time.Duration
-}
-
-func New(log *slog.Logger, storage *sqlite.Storage, cache *local.Cache, accessTokenTTL time.Duration, refreshTokenTTL time.Duration, secretKey []byte) *Auth {
-	return &Auth{
-		userSaver:       storage,
-		userProvider:    storage,
-		userUpdater:     storage,
-		tokenSaver:      storage,
-		tokenProvider:   storage,
-		tokenUpdater:    storage,
-		log:             log,
-		cache:           cache,
-		secretKey:       secretKey,
-		accessTokenTTL:  accessTokenTTL,
-		refreshTokenTTL: refreshTokenTTL,
-	}
-}
-
-func (a *Auth) Login(ctx context.Context, login string, password string) (string, error) {
+	log                       *slog.Logger
+	userProvider              storage.UserProvider
+	userSaver                 storage.UserSaver
+	userUpdater               storage.UserUpdater
+	tokenSaver                storage.TokenSaver
+	tokenProvider             storage.TokenProvider
+	tokenUpdater              storage.TokenUpdater
+	verificationTokenSaver    storage.VerificationTokenSaver
+	verificationTokenProvider storage.VerificationTokenProvider
+	verificationTokenUpdater  storage.VerificationTokenUpdater
+	phoneCodeSaver            storage.PhoneCodeSaver
+	phoneCodeProvider         storage.PhoneCodeProvider
+	phoneCodeUpdater          storage.PhoneCodeUpdater
+	refreshTokenSaver         storage.RefreshTokenSaver
+	refreshTokenProvider      storage.RefreshTokenProvider
+	refreshTokenUpdater       storage.RefreshTokenUpdater
+	sessionSaver              storage.SessionSaver
+	sessionProvider           storage.SessionProvider
+	sessionUpdater            storage.SessionUpdater
+	mfaSaver                  storage.MFASaver
+	mfaProvider               storage.MFAProvider
+	mfaUpdater                storage.MFAUpdater
+	recoveryCodeSaver         storage.RecoveryCodeSaver
+	recoveryCodeProvider      storage.RecoveryCodeProvider
+	recoveryCodeUpdater       storage.RecoveryCodeUpdater
+	webauthnSaver             storage.WebAuthnSaver
+	webauthnProvider          storage.WebAuthnProvider
+	webauthnUpdater           storage.WebAuthnUpdater
+	magicLinkSaver            storage.MagicLinkSaver
+	magicLinkProvider         storage.MagicLinkProvider
+	magicLinkUpdater          storage.MagicLinkUpdater
+	accountLockProvider       storage.AccountLockProvider
+	accountLockUpdater        storage.AccountLockUpdater
+	oauthClientSaver          storage.OAuthClientSaver
+	oauthClientProvider       storage.OAuthClientProvider
+	oauthClientUpdater        storage.OAuthClientUpdater
+	authorizationCodeSaver    storage.AuthorizationCodeSaver
+	authorizationCodeProvider storage.AuthorizationCodeProvider
+	authorizationCodeUpdater  storage.AuthorizationCodeUpdater
+	oauthConsentSaver         storage.OAuthConsentSaver
+	oauthConsentProvider      storage.OAuthConsentProvider
+	deviceAuthSaver           storage.DeviceAuthorizationSaver
+	deviceAuthProvider        storage.DeviceAuthorizationProvider
+	deviceAuthUpdater         storage.DeviceAuthorizationUpdater
+	cache                     cache.Cacher
+	keys                      *keyring.Ring
+	tokenOptions              jwt.Options
+	accessTokenTTL            time.Duration
+	refreshTokenTTL           time.Duration
+	mfaKey                    []byte
+	mfaIssuer                 string
+	mfaChallengeTTL           time.Duration
+	webauthn                  *webauthn.Config
+	notifier                  notifier.Notifier
+	links                     Links
+	catalog                   *i18n.Catalog
+	lockout                   LockoutPolicy
+	passwordPolicy            password.Policy
+	hasher                    password.Hasher
+	reportBreachedLogins      bool
+	requireVerifiedEmail      bool
+	verificationTokenTTL      time.Duration
+	magicLinkTTL              time.Duration
+	oauth                     OAuthSettings
+}
+
+func New(log *slog.Logger, storage *sqlite.Storage, cache *local.Cache, accessTokenTTL time.Duration, refreshTokenTTL time.Duration, keys *keyring.Ring, tokenOptions jwt.Options, opts ...Option) *Auth {
+	a := &Auth{
+		userSaver:                 storage,
+		userProvider:              storage,
+		userUpdater:               storage,
+		tokenSaver:                storage,
+		tokenProvider:             storage,
+		tokenUpdater:              storage,
+		verificationTokenSaver:    storage,
+		verificationTokenProvider: storage,
+		verificationTokenUpdater:  storage,
+		phoneCodeSaver:            storage,
+		phoneCodeProvider:         storage,
+		phoneCodeUpdater:          storage,
+		refreshTokenSaver:         storage,
+		refreshTokenProvider:      storage,
+		refreshTokenUpdater:       storage,
+		sessionSaver:              storage,
+		sessionProvider:           storage,
+		sessionUpdater:            storage,
+		mfaSaver:                  storage,
+		mfaProvider:               storage,
+		mfaUpdater:                storage,
+		recoveryCodeSaver:         storage,
+		recoveryCodeProvider:      storage,
+		recoveryCodeUpdater:       storage,
+		webauthnSaver:             storage,
+		webauthnProvider:          storage,
+		webauthnUpdater:           storage,
+		magicLinkSaver:            storage,
+		magicLinkProvider:         storage,
+		magicLinkUpdater:          storage,
+		accountLockProvider:       storage,
+		accountLockUpdater:        storage,
+		oauthClientSaver:          storage,
+		oauthClientProvider:       storage,
+		oauthClientUpdater:        storage,
+		authorizationCodeSaver:    storage,
+		authorizationCodeProvider: storage,
+		authorizationCodeUpdater:  storage,
+		oauthConsentSaver:         storage,
+		oauthConsentProvider:      storage,
+		deviceAuthSaver:           storage,
+		deviceAuthProvider:        storage,
+		deviceAuthUpdater:         storage,
+		log:                       log,
+		cache:                     cache,
+		keys:                      keys,
+		tokenOptions:              tokenOptions,
+		accessTokenTTL:            accessTokenTTL,
+		refreshTokenTTL:           refreshTokenTTL,
+		mfaIssuer:                 defaultMFAIssuer,
+		mfaChallengeTTL:           defaultMFAChallengeTTL,
+		verificationTokenTTL:      defaultVerificationTokenTTL,
+		magicLinkTTL:              defaultMagicLinkTTL,
+		oauth:                     defaultOAuthSettings,
+		notifier:                  notifier.NewWriterSender(os.Stdout),
+		catalog:                   i18n.MustLoad("en"),
+		lockout:                   defaultLockoutPolicy,
+		passwordPolicy:            password.DefaultPolicy(),
+		hasher:                    password.NewArgon2idHasher(password.DefaultArgon2Params),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+// Login checks the password. Users with a confirmed second factor get an MFA
+// token to pass to VerifyMFA instead of a token pair.
+func (a *Auth) Login(ctx context.Context, login string, password string, client models.ClientInfo) (models.LoginResult, error) {
 	const op = "auth.login"
 
+	if err := a.checkIPFailures(ctx, client.IP); err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
 	user, err := a.userProvider.GetUser(ctx, login)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, sqlite.ErrUserNotFound) {
+			a.addIPFailure(ctx, client.IP)
+			return models.LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.checkPassword(ctx, user, password, client.IP); err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	a.reportBreachedPassword(user, password)
+	a.rehashPassword(ctx, user, password)
+
+	result, err := a.firstFactorPassed(ctx, user, client)
+	if err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return result, nil
+}
+
+// firstFactorPassed finishes a login whose first factor checked out: users
+// with a confirmed second factor get an MFA challenge, everyone else tokens.
+func (a *Auth) firstFactorPassed(ctx context.Context, user models.User, client models.ClientInfo) (models.LoginResult, error) {
+	if err := a.checkEmailVerified(user); err != nil {
+		return models.LoginResult{}, err
+	}
+
+	enrolled, err := a.mfaEnrolled(ctx, user.ID)
+	if err != nil {
+		return models.LoginResult{}, err
+	}
+	if enrolled {
+		mfaToken, err := a.startMFAChallenge(ctx, user.ID, client)
+		if err != nil {
+			return models.LoginResult{}, err
+		}
+		return models.LoginResult{MFAToken: mfaToken}, nil
+	}
+
+	tokens, err := a.signIn(ctx, user, client)
+	if err != nil {
+		return models.LoginResult{}, err
+	}
+	return models.LoginResult{Tokens: tokens}, nil
+}
+
+// reportBreachedPassword logs users who sign in with a password found in the
+// breach list, so they can be made to change it.
+func (a *Auth) reportBreachedPassword(user models.User, password string) {
+	if !a.reportBreachedLogins || a.passwordPolicy.Breaches == nil {
+		return
+	}
+	count, err := a.passwordPolicy.Breaches.Count(password)
+	if err != nil {
+		a.log.Error("failed to check breached passwords", slog.String("error", err.Error()))
+		return
+	}
+	if count > 0 {
+		a.log.Warn("login with breached password", slog.Int64("uid", user.ID), slog.Int("breaches", count))
+	}
+}
+
+// rehashPassword replaces a hash made with an older algorithm or parameters
+// once the password is known to be right. Failing to is only logged.
+func (a *Auth) rehashPassword(ctx context.Context, user models.User, password string) {
+	if !a.hasher.NeedsRehash(user.PassHash) {
+		return
+	}
+	passHash, err := a.hasher.Hash(password)
+	if err != nil {
+		a.log.Error("failed to rehash password", slog.Int64("uid", user.ID), slog.String("error", err.Error()))
+		return
+	}
+	if err := a.userUpdater.RehashPassword(ctx, user.ID, user.PassHash, passHash); err != nil {
+		a.log.Error("failed to rehash password", slog.Int64("uid", user.ID), slog.String("error", err.Error()))
+	}
+}
+
+// signIn opens a session for an authenticated user and issues its tokens.
+func (a *Auth) signIn(ctx context.Context, user models.User, client models.ClientInfo) (models.TokenPair, error) {
+	if err := a.checkEmailVerified(user); err != nil {
+		return models.TokenPair{}, err
+	}
+	if err := a.clearFailedLogins(ctx, user.ID); err != nil {
+		return models.TokenPair{}, err
+	}
+	a.alertNewLogin(ctx, user, client)
+	session, err := a.startSession(ctx, user.ID, client, "", "")
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	return a.issueTokens(ctx, user, session)
+}
+
+// Refresh exchanges a refresh token for a new token pair. Refresh tokens are
+// single-use: presenting one that was already exchanged revokes every token
+// issued from the same login. Refresh tokens of OAuth clients are only
+// accepted at /token.
+func (a *Auth) Refresh(ctx context.Context, refreshToken string, client models.ClientInfo) (models.TokenPair, error) {
+	return a.refresh(ctx, refreshToken, "", client)
+}
+
+// refresh rotates a refresh token of a session belonging to clientID, which
+// is empty for first-party sessions.
+func (a *Auth) refresh(ctx context.Context, refreshToken string, clientID string, client models.ClientInfo) (models.TokenPair, error) {
+	const op = "auth.Refresh"
+
+	stored, err := a.refreshTokenProvider.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sqlite.ErrTokenNotFound) {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+		}
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if stored.Revoked || time.Now().After(stored.ExpiresAt) {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+	}
+	// The refresh token family is the session.
+	session, err := a.sessionProvider.GetSession(ctx, stored.FamilyID)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrSessionNotFound) {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+		}
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if session.ClientID != clientID {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+	}
+
+	ok, err := a.refreshTokenUpdater.UseRefreshToken(ctx, stored.ID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		a.log.Warn("refresh token reuse detected",
+			slog.Int64("uid", stored.UserID),
+			slog.String("family", stored.FamilyID),
+		)
+		if err := a.refreshTokenUpdater.RevokeRefreshTokenFamily(ctx, stored.FamilyID); err != nil {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := a.revokeSession(ctx, stored.UserID, stored.FamilyID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrRefreshTokenReused)
+	}
+
+	if session.Revoked {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+	}
+	err = a.sessionUpdater.TouchSession(ctx, session.ID, client.IP, time.Now().Add(a.refreshTokenTTL))
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(password)); err != nil {
-		a.log.Info("invalid credentials", err)
-		return "", fmt.Errorf("%s: %w", op, err)
+	tokens, err := a.issueTokens(ctx, user, session)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
+	return tokens, nil
+}
+
+// ValidateToken checks the signature and lifetime of an access token, that
+// its session has not been revoked and that it was issued after the last
+// password change. Tokens issued to OAuth clients also need the client to
+// be enabled; a client_credentials token has no user and only that check.
+func (a *Auth) ValidateToken(ctx context.Context, jwtToken string) (jwt.AccessToken, error) {
+	const op = "auth.ValidateToken"
 
-	jwtToken, err := jwt.GenerateToken(ctx, user, a.accessTokenTTL, a.secretKey)
+	accessToken, err := jwt.ParseToken(ctx, jwtToken, a.keys, a.tokenOptions)
 	if err != nil {
-		a.log.Error("failed to generate token", err)
-		return "", fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, jwt.ErrInvalidToken) {
+			return jwt.AccessToken{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		return jwt.AccessToken{}, fmt.Errorf("%s: %w", op, err)
 	}
-	hash := sha256.Sum256([]byte(jwtToken))
-	key := hex.EncodeToString(hash[:])
-	value := user.ID
-	err = a.cache.Set(ctx, key, value, a.accessTokenTTL*time.Second)
+
+	if accessToken.ClientID != "" {
+		active, err := a.clientActive(ctx, accessToken.ClientID)
+		if err != nil {
+			return jwt.AccessToken{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if !active {
+			return jwt.AccessToken{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+		// A client_credentials token is the client's own, with no user.
+		if accessToken.Sid == "" && accessToken.Uid == 0 {
+			return accessToken, nil
+		}
+	}
+
+	active, err := a.sessionActive(ctx, accessToken.Sid)
 	if err != nil {
-		a.log.Error("failed to save in cache", err)
+		return jwt.AccessToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !active {
+		return jwt.AccessToken{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
 	}
 
-	return jwtToken, nil
+	version, err := a.tokenVersion(ctx, accessToken.Uid)
+	if err != nil {
+		return jwt.AccessToken{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if accessToken.Ver != version {
+		return jwt.AccessToken{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+	return accessToken, nil
+}
+
+// firstPartyToken validates an access token presented to the service's own
+// RPCs. Tokens issued to OAuth clients only carry what the user granted the
+// client, so they cannot act on the account here.
+func (a *Auth) firstPartyToken(ctx context.Context, jwtToken string) (jwt.AccessToken, error) {
+	accessToken, err := a.ValidateToken(ctx, jwtToken)
+	if err != nil {
+		return jwt.AccessToken{}, err
+	}
+	if accessToken.ClientID != "" {
+		return jwt.AccessToken{}, ErrInvalidToken
+	}
+	return accessToken, nil
+}
+
+// PublicKeys returns the keys that verify tokens issued by this service.
+func (a *Auth) PublicKeys(ctx context.Context) ([]jwk.Key, error) {
+	const op = "auth.PublicKeys"
+
+	var keys []jwk.Key
+	for _, key := range a.keys.Keys() {
+		publicKey, err := jwk.FromPublicKey(key.PrivateKey.Public(), string(key.Algorithm))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, publicKey)
+	}
+	return keys, nil
+}
+
+func (a *Auth) issueTokens(ctx context.Context, user models.User, session models.Session) (models.TokenPair, error) {
+	signingKey, err := a.keys.Active()
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	jwtToken, err := jwt.GenerateToken(ctx, user, session, a.accessTokenTTL, signingKey.Signer(), a.tokenOptions)
+	if err != nil {
+		a.log.Error("failed to generate token", slog.String("error", err.Error()))
+		return models.TokenPair{}, err
+	}
+	err = a.cache.Set(ctx, sessionKey(session.ID), user.ID, a.accessTokenTTL)
+	if err != nil {
+		a.log.Error("failed to save in cache", slog.String("error", err.Error()))
+	}
+
+	refreshToken, err := token.GenerateToken()
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	expiresAt := time.Now().Add(a.refreshTokenTTL)
+	err = a.refreshTokenSaver.SaveRefreshToken(ctx, user.ID, hashToken(refreshToken), session.ID, expiresAt)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return models.TokenPair{AccessToken: jwtToken, RefreshToken: refreshToken}, nil
 }
 
 func (a *Auth) RegisterNewUser(ctx context.Context, email, login, phone, password string) (bool, error) {
 	const op = "auth.RegisterNewUser"
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err := a.passwordPolicy.Check(password, login, email); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	passHash, err := a.hasher.Hash(password)
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = a.userSaver.SaveUser(ctx, email, login, phone, passHash)
+	id, err := a.userSaver.SaveUser(ctx, email, login, phone, passHash)
 	if err != nil {
 		return false, nil
 	}
+	user, err := a.userProvider.GetUserByID(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.issueVerificationToken(ctx, user); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
 	return true, nil
 }
 
 func (a *Auth) LogOut(ctx context.Context, jwtToken string) (bool, error) {
 	const op = "auth.LogOut"
-	hash := sha256.Sum256([]byte(jwtToken))
-	key := hex.EncodeToString(hash[:])
 
-	if err := a.cache.Delete(ctx, key); err != nil {
-		a.log.Error("failed to delete in cache", err)
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.revokeSession(ctx, accessToken.Uid, accessToken.Sid); err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 	return true, nil
 }
 
-func (a *Auth) ChangePassword(ctx context.Context, login, oldPassword, newPassword string) (bool, error) {
+// ChangePassword replaces the password of a user who knows the old one. Wrong
+// old passwords count towards the same lockout as failed logins.
+func (a *Auth) ChangePassword(ctx context.Context, login, oldPassword, newPassword string, client models.ClientInfo) (bool, error) {
 	const op = "auth.ChangePassword"
+
+	if err := a.checkIPFailures(ctx, client.IP); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
 	user, err := a.userProvider.GetUser(ctx, login)
 	if err != nil {
+		if errors.Is(err, sqlite.ErrUserNotFound) {
+			a.addIPFailure(ctx, client.IP)
+			return false, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.checkPassword(ctx, user, oldPassword, client.IP); err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
-	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(oldPassword)); err != nil {
-		a.log.Info("invalid credentials", err)
+	if err := a.passwordPolicy.Check(newPassword, user.Login, user.Email); err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
-	passHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	passHash, err := a.hasher.Hash(newPassword)
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	updates := map[string]interface{}{
-		"pass_hash": passHash,
-	}
-	_, err = a.userUpdater.UpdateUser(ctx, user.ID, updates)
+	_, err = a.userUpdater.UpdatePassword(ctx, user.ID, passHash)
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
+	if err := a.invalidateTokens(ctx, user.ID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
 	return true, nil
 }
 
-func (a *Auth) ForgotPassword(ctx context.Context, login string) (string, error) {
+// ForgotPassword emails the user a link to reset their password. It succeeds
+// for unknown logins too, so it cannot be used to probe for accounts.
+func (a *Auth) ForgotPassword(ctx context.Context, login string) (bool, error) {
 	const op = "auth.ForgotPassword"
 	user, err := a.userProvider.GetUser(ctx, login)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		if errors.Is(err, sqlite.ErrUserNotFound) {
+			return true, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
 	}
 	resetToken, err := token.GenerateToken()
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		return false, fmt.Errorf("%s: %w", op, err)
 	}
 	expiresAt := time.Now().Add(24 * time.Hour)
-	err = a.tokenSaver.SaveToken(ctx, user.ID, resetToken, expiresAt)
+	err = a.tokenSaver.SaveToken(ctx, user.ID, hashToken(resetToken), expiresAt)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.sendResetPasswordEmail(ctx, user, resetToken, expiresAt); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return resetToken, nil
+	return true, nil
 }
 
 func (a *Auth) ResetPassword(ctx context.Context, token, newPassword string) (bool, error) {
-	const op = "auth.ForgotPassword"
+	const op = "auth.ResetPassword"
 
-	resetToken, err := a.tokenProvider.GetToken(ctx, token)
+	resetToken, err := a.tokenProvider.GetToken(ctx, hashToken(token))
 	if err != nil {
+		if errors.Is(err, sqlite.ErrTokenNotFound) {
+			return false, fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+		}
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
-	passHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if time.Now().After(resetToken.ExpiresAt) {
+		if _, err := a.tokenUpdater.DeleteToken(ctx, resetToken.ID); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+	}
+	user, err := a.userProvider.GetUserByID(ctx, resetToken.UserID)
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
-	updates := map[string]interface{}{
-		"pass_hash": passHash,
+	if err := a.passwordPolicy.Check(newPassword, user.Login, user.Email); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	passHash, err := a.hasher.Hash(newPassword)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
 	}
-	result, err := a.userUpdater.UpdateUser(ctx, resetToken.UserID, updates)
+	// Spend the token before using it, so two concurrent resets with the same
+	// token cannot both go through.
+	spent, err := a.tokenUpdater.DeleteToken(ctx, resetToken.ID)
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
-	err = a.tokenUpdater.DeleteToken(ctx, resetToken.ID)
+	if !spent {
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+	}
+	result, err := a.userUpdater.UpdatePassword(ctx, resetToken.UserID, passHash)
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
+	if err := a.invalidateTokens(ctx, resetToken.UserID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
 	return result, nil
 }
 
-func (a *Auth) UpdateUser(ctx context.Context, jwtToken string, email string, phone string) (bool, error) {
+func (a *Auth) UpdateUser(ctx context.Context, jwtToken string, email string, phone string, locale string) (bool, error) {
 	const op = "auth.UpdateUSer"
-	hash := sha256.Sum256([]byte(jwtToken))
-	key := hex.EncodeToString(hash[:])
-	user, ok := a.cache.Get(ctx, key)
-	if !ok {
-		a.log.Error("failed to find user in cache")
-		return false, fmt.Errorf("%s: %w", op, ok)
+	if locale != "" {
+		if !a.catalog.Supports(locale) {
+			return false, fmt.Errorf("%s: %w", op, ErrUnsupportedLocale)
+		}
+		locale = a.catalog.Match(locale)
+	}
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := a.userProvider.GetUserByID(ctx, accessToken.Uid)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	// A new number has to be verified again. This happens first so the old
+	// verification is not carried over to the new number.
+	if phone != "" && phone != user.Phone {
+		if err := a.userUpdater.SetPhoneVerified(ctx, user.ID, false); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
 	}
 	updates := map[string]interface{}{
-		"email": email,
-		"phone": phone,
+		"email":  email,
+		"phone":  phone,
+		"locale": locale,
 	}
-	_, err := a.userUpdater.UpdateUser(ctx, user.(int64), updates)
+	_, err = a.userUpdater.UpdateUser(ctx, accessToken.Uid, updates)
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
+
+	// A new address has to be verified again.
+	if email != "" && email != user.Email {
+		if err := a.userUpdater.SetEmailVerified(ctx, user.ID, false); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		user.Email = email
+		if err := a.issueVerificationToken(ctx, user); err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+	}
 	return true, nil
 }
+
+// tokenVersion returns the user's current token version, cached for the
+// access token TTL.
+func (a *Auth) tokenVersion(ctx context.Context, userID int64) (int64, error) {
+	if version, ok := a.cache.Get(ctx, tokenVersionKey(userID)); ok {
+		return version.(int64), nil
+	}
+
+	user, err := a.userProvider.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrUserNotFound) {
+			return 0, ErrInvalidToken
+		}
+		return 0, err
+	}
+	if err := a.cache.Set(ctx, tokenVersionKey(userID), user.TokenVersion, a.accessTokenTTL); err != nil {
+		a.log.Error("failed to save in cache", slog.String("error", err.Error()))
+	}
+	return user.TokenVersion, nil
+}
+
+// invalidateTokens is called after the token version was bumped: it drops the
+// cached version, the user's outstanding reset tokens and signs the user out
+// of every session.
+func (a *Auth) invalidateTokens(ctx context.Context, userID int64) error {
+	_ = a.cache.Delete(ctx, tokenVersionKey(userID))
+	if err := a.tokenUpdater.DeleteTokens(ctx, userID); err != nil {
+		return err
+	}
+	_, err := a.revokeAllSessions(ctx, userID, "")
+	return err
+}
+
+func tokenVersionKey(userID int64) string {
+	return "tokenVersion:" + strconv.FormatInt(userID, 10)
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,426 @@
+package auth
+
+import (
+	"AuthGrpc/internal/cache/local"
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/jwt"
+	"AuthGrpc/internal/lib/encryption/keyring"
+	"AuthGrpc/internal/lib/encryption/signer"
+	"AuthGrpc/internal/lib/encryption/totp"
+	"AuthGrpc/internal/lib/webauthn"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testPassword    = "Corr3ct-Horse-Battery!"
+	testClientID    = "test-client"
+	testRedirectURI = "https://client.example.com/callback"
+	testVerifier    = "dBjftJeZ4CVP-mJ92K1rWQfo6Ozw9nBvaVxYDsnvJ9k"
+)
+
+// testAuth is an Auth backed by a fresh database in a temporary directory.
+type testAuth struct {
+	*Auth
+	dbPath string
+}
+
+func newTestAuth(t *testing.T, opts ...Option) testAuth {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	dbPath := filepath.Join(dir, "db.sqlite")
+	st, err := sqlite.New(ctx, dbPath)
+	if err != nil {
+		t.Fatalf("sqlite.New: %v", err)
+	}
+	c, err := local.InitCache(ctx, time.Minute, time.Minute)
+	if err != nil {
+		t.Fatalf("local.InitCache: %v", err)
+	}
+	keys, err := keyring.New(log, filepath.Join(dir, "keys"), "", signer.ES256, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("keyring.New: %v", err)
+	}
+	opts = append([]Option{
+		WithMFA(bytes.Repeat([]byte{7}, 32), "test", time.Minute),
+		WithWebAuthn(webauthn.Config{RPID: "example.com", RPName: "Example", Origins: []string{"https://example.com"}}),
+		WithOAuth(OAuthSettings{LoginURL: "https://example.com/login", VerificationURL: "https://example.com/device"}),
+	}, opts...)
+	a := New(log, st, c, time.Minute, time.Hour, keys, jwt.Options{Issuer: "https://auth.example.com"}, opts...)
+	return testAuth{Auth: a, dbPath: dbPath}
+}
+
+// exec runs a statement against the test database, for state the service
+// has no API for.
+func (ta testAuth) exec(t *testing.T, query string, args ...any) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ta.dbPath)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("exec %q: %v", query, err)
+	}
+}
+
+// newUser registers a user with testPassword.
+func (ta testAuth) newUser(t *testing.T, login string) {
+	t.Helper()
+	ok, err := ta.RegisterNewUser(context.Background(), login+"@example.com", login, "+1555"+login, testPassword)
+	if err != nil || !ok {
+		t.Fatalf("RegisterNewUser(%s) = %v, %v", login, ok, err)
+	}
+}
+
+// login signs the user in with testPassword and returns their tokens.
+func (ta testAuth) login(t *testing.T, login string) models.TokenPair {
+	t.Helper()
+	result, err := ta.Login(context.Background(), login, testPassword, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login(%s): %v", login, err)
+	}
+	return result.Tokens
+}
+
+// enrollTOTP enrolls and confirms a TOTP factor for the owner of accessToken
+// and returns its secret.
+func (ta testAuth) enrollTOTP(t *testing.T, accessToken string) string {
+	t.Helper()
+	ctx := context.Background()
+	secret, _, err := ta.EnrollTOTP(ctx, accessToken)
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatalf("totp.Code: %v", err)
+	}
+	if _, err := ta.ConfirmTOTP(ctx, accessToken, code); err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+	return secret
+}
+
+// registerPublicClient registers testClientID as a public client.
+func (ta testAuth) registerPublicClient(t *testing.T) {
+	t.Helper()
+	err := ta.RegisterOAuthClient(context.Background(), models.OAuthClient{
+		ID:           testClientID,
+		RedirectURIs: []string{testRedirectURI},
+		Scopes:       []string{"profile"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterOAuthClient: %v", err)
+	}
+}
+
+// authorizationRequest starts /authorize for testClientID and returns the ID
+// of the pending request.
+func (ta testAuth) authorizationRequest(t *testing.T) string {
+	t.Helper()
+	challenge := sha256.Sum256([]byte(testVerifier))
+	loginURL, err := ta.Authorize(context.Background(), models.AuthorizationRequest{
+		ClientID:            testClientID,
+		RedirectURI:         testRedirectURI,
+		ResponseType:        "code",
+		Scope:               "profile",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(challenge[:]),
+		CodeChallengeMethod: codeChallengeMethodS256,
+	})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	return queryParam(t, loginURL, "request_id")
+}
+
+// authorizationCode runs /authorize for testClientID as the owner of
+// accessToken and returns the code it was given.
+func (ta testAuth) authorizationCode(t *testing.T, accessToken string) string {
+	t.Helper()
+	redirect, err := ta.CompleteAuthorization(context.Background(), accessToken, ta.authorizationRequest(t), true)
+	if err != nil {
+		t.Fatalf("CompleteAuthorization: %v", err)
+	}
+	return queryParam(t, redirect, "code")
+}
+
+// oauthTokens signs the user in to testClientID.
+func (ta testAuth) oauthTokens(t *testing.T, login string) models.OAuthTokens {
+	t.Helper()
+	code := ta.authorizationCode(t, ta.login(t, login).AccessToken)
+	tokens, err := ta.ExchangeAuthorizationCode(context.Background(), publicClient(), code, testRedirectURI, testVerifier, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("ExchangeAuthorizationCode: %v", err)
+	}
+	return tokens
+}
+
+func publicClient() models.ClientCredentials {
+	return models.ClientCredentials{ID: testClientID, Method: AuthMethodNone}
+}
+
+func queryParam(t *testing.T, rawURL string, name string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("url.Parse(%s): %v", rawURL, err)
+	}
+	value := u.Query().Get(name)
+	if value == "" {
+		t.Fatalf("%s has no %s", rawURL, name)
+	}
+	return value
+}
+
+func TestFirstPartyCallsRejectOAuthTokens(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	ta.exec(t, "UPDATE users SET role = 'admin' WHERE login = 'alice'")
+	ta.registerPublicClient(t)
+	oauthToken := ta.oauthTokens(t, "alice").AccessToken
+
+	if _, err := ta.ValidateToken(ctx, oauthToken); err != nil {
+		t.Fatalf("ValidateToken of the OAuth token: %v", err)
+	}
+
+	calls := map[string]func(token string) error{
+		"UpdateUser": func(token string) error {
+			_, err := ta.UpdateUser(ctx, token, "mallory@example.com", "", "")
+			return err
+		},
+		"LogOut": func(token string) error {
+			_, err := ta.LogOut(ctx, token)
+			return err
+		},
+		"GenerateRecoveryCodes": func(token string) error {
+			_, err := ta.GenerateRecoveryCodes(ctx, token)
+			return err
+		},
+		"EnrollTOTP": func(token string) error {
+			_, _, err := ta.EnrollTOTP(ctx, token)
+			return err
+		},
+		"BeginWebAuthnRegistration": func(token string) error {
+			_, err := ta.BeginWebAuthnRegistration(ctx, token)
+			return err
+		},
+		"ListSessions": func(token string) error {
+			_, err := ta.ListSessions(ctx, token)
+			return err
+		},
+		"RevokeAllSessions": func(token string) error {
+			_, err := ta.RevokeAllSessions(ctx, token, false)
+			return err
+		},
+		"SendPhoneCode": func(token string) error {
+			_, err := ta.SendPhoneCode(ctx, token)
+			return err
+		},
+		"UnlockUser": func(token string) error {
+			_, err := ta.UnlockUser(ctx, token, "alice")
+			return err
+		},
+		"CreateOAuthClient": func(token string) error {
+			_, _, err := ta.CreateOAuthClient(ctx, token, models.OAuthClient{
+				RedirectURIs: []string{testRedirectURI},
+				Scopes:       []string{"profile"},
+			})
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			if err := call(oauthToken); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("got %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+
+	user, err := ta.userProvider.GetUser(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if user.Email != "alice@example.com" {
+		t.Fatalf("email changed to %s", user.Email)
+	}
+}
+
+func TestResetPasswordRejectsExpiredTokens(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	user, err := ta.userProvider.GetUser(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	const newPassword = "An0ther-Horse-Battery!"
+
+	if err := ta.tokenSaver.SaveToken(ctx, user.ID, hashToken("expired"), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("SaveToken: %v", err)
+	}
+	if _, err := ta.ResetPassword(ctx, "expired", newPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("ResetPassword with an expired token = %v, want ErrInvalidResetToken", err)
+	}
+	if _, err := ta.ResetPassword(ctx, "unknown", newPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("ResetPassword with an unknown token = %v, want ErrInvalidResetToken", err)
+	}
+	ta.login(t, "alice")
+
+	if err := ta.tokenSaver.SaveToken(ctx, user.ID, hashToken("valid"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("SaveToken: %v", err)
+	}
+	if _, err := ta.ResetPassword(ctx, "valid", newPassword); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if _, err := ta.ResetPassword(ctx, "valid", newPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("reusing a reset token = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+// forgotPassword asks for a reset link for login and returns its token.
+func (ta testAuth) forgotPassword(t *testing.T, box *outbox, login string) string {
+	t.Helper()
+	if _, err := ta.ForgotPassword(context.Background(), login); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	return box.lastToken(t, login+"@example.com")
+}
+
+func newResettingTestAuth(t *testing.T) (testAuth, *outbox) {
+	t.Helper()
+	box := &outbox{}
+	ta := newTestAuth(t, WithNotifier(box, Links{ResetPassword: "https://example.com/reset"}))
+	return ta, box
+}
+
+func TestResetTokensAreStoredHashed(t *testing.T) {
+	ctx := context.Background()
+	ta, box := newResettingTestAuth(t)
+	ta.newUser(t, "alice")
+
+	resetToken := ta.forgotPassword(t, box, "alice")
+	if _, err := ta.tokenProvider.GetToken(ctx, resetToken); !errors.Is(err, sqlite.ErrTokenNotFound) {
+		t.Fatalf("GetToken with the raw token = %v, want ErrTokenNotFound", err)
+	}
+	if _, err := ta.ResetPassword(ctx, resetToken, "An0ther-Horse-Battery!"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+}
+
+func TestPasswordChangesInvalidateResetTokens(t *testing.T) {
+	ctx := context.Background()
+	ta, box := newResettingTestAuth(t)
+	ta.newUser(t, "alice")
+	const newPassword = "An0ther-Horse-Battery!"
+
+	first := ta.forgotPassword(t, box, "alice")
+	second := ta.forgotPassword(t, box, "alice")
+	if _, err := ta.ResetPassword(ctx, first, newPassword); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if _, err := ta.ResetPassword(ctx, second, "Yet-An0ther-Horse!"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("second reset token after a reset = %v, want ErrInvalidResetToken", err)
+	}
+
+	third := ta.forgotPassword(t, box, "alice")
+	if _, err := ta.ChangePassword(ctx, "alice", newPassword, "Yet-An0ther-Horse!", models.ClientInfo{}); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if _, err := ta.ResetPassword(ctx, third, newPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Fatalf("reset token after a password change = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestResetTokenIsSpentOnceUnderConcurrency(t *testing.T) {
+	ctx := context.Background()
+	ta, box := newResettingTestAuth(t)
+	ta.newUser(t, "alice")
+	resetToken := ta.forgotPassword(t, box, "alice")
+
+	const attempts = 10
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		reset int
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ta.ResetPassword(ctx, resetToken, "An0ther-Horse-Battery!")
+			if err == nil {
+				mu.Lock()
+				reset++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrInvalidResetToken) {
+				t.Errorf("ResetPassword = %v, want ErrInvalidResetToken", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reset != 1 {
+		t.Fatalf("%d resets went through, want 1", reset)
+	}
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	first := ta.login(t, "alice")
+
+	second, err := ta.Refresh(ctx, first.RefreshToken, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if _, err := ta.Refresh(ctx, first.RefreshToken, models.ClientInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replayed refresh token = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := ta.Refresh(ctx, second.RefreshToken, models.ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("latest refresh token after reuse = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := ta.ValidateToken(ctx, second.AccessToken); err == nil {
+		t.Fatal("access token is still valid after refresh token reuse")
+	}
+}
+
+func TestChangePasswordInvalidatesTokens(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	tokens := ta.login(t, "alice")
+	if _, err := ta.ValidateToken(ctx, tokens.AccessToken); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	if _, err := ta.ChangePassword(ctx, "alice", testPassword, "An0ther-Horse-Battery!", models.ClientInfo{}); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if _, err := ta.ValidateToken(ctx, tokens.AccessToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("access token after a password change = %v, want ErrInvalidToken", err)
+	}
+	if _, err := ta.Refresh(ctx, tokens.RefreshToken, models.ClientInfo{}); err == nil {
+		t.Fatal("refresh token still works after a password change")
+	}
+}
@@ -0,0 +1,303 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/token"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDeviceCodeTTL      = 10 * time.Minute
+	defaultDevicePollInterval = 5 * time.Second
+	// slowDownStep is how much longer a device has to wait after polling
+	// too fast, see RFC 8628 section 3.5.
+	slowDownStep = 5 * time.Second
+
+	// userCodeAlphabet has no vowels, so codes do not spell words, and no
+	// characters that are easily confused, as RFC 8628 section 6.1 suggests.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+	// maxUserCodeAttempts bounds the wrong user codes a signed-in user may
+	// enter while a device code is valid, so they cannot be guessed.
+	maxUserCodeAttempts = 10
+	// maxUserCodeCollisions bounds how often a new user code is drawn when
+	// the previous one is already in use.
+	maxUserCodeCollisions = 5
+)
+
+var (
+	// ErrInvalidUserCode is returned for an unknown, expired or already
+	// decided user code.
+	ErrInvalidUserCode         = errors.New("invalid user code")
+	ErrTooManyUserCodeAttempts = errors.New("too many wrong user codes")
+)
+
+// AuthorizeDevice starts the device authorization grant of RFC 8628 for a
+// device that cannot receive a redirect. The device shows the user code and
+// verification URI, then polls /token with the device code until the user
+// approved it on another device.
+func (a *Auth) AuthorizeDevice(ctx context.Context, creds models.ClientCredentials, scope string) (models.DeviceCode, error) {
+	const op = "auth.AuthorizeDevice"
+
+	client, err := a.tokenClient(ctx, creds, GrantDeviceCode)
+	if err != nil {
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+	scope, err = grantableScope(client, scope)
+	if err != nil {
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deviceCode, err := token.GenerateToken()
+	if err != nil {
+		return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+	// User codes are short enough to collide with one still pending, so a
+	// taken one is replaced by a fresh code.
+	var userCode string
+	for i := 0; ; i++ {
+		userCode, err = generateUserCode()
+		if err != nil {
+			return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+		}
+		err = a.deviceAuthSaver.SaveDeviceAuthorization(ctx, models.DeviceAuthorization{
+			DeviceCodeHash: hashToken(deviceCode),
+			UserCodeHash:   hashToken(normalizeUserCode(userCode)),
+			ClientID:       client.ID,
+			Scope:          scope,
+			Interval:       a.oauth.DevicePollInterval,
+			ExpiresAt:      time.Now().Add(a.oauth.DeviceCodeTTL),
+		})
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, sqlite.ErrUserCodeTaken) || i+1 == maxUserCodeCollisions {
+			return models.DeviceCode{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return models.DeviceCode{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         a.oauth.VerificationURL,
+		VerificationURIComplete: addQuery(a.oauth.VerificationURL, url.Values{"user_code": {userCode}}),
+		ExpiresIn:               a.oauth.DeviceCodeTTL,
+		Interval:                a.oauth.DevicePollInterval,
+	}, nil
+}
+
+// DevicePrompt tells the verification page what the device with the user
+// code asks the signed-in user for.
+func (a *Auth) DevicePrompt(ctx context.Context, jwtToken string, userCode string) (models.ConsentPrompt, error) {
+	const op = "auth.DevicePrompt"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return models.ConsentPrompt{}, fmt.Errorf("%s: %w", op, err)
+	}
+	deviceAuth, err := a.pendingDeviceAuthorization(ctx, accessToken.Uid, userCode)
+	if err != nil {
+		return models.ConsentPrompt{}, fmt.Errorf("%s: %w", op, err)
+	}
+	client, err := a.oauthClient(ctx, deviceAuth.ClientID)
+	if err != nil {
+		return models.ConsentPrompt{}, fmt.Errorf("%s: %w", op, err)
+	}
+	// The user has to confirm the code is the one their device shows, so
+	// consent is always asked for.
+	return models.ConsentPrompt{
+		ClientID:        client.ID,
+		ClientName:      client.Name,
+		Scopes:          strings.Fields(deviceAuth.Scope),
+		ConsentRequired: true,
+	}, nil
+}
+
+// CompleteDeviceAuthorization records the signed-in user's decision on the
+// device with the user code. The device learns it on its next poll.
+func (a *Auth) CompleteDeviceAuthorization(ctx context.Context, jwtToken string, userCode string, approved bool) error {
+	const op = "auth.CompleteDeviceAuthorization"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	deviceAuth, err := a.pendingDeviceAuthorization(ctx, accessToken.Uid, userCode)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := a.oauthClient(ctx, deviceAuth.ClientID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	status := models.DeviceAuthorizationDenied
+	var authTime time.Time
+	if approved {
+		status = models.DeviceAuthorizationApproved
+		if err := a.grantConsent(ctx, accessToken.Uid, deviceAuth.ClientID, deviceAuth.Scope); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		session, err := a.sessionProvider.GetSession(ctx, accessToken.Sid)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		authTime = session.CreatedAt
+	}
+	decided, err := a.deviceAuthUpdater.DecideDeviceAuthorization(ctx, deviceAuth.ID, status, accessToken.Uid, authTime)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if !decided {
+		return fmt.Errorf("%s: %w", op, ErrInvalidUserCode)
+	}
+	return nil
+}
+
+// ExchangeDeviceCode is the device_code grant the device polls with. Until
+// the user decides it answers authorization_pending, or slow_down when the
+// device polls more often than its interval allows, which also makes the
+// interval longer.
+func (a *Auth) ExchangeDeviceCode(ctx context.Context, creds models.ClientCredentials, deviceCode string, client models.ClientInfo) (models.OAuthTokens, error) {
+	const op = "auth.ExchangeDeviceCode"
+
+	oauthClient, err := a.tokenClient(ctx, creds, GrantDeviceCode)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	deviceAuth, err := a.deviceAuthProvider.GetDeviceAuthorization(ctx, hashToken(deviceCode))
+	if err != nil {
+		if errors.Is(err, sqlite.ErrCodeNotFound) {
+			return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "unknown device code"))
+		}
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if deviceAuth.ClientID != oauthClient.ID {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "device code was issued to another client"))
+	}
+	now := time.Now()
+	if now.After(deviceAuth.ExpiresAt) {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthExpiredToken, "device code expired"))
+	}
+
+	switch deviceAuth.Status {
+	case models.DeviceAuthorizationPending:
+		interval := deviceAuth.Interval
+		code := OAuthAuthorizationPending
+		if !deviceAuth.LastPolledAt.IsZero() && now.Sub(deviceAuth.LastPolledAt) < deviceAuth.Interval {
+			interval += slowDownStep
+			code = OAuthSlowDown
+		}
+		if err := a.deviceAuthUpdater.PollDeviceAuthorization(ctx, deviceAuth.ID, now, interval); err != nil {
+			return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(code, ""))
+	case models.DeviceAuthorizationDenied:
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthAccessDenied, "the user denied the request"))
+	case models.DeviceAuthorizationApproved:
+	default:
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "device code already used"))
+	}
+
+	user, err := a.userProvider.GetUserByID(ctx, deviceAuth.UserID)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if client.DeviceName == "" {
+		client.DeviceName = oauthClient.Name
+	}
+	session, err := a.startSession(ctx, user.ID, client, oauthClient.ID, deviceAuth.Scope)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	used, err := a.deviceAuthUpdater.UseDeviceAuthorization(ctx, deviceAuth.ID, session.ID)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !used {
+		// Lost a race with another poll of the same device code.
+		if err := a.revokeSession(ctx, user.ID, session.ID); err != nil {
+			return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "device code already used"))
+	}
+
+	tokens, err := a.issueTokens(ctx, user, session)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	idToken, err := a.issueIDToken(ctx, user, session, "", deviceAuth.AuthTime)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return models.OAuthTokens{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    a.accessTokenTTL,
+		Scope:        session.Scope,
+		IDToken:      idToken,
+	}, nil
+}
+
+// pendingDeviceAuthorization looks up the device the user entered the code
+// of, counting wrong codes against the user. The attempt is counted before
+// the lookup, so concurrent guesses cannot get past the limit, and given
+// back when the code was right.
+func (a *Auth) pendingDeviceAuthorization(ctx context.Context, userID int64, userCode string) (models.DeviceAuthorization, error) {
+	attemptsKey := "userCodeAttempts:" + strconv.FormatInt(userID, 10)
+	attempts, err := a.cache.Increment(ctx, attemptsKey, 1, a.oauth.DeviceCodeTTL)
+	if err != nil {
+		return models.DeviceAuthorization{}, err
+	}
+	if attempts > maxUserCodeAttempts {
+		return models.DeviceAuthorization{}, ErrTooManyUserCodeAttempts
+	}
+
+	deviceAuth, err := a.deviceAuthProvider.GetDeviceAuthorizationByUserCode(ctx, hashToken(normalizeUserCode(userCode)))
+	if err != nil && !errors.Is(err, sqlite.ErrCodeNotFound) {
+		return models.DeviceAuthorization{}, err
+	}
+	if err != nil || deviceAuth.Status != models.DeviceAuthorizationPending || time.Now().After(deviceAuth.ExpiresAt) {
+		return models.DeviceAuthorization{}, ErrInvalidUserCode
+	}
+	if _, err := a.cache.Increment(ctx, attemptsKey, -1, a.oauth.DeviceCodeTTL); err != nil {
+		a.log.Error("failed to save in cache", slog.String("error", err.Error()))
+	}
+	return deviceAuth, nil
+}
+
+// generateUserCode returns a code like "BDFG-HJKL" for the user to type.
+func generateUserCode() (string, error) {
+	var code strings.Builder
+	alphabetSize := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := 0; i < userCodeLength; i++ {
+		if i == userCodeLength/2 {
+			code.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(userCodeAlphabet[n.Int64()])
+	}
+	return code.String(), nil
+}
+
+// normalizeUserCode accepts the code in lower case and with or without the
+// dash and spaces.
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(userCode))
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testDeviceClientID = "test-device"
+
+// registerDeviceClient registers testDeviceClientID as a public client of
+// the device authorization grant.
+func (ta testAuth) registerDeviceClient(t *testing.T) {
+	t.Helper()
+	err := ta.RegisterOAuthClient(context.Background(), models.OAuthClient{
+		ID:         testDeviceClientID,
+		Scopes:     []string{"profile"},
+		GrantTypes: []string{GrantDeviceCode, GrantRefreshToken},
+		AuthMethod: AuthMethodNone,
+	})
+	if err != nil {
+		t.Fatalf("RegisterOAuthClient: %v", err)
+	}
+}
+
+func deviceClient() models.ClientCredentials {
+	return models.ClientCredentials{ID: testDeviceClientID, Method: AuthMethodNone}
+}
+
+func TestUserCodeAttemptsHoldUnderConcurrency(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	ta.registerDeviceClient(t)
+	accessToken := ta.login(t, "alice").AccessToken
+	code, err := ta.AuthorizeDevice(ctx, deviceClient(), "profile")
+	if err != nil {
+		t.Fatalf("AuthorizeDevice: %v", err)
+	}
+
+	// A right code does not use up an attempt.
+	if _, err := ta.DevicePrompt(ctx, accessToken, code.UserCode); err != nil {
+		t.Fatalf("DevicePrompt: %v", err)
+	}
+
+	const guesses = 3 * maxUserCodeAttempts
+	errs := make(chan error, guesses)
+	var wg sync.WaitGroup
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ta.DevicePrompt(ctx, accessToken, "XXXX-XXXX")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	wrong := 0
+	for err := range errs {
+		switch {
+		case errors.Is(err, ErrInvalidUserCode):
+			wrong++
+		case !errors.Is(err, ErrTooManyUserCodeAttempts):
+			t.Fatalf("got %v, want ErrInvalidUserCode or ErrTooManyUserCodeAttempts", err)
+		}
+	}
+	if wrong != maxUserCodeAttempts {
+		t.Fatalf("%d wrong codes were checked, want %d", wrong, maxUserCodeAttempts)
+	}
+	if _, err := ta.DevicePrompt(ctx, accessToken, code.UserCode); !errors.Is(err, ErrTooManyUserCodeAttempts) {
+		t.Fatalf("DevicePrompt after the limit = %v, want ErrTooManyUserCodeAttempts", err)
+	}
+}
+
+func TestSaveDeviceAuthorizationReportsTakenUserCode(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.registerDeviceClient(t)
+	save := func(deviceCode string) error {
+		return ta.deviceAuthSaver.SaveDeviceAuthorization(ctx, models.DeviceAuthorization{
+			DeviceCodeHash: hashToken(deviceCode),
+			UserCodeHash:   hashToken("BCDFGHJK"),
+			ClientID:       testDeviceClientID,
+			Scope:          "profile",
+			Interval:       time.Second,
+			ExpiresAt:      time.Now().Add(time.Minute),
+		})
+	}
+	if err := save("first"); err != nil {
+		t.Fatalf("SaveDeviceAuthorization: %v", err)
+	}
+	// AuthorizeDevice draws a new user code on this error.
+	if err := save("second"); !errors.Is(err, sqlite.ErrUserCodeTaken) {
+		t.Fatalf("saving a taken user code = %v, want sqlite.ErrUserCodeTaken", err)
+	}
+}
+
+func TestDeviceCodePolling(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	ta.registerDeviceClient(t)
+	accessToken := ta.login(t, "alice").AccessToken
+	poll := func(deviceCode string) (models.OAuthTokens, error) {
+		return ta.ExchangeDeviceCode(ctx, deviceClient(), deviceCode, models.ClientInfo{})
+	}
+
+	t.Run("approved", func(t *testing.T) {
+		code, err := ta.AuthorizeDevice(ctx, deviceClient(), "profile")
+		if err != nil {
+			t.Fatalf("AuthorizeDevice: %v", err)
+		}
+		if _, err := poll(code.DeviceCode); oauthErrorCode(err) != OAuthAuthorizationPending {
+			t.Fatalf("first poll: got %v, want %s", err, OAuthAuthorizationPending)
+		}
+		if _, err := poll(code.DeviceCode); oauthErrorCode(err) != OAuthSlowDown {
+			t.Fatalf("poll within the interval: got %v, want %s", err, OAuthSlowDown)
+		}
+		if err := ta.CompleteDeviceAuthorization(ctx, accessToken, code.UserCode, true); err != nil {
+			t.Fatalf("CompleteDeviceAuthorization: %v", err)
+		}
+		tokens, err := poll(code.DeviceCode)
+		if err != nil {
+			t.Fatalf("poll after approval: %v", err)
+		}
+		if _, err := ta.ValidateToken(ctx, tokens.AccessToken); err != nil {
+			t.Fatalf("ValidateToken: %v", err)
+		}
+		if _, err := poll(code.DeviceCode); oauthErrorCode(err) != OAuthInvalidGrant {
+			t.Fatalf("poll after use: got %v, want %s", err, OAuthInvalidGrant)
+		}
+		// A decided user code cannot be entered again.
+		if err := ta.CompleteDeviceAuthorization(ctx, accessToken, code.UserCode, true); !errors.Is(err, ErrInvalidUserCode) {
+			t.Fatalf("deciding twice = %v, want ErrInvalidUserCode", err)
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		code, err := ta.AuthorizeDevice(ctx, deviceClient(), "profile")
+		if err != nil {
+			t.Fatalf("AuthorizeDevice: %v", err)
+		}
+		if err := ta.CompleteDeviceAuthorization(ctx, accessToken, code.UserCode, false); err != nil {
+			t.Fatalf("CompleteDeviceAuthorization: %v", err)
+		}
+		if _, err := poll(code.DeviceCode); oauthErrorCode(err) != OAuthAccessDenied {
+			t.Fatalf("poll after denial: got %v, want %s", err, OAuthAccessDenied)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		code, err := ta.AuthorizeDevice(ctx, deviceClient(), "profile")
+		if err != nil {
+			t.Fatalf("AuthorizeDevice: %v", err)
+		}
+		ta.exec(t, "UPDATE deviceAuthorizations SET expires_at = ? WHERE device_code_hash = ?", time.Now().Add(-time.Second).UTC(), hashToken(code.DeviceCode))
+		if _, err := poll(code.DeviceCode); oauthErrorCode(err) != OAuthExpiredToken {
+			t.Fatalf("poll after expiry: got %v, want %s", err, OAuthExpiredToken)
+		}
+		if err := ta.CompleteDeviceAuthorization(ctx, accessToken, code.UserCode, true); !errors.Is(err, ErrInvalidUserCode) {
+			t.Fatalf("approving an expired code = %v, want ErrInvalidUserCode", err)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := poll("unknown"); oauthErrorCode(err) != OAuthInvalidGrant {
+			t.Fatalf("got %v, want %s", err, OAuthInvalidGrant)
+		}
+	})
+}
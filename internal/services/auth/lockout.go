@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// roleAdmin is the role allowed to manage other users' accounts.
+const roleAdmin = "admin"
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountLocked      = errors.New("account locked")
+	ErrTooManyAttempts    = errors.New("too many failed login attempts")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrUserNotFound       = errors.New("user not found")
+)
+
+// LockoutPolicy limits password guessing. An account is locked after
+// Threshold consecutive failures, for Duration the first time and twice as
+// long each further time up to MaxDuration. A source IP is refused once it has
+// failed IPThreshold times within IPWindow, whichever accounts it tried.
+type LockoutPolicy struct {
+	Threshold   int
+	Duration    time.Duration
+	MaxDuration time.Duration
+	IPThreshold int
+	IPWindow    time.Duration
+}
+
+var defaultLockoutPolicy = LockoutPolicy{
+	Threshold:   5,
+	Duration:    time.Minute,
+	MaxDuration: time.Hour,
+	IPThreshold: 20,
+	IPWindow:    15 * time.Minute,
+}
+
+// UnlockUser lifts the lock on an account and forgets its failed logins. Only
+// admins may call it.
+func (a *Auth) UnlockUser(ctx context.Context, jwtToken string, login string) (bool, error) {
+	const op = "auth.UnlockUser"
+
+	user, err := a.adminTarget(ctx, jwtToken, login)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	unlocked, err := a.accountLockUpdater.UnlockAccount(ctx, user.ID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	a.log.Info("account unlocked", slog.Int64("uid", user.ID))
+	return unlocked, nil
+}
+
+// LockStatus reports the failed logins and lock of an account. Only admins
+// may call it.
+func (a *Auth) LockStatus(ctx context.Context, jwtToken string, login string) (models.AccountLock, error) {
+	const op = "auth.LockStatus"
+
+	user, err := a.adminTarget(ctx, jwtToken, login)
+	if err != nil {
+		return models.AccountLock{}, fmt.Errorf("%s: %w", op, err)
+	}
+	lock, err := a.accountLockProvider.GetAccountLock(ctx, user.ID)
+	if err != nil {
+		return models.AccountLock{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return lock, nil
+}
+
+// adminTarget checks that the token belongs to an admin and looks up the user
+// they want to act on.
+func (a *Auth) adminTarget(ctx context.Context, jwtToken string, login string) (models.User, error) {
+	if err := a.requireAdmin(ctx, jwtToken); err != nil {
+		return models.User{}, err
+	}
+	user, err := a.userProvider.GetUser(ctx, login)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrUserNotFound) {
+			return models.User{}, ErrUserNotFound
+		}
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// requireAdmin checks that the token belongs to an admin.
+func (a *Auth) requireAdmin(ctx context.Context, jwtToken string) error {
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return err
+	}
+	if accessToken.Role != roleAdmin {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
+// checkPassword verifies the password of a user who is not locked out.
+func (a *Auth) checkPassword(ctx context.Context, user models.User, password string, ip string) error {
+	return a.checkCredential(ctx, user, ip, ErrInvalidCredentials, func() (bool, error) {
+		return a.hasher.Verify(user.PassHash, password)
+	})
+}
+
+// checkCredential runs check for a user who is not locked out. The attempt is
+// counted against the account and the source IP before check runs, so
+// concurrent guesses cannot get past the thresholds, and given back if the
+// credential checks out. One that does not is reported as invalid. The
+// account's failures are only forgotten once a sign-in completes, so a right
+// password does not undo wrong second factors.
+func (a *Auth) checkCredential(ctx context.Context, user models.User, ip string, invalid error, check func() (bool, error)) error {
+	if err := a.reserveIPAttempt(ctx, ip); err != nil {
+		return err
+	}
+	lock, err := a.accountLockUpdater.ReserveLoginAttempt(ctx, user.ID)
+	if err != nil {
+		a.releaseIPAttempt(ctx, ip)
+		return err
+	}
+	if lock.Locked(time.Now()) || lock.FailedAttempts > a.lockout.Threshold {
+		a.releaseIPAttempt(ctx, ip)
+		if err := a.accountLockUpdater.ReleaseLoginAttempt(ctx, user.ID); err != nil {
+			return err
+		}
+		return ErrAccountLocked
+	}
+
+	ok, err := check()
+	if err != nil || ok {
+		a.releaseIPAttempt(ctx, ip)
+		if releaseErr := a.accountLockUpdater.ReleaseLoginAttempt(ctx, user.ID); releaseErr != nil {
+			return releaseErr
+		}
+		return err
+	}
+	a.log.Info("invalid credentials", slog.Int64("uid", user.ID))
+	if err := a.failLogin(ctx, lock); err != nil {
+		return err
+	}
+	return invalid
+}
+
+// clearFailedLogins forgets the failed logins of a user who signed in.
+func (a *Auth) clearFailedLogins(ctx context.Context, userID int64) error {
+	lock, err := a.accountLockProvider.GetAccountLock(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if lock.FailedAttempts > 0 || lock.Lockouts > 0 {
+		if _, err := a.accountLockUpdater.UnlockAccount(ctx, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failLogin keeps the attempt reserved in lock as a failure and locks the
+// account if it was the one that reached the threshold.
+func (a *Auth) failLogin(ctx context.Context, lock models.AccountLock) error {
+	now := time.Now()
+	if err := a.accountLockUpdater.RecordFailedLogin(ctx, lock.UserID, now); err != nil {
+		return err
+	}
+	if lock.FailedAttempts < a.lockout.Threshold {
+		return nil
+	}
+
+	duration := a.lockout.Duration
+	for i := 0; i < lock.Lockouts && duration < a.lockout.MaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > a.lockout.MaxDuration {
+		duration = a.lockout.MaxDuration
+	}
+	a.log.Warn("account locked",
+		slog.Int64("uid", lock.UserID),
+		slog.Int("lockouts", lock.Lockouts+1),
+		slog.Duration("duration", duration),
+	)
+	return a.accountLockUpdater.LockAccount(ctx, lock.UserID, now.Add(duration))
+}
+
+func (a *Auth) checkIPFailures(ctx context.Context, ip string) error {
+	if ip == "" {
+		return nil
+	}
+	value, ok := a.cache.Get(ctx, ipFailuresKey(ip))
+	if !ok {
+		return nil
+	}
+	if failures, ok := value.(int); ok && failures >= a.lockout.IPThreshold {
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+// addIPFailure counts a failed login from ip. The count is kept for IPWindow
+// from the first failure.
+func (a *Auth) addIPFailure(ctx context.Context, ip string) {
+	if ip == "" {
+		return
+	}
+	if _, err := a.cache.Increment(ctx, ipFailuresKey(ip), 1, a.lockout.IPWindow); err != nil {
+		a.log.Error("failed to save in cache", slog.String("error", err.Error()))
+	}
+}
+
+// reserveIPAttempt counts an attempt from ip before it is checked and refuses
+// it if the address is already past the threshold.
+func (a *Auth) reserveIPAttempt(ctx context.Context, ip string) error {
+	if ip == "" {
+		return nil
+	}
+	failures, err := a.cache.Increment(ctx, ipFailuresKey(ip), 1, a.lockout.IPWindow)
+	if err != nil {
+		a.log.Error("failed to save in cache", slog.String("error", err.Error()))
+		return nil
+	}
+	if failures > a.lockout.IPThreshold {
+		a.releaseIPAttempt(ctx, ip)
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+// releaseIPAttempt gives back an attempt reserved with reserveIPAttempt that
+// did not fail.
+func (a *Auth) releaseIPAttempt(ctx context.Context, ip string) {
+	if ip == "" {
+		return
+	}
+	if _, err := a.cache.Increment(ctx, ipFailuresKey(ip), -1, a.lockout.IPWindow); err != nil {
+		a.log.Error("failed to save in cache", slog.String("error", err.Error()))
+	}
+}
+
+func ipFailuresKey(ip string) string {
+	return "loginFailures:" + ip
+}
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChangePasswordCountsTowardsLockout(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	const newPassword = "An0ther-Horse-Battery!"
+
+	for i := 0; i < defaultLockoutPolicy.Threshold; i++ {
+		_, err := ta.ChangePassword(ctx, "alice", "wrong", newPassword, models.ClientInfo{})
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+	if _, err := ta.ChangePassword(ctx, "alice", testPassword, newPassword, models.ClientInfo{}); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("ChangePassword on a locked account = %v, want ErrAccountLocked", err)
+	}
+	if _, err := ta.Login(ctx, "alice", testPassword, models.ClientInfo{}); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Login on a locked account = %v, want ErrAccountLocked", err)
+	}
+}
+
+func TestChangePasswordCountsIPFailures(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t, WithLockout(LockoutPolicy{Threshold: 100, IPThreshold: 2}))
+	ta.newUser(t, "alice")
+	client := models.ClientInfo{IP: "203.0.113.7"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := ta.ChangePassword(ctx, "alice", "wrong", "An0ther-Horse-Battery!", client); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+	if _, err := ta.Login(ctx, "alice", testPassword, client); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("Login = %v, want ErrTooManyAttempts", err)
+	}
+}
+
+func TestResetPasswordWithRecoveryCodeCountsTowardsLockout(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	const newPassword = "An0ther-Horse-Battery!"
+
+	for i := 0; i < defaultLockoutPolicy.Threshold; i++ {
+		_, err := ta.ResetPasswordWithRecoveryCode(ctx, "alice", "aaaaa-bbbbb", newPassword, models.ClientInfo{})
+		if !errors.Is(err, ErrInvalidRecoveryCode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidRecoveryCode", i+1, err)
+		}
+	}
+	_, err := ta.ResetPasswordWithRecoveryCode(ctx, "alice", "aaaaa-bbbbb", newPassword, models.ClientInfo{})
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("ResetPasswordWithRecoveryCode on a locked account = %v, want ErrAccountLocked", err)
+	}
+}
+
+// failLogins signs in n times with a wrong password and expects each to be
+// refused as invalid.
+func (ta testAuth) failLogins(t *testing.T, login string, n int, client models.ClientInfo) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := ta.Login(context.Background(), login, "wrong", client); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidCredentials", i+1, err)
+		}
+	}
+}
+
+func (ta testAuth) accountLock(t *testing.T, login string) models.AccountLock {
+	t.Helper()
+	ctx := context.Background()
+	user, err := ta.userProvider.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	lock, err := ta.accountLockProvider.GetAccountLock(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetAccountLock: %v", err)
+	}
+	return lock
+}
+
+func TestLockoutThreshold(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	threshold := defaultLockoutPolicy.Threshold
+
+	// One short of the threshold the account stays open, and signing in
+	// forgets the failures.
+	ta.failLogins(t, "alice", threshold-1, models.ClientInfo{})
+	ta.login(t, "alice")
+	if lock := ta.accountLock(t, "alice"); lock.FailedAttempts != 0 {
+		t.Fatalf("%d failed attempts after signing in, want 0", lock.FailedAttempts)
+	}
+
+	ta.failLogins(t, "alice", threshold, models.ClientInfo{})
+	if _, err := ta.Login(ctx, "alice", testPassword, models.ClientInfo{}); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Login on a locked account = %v, want ErrAccountLocked", err)
+	}
+	lock := ta.accountLock(t, "alice")
+	if lock.Lockouts != 1 {
+		t.Fatalf("%d lockouts, want 1", lock.Lockouts)
+	}
+	if until := time.Until(lock.LockedUntil); until <= 0 || until > defaultLockoutPolicy.Duration {
+		t.Fatalf("locked for %v, want up to %v", until, defaultLockoutPolicy.Duration)
+	}
+}
+
+func TestLockoutDoublesUpToMaxDuration(t *testing.T) {
+	ta := newTestAuth(t, WithLockout(LockoutPolicy{
+		Threshold:   2,
+		Duration:    time.Minute,
+		MaxDuration: 3 * time.Minute,
+		IPThreshold: 100,
+		IPWindow:    time.Minute,
+	}))
+	ta.newUser(t, "alice")
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		ta.failLogins(t, "alice", 2, models.ClientInfo{})
+		until := time.Until(ta.accountLock(t, "alice").LockedUntil)
+		if until <= want-time.Minute/2 || until > want {
+			t.Fatalf("locked for %v, want %v", until, want)
+		}
+		// Let the lock run out without a successful login in between.
+		ta.exec(t, "UPDATE accountLocks SET locked_until = ?", time.Now().Add(-time.Second).UTC())
+	}
+}
+
+func TestIPThresholdSpansAccounts(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t, WithLockout(LockoutPolicy{
+		Threshold:   100,
+		Duration:    time.Minute,
+		MaxDuration: time.Hour,
+		IPThreshold: 3,
+		IPWindow:    time.Minute,
+	}))
+	ta.newUser(t, "alice")
+	ta.newUser(t, "bob")
+	attacker := models.ClientInfo{IP: "203.0.113.7"}
+
+	ta.failLogins(t, "alice", 2, attacker)
+	ta.failLogins(t, "bob", 1, attacker)
+	// The address is refused whatever login it tries.
+	if _, err := ta.Login(ctx, "nobody", "wrong", attacker); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("Login past the IP threshold = %v, want ErrTooManyAttempts", err)
+	}
+	if _, err := ta.Login(ctx, "bob", testPassword, attacker); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("Login with the right password = %v, want ErrTooManyAttempts", err)
+	}
+	if _, err := ta.Login(ctx, "bob", testPassword, models.ClientInfo{IP: "198.51.100.1"}); err != nil {
+		t.Fatalf("Login from another address: %v", err)
+	}
+}
+
+func TestIPFailuresCountUnderConcurrency(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t, WithLockout(LockoutPolicy{
+		Threshold:   1000,
+		Duration:    time.Minute,
+		MaxDuration: time.Hour,
+		IPThreshold: 1000,
+		IPWindow:    time.Minute,
+	}))
+	ta.newUser(t, "alice")
+	attacker := models.ClientInfo{IP: "203.0.113.7"}
+
+	const guesses = 20
+	var wg sync.WaitGroup
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ta.Login(ctx, "alice", "wrong", attacker); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("Login = %v, want ErrInvalidCredentials", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, ok := ta.cache.Get(ctx, ipFailuresKey(attacker.IP))
+	if failures, _ := value.(int); !ok || failures != guesses {
+		t.Fatalf("%v failures counted for the address, want %d", value, guesses)
+	}
+}
+
+func TestLockoutThresholdUnderConcurrency(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t, WithLockout(LockoutPolicy{
+		Threshold:   5,
+		Duration:    time.Minute,
+		MaxDuration: time.Hour,
+		IPThreshold: 1000,
+		IPWindow:    time.Minute,
+	}))
+	ta.newUser(t, "alice")
+
+	const guesses = 20
+	var (
+		wg              sync.WaitGroup
+		mu              sync.Mutex
+		invalid, locked int
+	)
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ta.Login(ctx, "alice", "wrong", models.ClientInfo{})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case errors.Is(err, ErrInvalidCredentials):
+				invalid++
+			case errors.Is(err, ErrAccountLocked):
+				locked++
+			default:
+				t.Errorf("Login = %v, want ErrInvalidCredentials or ErrAccountLocked", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Only the guesses up to the threshold get checked, however many race.
+	if invalid != 5 || locked != guesses-5 {
+		t.Fatalf("%d guesses checked and %d refused, want 5 and %d", invalid, locked, guesses-5)
+	}
+	if lock := ta.accountLock(t, "alice"); !lock.Locked(time.Now()) || lock.Lockouts != 1 {
+		t.Fatalf("account lock = %+v, want locked once", lock)
+	}
+}
+
+func TestIPThresholdUnderConcurrency(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t, WithLockout(LockoutPolicy{
+		Threshold:   1000,
+		Duration:    time.Minute,
+		MaxDuration: time.Hour,
+		IPThreshold: 3,
+		IPWindow:    time.Minute,
+	}))
+	ta.newUser(t, "alice")
+	attacker := models.ClientInfo{IP: "203.0.113.7"}
+
+	const guesses = 20
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		invalid int
+	)
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ta.Login(ctx, "alice", "wrong", attacker)
+			if errors.Is(err, ErrInvalidCredentials) {
+				mu.Lock()
+				invalid++
+				mu.Unlock()
+			} else if !errors.Is(err, ErrTooManyAttempts) {
+				t.Errorf("Login = %v, want ErrInvalidCredentials or ErrTooManyAttempts", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if invalid != 3 {
+		t.Fatalf("%d guesses checked, want 3", invalid)
+	}
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/token"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultMagicLinkTTL = 15 * time.Minute
+
+var ErrInvalidMagicLink = errors.New("invalid magic link")
+
+// RequestMagicLink emails the user a link that signs them in without a
+// password. With bindDevice the link only works together with the returned
+// device secret, which the requesting client keeps. Like ForgotPassword it
+// succeeds for unknown logins, so it cannot be used to probe for accounts.
+func (a *Auth) RequestMagicLink(ctx context.Context, login string, bindDevice bool) (string, error) {
+	const op = "auth.RequestMagicLink"
+
+	var deviceSecret string
+	if bindDevice {
+		secret, err := token.GenerateToken()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		deviceSecret = secret
+	}
+
+	user, err := a.userProvider.GetUser(ctx, login)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrUserNotFound) {
+			return deviceSecret, nil
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if user.Email == "" {
+		return deviceSecret, nil
+	}
+
+	magicToken, err := token.GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	link := models.MagicLink{
+		UserID:    user.ID,
+		TokenHash: hashToken(magicToken),
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(a.magicLinkTTL),
+	}
+	if bindDevice {
+		link.DeviceHash = hashToken(deviceSecret)
+	}
+	if err := a.magicLinkSaver.SaveMagicLink(ctx, link); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.sendMagicLinkEmail(ctx, user, magicToken, link.ExpiresAt); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return deviceSecret, nil
+}
+
+// ExchangeMagicLink signs in the user a magic link was sent to, with the same
+// result Login gives. A link works once; one bound to a device also needs the
+// device secret RequestMagicLink returned, and a wrong secret leaves it
+// unused. Following the link proves control of the address, so it also
+// verifies the email if it is still the user's.
+func (a *Auth) ExchangeMagicLink(ctx context.Context, magicToken string, deviceSecret string, client models.ClientInfo) (models.LoginResult, error) {
+	const op = "auth.ExchangeMagicLink"
+
+	link, err := a.magicLinkProvider.GetMagicLink(ctx, hashToken(magicToken))
+	if err != nil {
+		if errors.Is(err, sqlite.ErrTokenNotFound) {
+			return models.LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidMagicLink)
+		}
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if link.Used || time.Now().After(link.ExpiresAt) {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidMagicLink)
+	}
+	if link.DeviceHash != "" && subtle.ConstantTimeCompare([]byte(link.DeviceHash), []byte(hashToken(deviceSecret))) != 1 {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidMagicLink)
+	}
+	used, err := a.magicLinkUpdater.UseMagicLink(ctx, link.ID)
+	if err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !used {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidMagicLink)
+	}
+
+	user, err := a.userProvider.GetUserByID(ctx, link.UserID)
+	if err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	lock, err := a.accountLockProvider.GetAccountLock(ctx, user.ID)
+	if err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if lock.Locked(time.Now()) {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, ErrAccountLocked)
+	}
+	if user.Email == link.Email && !user.EmailVerified {
+		if err := a.userUpdater.SetEmailVerified(ctx, user.ID, true); err != nil {
+			return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+		user.EmailVerified = true
+	}
+
+	result, err := a.firstFactorPassed(ctx, user, client)
+	if err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return result, nil
+}
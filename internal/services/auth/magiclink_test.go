@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// saveMagicLink stores token as a magic link for email, issued to login and,
+// unless deviceSecret is empty, bound to that device secret.
+func (ta testAuth) saveMagicLink(t *testing.T, login, token, email, deviceSecret string, expiresAt time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := ta.userProvider.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	link := models.MagicLink{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		Email:     email,
+		ExpiresAt: expiresAt,
+	}
+	if deviceSecret != "" {
+		link.DeviceHash = hashToken(deviceSecret)
+	}
+	if err := ta.magicLinkSaver.SaveMagicLink(ctx, link); err != nil {
+		t.Fatalf("SaveMagicLink: %v", err)
+	}
+}
+
+func TestExchangeMagicLink(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+
+	ta.saveMagicLink(t, "alice", "link", "alice@example.com", "", time.Now().Add(time.Hour))
+	result, err := ta.ExchangeMagicLink(ctx, "link", "", models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("ExchangeMagicLink: %v", err)
+	}
+	if _, err := ta.ValidateToken(ctx, result.Tokens.AccessToken); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if _, err := ta.ExchangeMagicLink(ctx, "link", "", models.ClientInfo{}); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Fatalf("reused magic link = %v, want ErrInvalidMagicLink", err)
+	}
+}
+
+func TestExchangeMagicLinkRejectsExpiredAndUnknownLinks(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+
+	ta.saveMagicLink(t, "alice", "expired", "alice@example.com", "", time.Now().Add(-time.Minute))
+	if _, err := ta.ExchangeMagicLink(ctx, "expired", "", models.ClientInfo{}); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Fatalf("expired magic link = %v, want ErrInvalidMagicLink", err)
+	}
+	if _, err := ta.ExchangeMagicLink(ctx, "unknown", "", models.ClientInfo{}); !errors.Is(err, ErrInvalidMagicLink) {
+		t.Fatalf("unknown magic link = %v, want ErrInvalidMagicLink", err)
+	}
+}
+
+func TestExchangeMagicLinkBoundToDevice(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+
+	ta.saveMagicLink(t, "alice", "link", "alice@example.com", "device", time.Now().Add(time.Hour))
+	for _, secret := range []string{"", "other-device"} {
+		if _, err := ta.ExchangeMagicLink(ctx, "link", secret, models.ClientInfo{}); !errors.Is(err, ErrInvalidMagicLink) {
+			t.Fatalf("device secret %q = %v, want ErrInvalidMagicLink", secret, err)
+		}
+	}
+	// A wrong secret leaves the link unused.
+	if _, err := ta.ExchangeMagicLink(ctx, "link", "device", models.ClientInfo{}); err != nil {
+		t.Fatalf("ExchangeMagicLink with the device secret: %v", err)
+	}
+}
+
+func TestExchangeMagicLinkVerifiesEmail(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t, WithEmailVerification(true, time.Hour))
+	ta.newUser(t, "alice")
+
+	// A link sent to an address the user no longer has proves nothing.
+	ta.saveMagicLink(t, "alice", "old-address", "alice@example.org", "", time.Now().Add(time.Hour))
+	if _, err := ta.ExchangeMagicLink(ctx, "old-address", "", models.ClientInfo{}); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("link for another address = %v, want ErrEmailNotVerified", err)
+	}
+
+	ta.saveMagicLink(t, "alice", "link", "alice@example.com", "", time.Now().Add(time.Hour))
+	if _, err := ta.ExchangeMagicLink(ctx, "link", "", models.ClientInfo{}); err != nil {
+		t.Fatalf("ExchangeMagicLink: %v", err)
+	}
+	ta.login(t, "alice")
+}
+
+func TestRequestMagicLinkForUnknownLogin(t *testing.T) {
+	ta := newTestAuth(t)
+
+	secret, err := ta.RequestMagicLink(context.Background(), "nobody", true)
+	if err != nil {
+		t.Fatalf("RequestMagicLink: %v", err)
+	}
+	if secret == "" {
+		t.Fatal("RequestMagicLink returned no device secret for an unknown login")
+	}
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/symmetric"
+	"AuthGrpc/internal/lib/encryption/token"
+	"AuthGrpc/internal/lib/encryption/totp"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMFAIssuer       = "AuthGrpc"
+	defaultMFAChallengeTTL = 5 * time.Minute
+
+	// maxMFAAttempts is how many wrong codes one challenge tolerates before
+	// the user has to start over with the password.
+	maxMFAAttempts = 5
+	// totpSkew accepts codes from one step before and after the current one.
+	totpSkew = 1
+)
+
+var (
+	ErrMFADisabled       = errors.New("mfa is not configured")
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+	ErrMFANotEnrolled    = errors.New("mfa not enrolled")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+	ErrInvalidMFAToken   = errors.New("invalid mfa token")
+)
+
+// EnrollTOTP generates a new TOTP secret for the token's owner. The factor
+// only takes effect once ConfirmTOTP has seen a valid code for it.
+func (a *Auth) EnrollTOTP(ctx context.Context, jwtToken string) (secret string, uri string, err error) {
+	const op = "auth.EnrollTOTP"
+
+	if a.mfaKey == nil {
+		return "", "", fmt.Errorf("%s: %w", op, ErrMFADisabled)
+	}
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := a.userProvider.GetUserByID(ctx, accessToken.Uid)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	enrolled, err := a.mfaEnrolled(ctx, user.ID)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if enrolled {
+		return "", "", fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+	}
+
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	encrypted, err := symmetric.Encrypt(a.mfaKey, []byte(secret), mfaAdditionalData(user.ID))
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.mfaSaver.SaveMFAFactor(ctx, user.ID, encrypted); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return secret, totp.URI(a.mfaIssuer, user.Login, secret), nil
+}
+
+// ConfirmTOTP enables the enrolled factor once the user proves their
+// authenticator produces valid codes.
+func (a *Auth) ConfirmTOTP(ctx context.Context, jwtToken string, code string) (bool, error) {
+	const op = "auth.ConfirmTOTP"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	factor, err := a.mfaProvider.GetMFAFactor(ctx, accessToken.Uid)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrFactorNotFound) {
+			return false, fmt.Errorf("%s: %w", op, ErrMFANotEnrolled)
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if factor.Confirmed {
+		return false, fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+	}
+
+	step, ok, err := a.checkTOTP(factor, code)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidMFACode)
+	}
+	if err := a.mfaUpdater.ConfirmMFAFactor(ctx, factor.ID, step); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return true, nil
+}
+
+// VerifyMFA completes a Login that returned an MFA token.
+func (a *Auth) VerifyMFA(ctx context.Context, mfaToken string, code string) (models.TokenPair, error) {
+	const op = "auth.VerifyMFA"
+
+	challenge, err := a.mfaChallenge(ctx, mfaToken)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	factor, err := a.mfaProvider.GetMFAFactor(ctx, challenge.UserID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.checkMFACode(ctx, challenge, ErrInvalidMFACode, func() (bool, error) {
+		step, ok, err := a.checkTOTP(factor, code)
+		if err != nil || !ok {
+			return false, err
+		}
+		return a.mfaUpdater.UseMFAStep(ctx, factor.ID, step)
+	})
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return a.completeMFAChallenge(ctx, op, challenge)
+}
+
+func (a *Auth) mfaEnrolled(ctx context.Context, userID int64) (bool, error) {
+	factor, err := a.mfaProvider.GetMFAFactor(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrFactorNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return factor.Confirmed, nil
+}
+
+func (a *Auth) checkTOTP(factor models.MFAFactor, code string) (int64, bool, error) {
+	if a.mfaKey == nil {
+		return 0, false, ErrMFADisabled
+	}
+	secret, err := symmetric.Decrypt(a.mfaKey, factor.Secret, mfaAdditionalData(factor.UserID))
+	if err != nil {
+		return 0, false, err
+	}
+	return totp.Validate(string(secret), code, time.Now(), totpSkew)
+}
+
+func (a *Auth) startMFAChallenge(ctx context.Context, userID int64, client models.ClientInfo) (string, error) {
+	mfaToken, err := token.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+	err = a.mfaSaver.SaveMFAChallenge(ctx, models.MFAChallenge{
+		TokenHash: hashToken(mfaToken),
+		UserID:    userID,
+		Client:    client,
+		ExpiresAt: time.Now().Add(a.mfaChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return mfaToken, nil
+}
+
+// mfaChallenge looks up the challenge of the MFA token and counts an attempt
+// to pass it. A challenge that has used up its attempts is dropped.
+func (a *Auth) mfaChallenge(ctx context.Context, mfaToken string) (models.MFAChallenge, error) {
+	challenge, err := a.mfaProvider.GetMFAChallenge(ctx, hashToken(mfaToken))
+	if err != nil {
+		if errors.Is(err, sqlite.ErrCodeNotFound) {
+			return models.MFAChallenge{}, ErrInvalidMFAToken
+		}
+		return models.MFAChallenge{}, err
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return models.MFAChallenge{}, ErrInvalidMFAToken
+	}
+	ok, err := a.mfaUpdater.AddMFAChallengeAttempt(ctx, challenge.ID, maxMFAAttempts)
+	if err != nil {
+		return models.MFAChallenge{}, err
+	}
+	if !ok {
+		if _, err := a.mfaUpdater.DeleteMFAChallenge(ctx, challenge.ID); err != nil {
+			return models.MFAChallenge{}, err
+		}
+		return models.MFAChallenge{}, ErrInvalidMFAToken
+	}
+	return challenge, nil
+}
+
+// checkMFACode runs check for the user of the challenge. Wrong codes count
+// towards the same lockout as wrong passwords.
+func (a *Auth) checkMFACode(ctx context.Context, challenge models.MFAChallenge, invalid error, check func() (bool, error)) error {
+	user, err := a.userProvider.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return err
+	}
+	return a.checkCredential(ctx, user, challenge.Client.IP, invalid, check)
+}
+
+// completeMFAChallenge consumes the challenge and signs the user in.
+func (a *Auth) completeMFAChallenge(ctx context.Context, op string, challenge models.MFAChallenge) (models.TokenPair, error) {
+	deleted, err := a.mfaUpdater.DeleteMFAChallenge(ctx, challenge.ID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !deleted {
+		// Another request consumed the challenge first.
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidMFAToken)
+	}
+	user, err := a.userProvider.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	tokens, err := a.signIn(ctx, user, challenge.Client)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return tokens, nil
+}
+
+func mfaAdditionalData(userID int64) []byte {
+	return []byte("mfa:" + strconv.FormatInt(userID, 10))
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/totp"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// mfaLogin signs in a user with TOTP enrolled and returns the MFA token.
+func (ta testAuth) mfaLogin(t *testing.T, login string) string {
+	t.Helper()
+	result, err := ta.Login(context.Background(), login, testPassword, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("Login(%s): %v", login, err)
+	}
+	if result.MFAToken == "" {
+		t.Fatalf("Login(%s) did not ask for a second factor", login)
+	}
+	return result.MFAToken
+}
+
+func TestVerifyMFA(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	secret := ta.enrollTOTP(t, ta.login(t, "alice").AccessToken)
+	mfaToken := ta.mfaLogin(t, "alice")
+
+	// The step used to confirm enrollment cannot be used again.
+	code, err := totp.Code(secret, totp.Step(time.Now())+1)
+	if err != nil {
+		t.Fatalf("totp.Code: %v", err)
+	}
+	if _, err := ta.VerifyMFA(ctx, mfaToken, code); err != nil {
+		t.Fatalf("VerifyMFA: %v", err)
+	}
+	if _, err := ta.VerifyMFA(ctx, mfaToken, code); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("VerifyMFA with a completed challenge = %v, want ErrInvalidMFAToken", err)
+	}
+}
+
+func TestVerifyMFAAttemptLimit(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t, WithLockout(LockoutPolicy{Threshold: 100}))
+	ta.newUser(t, "alice")
+	secret := ta.enrollTOTP(t, ta.login(t, "alice").AccessToken)
+	mfaToken := ta.mfaLogin(t, "alice")
+
+	for i := 0; i < maxMFAAttempts; i++ {
+		if _, err := ta.VerifyMFA(ctx, mfaToken, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+	code, err := totp.Code(secret, totp.Step(time.Now())+1)
+	if err != nil {
+		t.Fatalf("totp.Code: %v", err)
+	}
+	if _, err := ta.VerifyMFA(ctx, mfaToken, code); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("VerifyMFA after %d wrong codes = %v, want ErrInvalidMFAToken", maxMFAAttempts, err)
+	}
+}
+
+func TestVerifyMFACountsTowardsLockout(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	ta.enrollTOTP(t, ta.login(t, "alice").AccessToken)
+
+	// Signing in with the right password again does not forget wrong codes.
+	for i := 0; i < defaultLockoutPolicy.Threshold; i++ {
+		mfaToken := ta.mfaLogin(t, "alice")
+		if _, err := ta.VerifyMFA(ctx, mfaToken, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+	if _, err := ta.Login(ctx, "alice", testPassword, models.ClientInfo{}); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Login = %v, want ErrAccountLocked", err)
+	}
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/pkg/i18n"
+	"AuthGrpc/internal/pkg/notifier"
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+// Notification template names, see i18n.Catalog.Render.
+const (
+	templateResetPassword = "reset_password"
+	templateVerifyEmail   = "verify_email"
+	templateNewLogin      = "new_login"
+	templatePhoneCode     = "phone_code"
+	templateMagicLink     = "magic_link"
+)
+
+var ErrUnsupportedLocale = errors.New("unsupported locale")
+
+// Links are the front-end pages emailed links point to. Each gets the token
+// appended as the "token" query parameter.
+type Links struct {
+	ResetPassword string
+	VerifyEmail   string
+	MagicLink     string
+}
+
+type tokenEmail struct {
+	Login     string
+	Link      string
+	ExpiresAt time.Time
+}
+
+type newLoginEmail struct {
+	Login     string
+	Device    string
+	UserAgent string
+	IP        string
+	Time      time.Time
+}
+
+type phoneCodeSMS struct {
+	Code    string
+	Minutes int
+}
+
+func (a *Auth) sendResetPasswordEmail(ctx context.Context, user models.User, resetToken string, expiresAt time.Time) error {
+	return a.notify(ctx, notifier.Email, user, user.Email, templateResetPassword, tokenEmail{
+		Login:     user.Login,
+		Link:      tokenLink(a.links.ResetPassword, resetToken),
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (a *Auth) sendVerificationEmail(ctx context.Context, user models.User, verificationToken string, expiresAt time.Time) error {
+	return a.notify(ctx, notifier.Email, user, user.Email, templateVerifyEmail, tokenEmail{
+		Login:     user.Login,
+		Link:      tokenLink(a.links.VerifyEmail, verificationToken),
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (a *Auth) sendMagicLinkEmail(ctx context.Context, user models.User, magicToken string, expiresAt time.Time) error {
+	return a.notify(ctx, notifier.Email, user, user.Email, templateMagicLink, tokenEmail{
+		Login:     user.Login,
+		Link:      tokenLink(a.links.MagicLink, magicToken),
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (a *Auth) sendPhoneCode(ctx context.Context, user models.User, code string) error {
+	return a.notify(ctx, notifier.SMS, user, user.Phone, templatePhoneCode, phoneCodeSMS{
+		Code:    code,
+		Minutes: int(phoneCodeTTL / time.Minute),
+	})
+}
+
+// alertNewLogin emails the user when they sign in from a device none of their
+// active sessions was started from. It never fails the sign-in.
+func (a *Auth) alertNewLogin(ctx context.Context, user models.User, client models.ClientInfo) {
+	if user.Email == "" {
+		return
+	}
+	sessions, err := a.sessionProvider.ListSessions(ctx, user.ID)
+	if err != nil {
+		a.log.Error("failed to list sessions", slog.String("error", err.Error()))
+		return
+	}
+	for _, session := range sessions {
+		if session.UserAgent == client.UserAgent {
+			return
+		}
+	}
+
+	err = a.notify(ctx, notifier.Email, user, user.Email, templateNewLogin, newLoginEmail{
+		Login:     user.Login,
+		Device:    client.DeviceName,
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+		Time:      time.Now(),
+	})
+	if err != nil {
+		a.log.Error("failed to send new login alert", slog.Int64("uid", user.ID), slog.String("error", err.Error()))
+	}
+}
+
+// notify renders the named template in the user's locale, or the one the
+// request asked for when the user has not chosen one, and sends it.
+func (a *Auth) notify(ctx context.Context, channel notifier.Channel, user models.User, to string, name string, data interface{}) error {
+	locale := a.catalog.Match(user.Locale, i18n.LocaleFromContext(ctx))
+	rendered, err := a.catalog.Render(locale, name, data)
+	if err != nil {
+		return err
+	}
+	return a.notifier.Send(ctx, notifier.Message{
+		Channel: channel,
+		To:      to,
+		Subject: rendered.Subject,
+		Body:    rendered.Text,
+		HTML:    rendered.HTML,
+	})
+}
+
+func tokenLink(base string, token string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base + "?token=" + url.QueryEscape(token)
+	}
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,584 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/token"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAuthorizationCodeTTL    = time.Minute
+	defaultAuthorizationRequestTTL = 10 * time.Minute
+
+	codeChallengeMethodS256 = "S256"
+)
+
+// OAuth error codes, see RFC 6749 sections 4.1.2.1 and 5.2.
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthAccessDenied            = "access_denied"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthServerError             = "server_error"
+	// Errors of the device authorization grant, see RFC 8628 section 3.5.
+	OAuthAuthorizationPending = "authorization_pending"
+	OAuthSlowDown             = "slow_down"
+	OAuthExpiredToken         = "expired_token"
+)
+
+var (
+	// ErrInvalidClient and ErrInvalidRedirectURI are shown to the user:
+	// without a trusted redirect URI there is nowhere to send the error.
+	ErrInvalidClient      = errors.New("invalid client")
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+	// ErrInvalidAuthorizationRequest is returned for an unknown or expired
+	// request ID.
+	ErrInvalidAuthorizationRequest = errors.New("invalid authorization request")
+)
+
+// OAuthError is an error the OAuth endpoints report to the client as is.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code string, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// OAuthSettings configure the authorization endpoint.
+type OAuthSettings struct {
+	// LoginURL is the page that signs the user in and asks for consent. It
+	// gets the pending request as the "request_id" query parameter.
+	LoginURL string
+	// CodeTTL is how long an authorization code may wait to be exchanged.
+	CodeTTL time.Duration
+	// RequestTTL is how long the user has to sign in and decide.
+	RequestTTL time.Duration
+	// IDTokenTTL is how long OpenID Connect ID tokens are valid.
+	IDTokenTTL time.Duration
+	// VerificationURL is the page where users enter the user code of the
+	// device authorization grant.
+	VerificationURL string
+	// DeviceCodeTTL is how long a device has for the user to approve it.
+	DeviceCodeTTL time.Duration
+	// DevicePollInterval is how often a device may poll at first.
+	DevicePollInterval time.Duration
+}
+
+var defaultOAuthSettings = OAuthSettings{
+	CodeTTL:            defaultAuthorizationCodeTTL,
+	RequestTTL:         defaultAuthorizationRequestTTL,
+	IDTokenTTL:         defaultIDTokenTTL,
+	DeviceCodeTTL:      defaultDeviceCodeTTL,
+	DevicePollInterval: defaultDevicePollInterval,
+}
+
+// SigningKeyRetention is how long a retired signing key must stay published:
+// the longest lifetime of the access, client credentials and ID tokens it
+// may have signed.
+func SigningKeyRetention(accessTokenTTL time.Duration, settings OAuthSettings) time.Duration {
+	idTokenTTL := settings.IDTokenTTL
+	if idTokenTTL <= 0 {
+		idTokenTTL = defaultIDTokenTTL
+	}
+	return max(accessTokenTTL, idTokenTTL)
+}
+
+// RegisterOAuthClient adds the client, or updates the one with the same ID.
+// Unless told otherwise it is a public client using the authorization_code
+// and refresh_token grants.
+func (a *Auth) RegisterOAuthClient(ctx context.Context, client models.OAuthClient) error {
+	const op = "auth.RegisterOAuthClient"
+
+	if client.ID == "" {
+		return fmt.Errorf("%s: %w: id is required", op, ErrInvalidClientMetadata)
+	}
+	if client.Name == "" {
+		client.Name = client.ID
+	}
+	if client.AuthMethod == "" {
+		client.AuthMethod = AuthMethodNone
+	}
+	if len(client.GrantTypes) == 0 {
+		client.GrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken}
+	}
+	if err := checkClientMetadata(client); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.oauthClientSaver.SaveOAuthClient(ctx, client); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// Authorize checks an authorization request and keeps it while the user
+// signs in. It returns the login page URL to send the user to.
+//
+// ErrInvalidClient and ErrInvalidRedirectURI mean the request cannot be
+// answered at its redirect URI; an *OAuthError is sent back to the client
+// there.
+func (a *Auth) Authorize(ctx context.Context, req models.AuthorizationRequest) (string, error) {
+	const op = "auth.Authorize"
+
+	client, err := a.oauthClient(ctx, req.ClientID)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if !redirectURIAllowed(client, req.RedirectURI) {
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidRedirectURI)
+	}
+
+	if !slices.Contains(client.GrantTypes, GrantAuthorizationCode) {
+		return "", fmt.Errorf("%s: %w", op, oauthError(OAuthUnauthorizedClient, "client may not use the authorization_code grant"))
+	}
+	if req.ResponseType != "code" {
+		return "", fmt.Errorf("%s: %w", op, oauthError(OAuthUnsupportedResponseType, "response_type must be code"))
+	}
+	if req.CodeChallenge == "" {
+		return "", fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidRequest, "code_challenge is required"))
+	}
+	if req.CodeChallengeMethod != codeChallengeMethodS256 {
+		return "", fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidRequest, "code_challenge_method must be S256"))
+	}
+	if challenge, err := base64.RawURLEncoding.DecodeString(req.CodeChallenge); err != nil || len(challenge) != sha256.Size {
+		return "", fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidRequest, "code_challenge is not a S256 challenge"))
+	}
+	scope, err := grantableScope(client, req.Scope)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	req.Scope = scope
+
+	requestID, err := token.GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	req.ExpiresAt = time.Now().Add(a.oauth.RequestTTL)
+	if err := a.cache.Set(ctx, authorizationRequestKey(requestID), req, a.oauth.RequestTTL); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return addQuery(a.oauth.LoginURL, url.Values{"request_id": {requestID}}), nil
+}
+
+// AuthorizationErrorRedirect sends an *OAuthError returned by Authorize back
+// to the client at the request's redirect URI.
+func (a *Auth) AuthorizationErrorRedirect(req models.AuthorizationRequest, oauthErr *OAuthError) string {
+	return a.authorizationRedirect(req, url.Values{
+		"error":             {oauthErr.Code},
+		"error_description": {oauthErr.Description},
+	})
+}
+
+// AuthorizationPrompt tells the consent page what the pending request asks
+// the signed-in user for.
+func (a *Auth) AuthorizationPrompt(ctx context.Context, jwtToken string, requestID string) (models.ConsentPrompt, error) {
+	const op = "auth.AuthorizationPrompt"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return models.ConsentPrompt{}, fmt.Errorf("%s: %w", op, err)
+	}
+	req, err := a.authorizationRequest(ctx, requestID)
+	if err != nil {
+		return models.ConsentPrompt{}, fmt.Errorf("%s: %w", op, err)
+	}
+	client, err := a.oauthClient(ctx, req.ClientID)
+	if err != nil {
+		return models.ConsentPrompt{}, fmt.Errorf("%s: %w", op, err)
+	}
+	consent, err := a.oauthConsentProvider.GetOAuthConsent(ctx, accessToken.Uid, client.ID)
+	if err != nil {
+		return models.ConsentPrompt{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	scopes := strings.Fields(req.Scope)
+	granted := strings.Fields(consent.Scope)
+	return models.ConsentPrompt{
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		Scopes:     scopes,
+		ConsentRequired: consent.GrantedAt.IsZero() || slices.ContainsFunc(scopes, func(scope string) bool {
+			return !slices.Contains(granted, scope)
+		}),
+	}, nil
+}
+
+// CompleteAuthorization records the signed-in user's decision on a pending
+// request and returns where to send them: back to the client with either an
+// authorization code or an access_denied error.
+func (a *Auth) CompleteAuthorization(ctx context.Context, jwtToken string, requestID string, approved bool) (string, error) {
+	const op = "auth.CompleteAuthorization"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	userID := accessToken.Uid
+	req, err := a.takeAuthorizationRequest(ctx, requestID)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !approved {
+		return a.authorizationRedirect(req, url.Values{
+			"error":             {OAuthAccessDenied},
+			"error_description": {"the user denied the request"},
+		}), nil
+	}
+
+	if err := a.grantConsent(ctx, userID, req.ClientID, req.Scope); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// The user signed in when the session behind their token started.
+	session, err := a.sessionProvider.GetSession(ctx, accessToken.Sid)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := token.GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	err = a.authorizationCodeSaver.SaveAuthorizationCode(ctx, models.AuthorizationCode{
+		CodeHash:      hashToken(code),
+		ClientID:      req.ClientID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         req.Scope,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		AuthTime:      session.CreatedAt,
+		ExpiresAt:     time.Now().Add(a.oauth.CodeTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return a.authorizationRedirect(req, url.Values{"code": {code}}), nil
+}
+
+// ExchangeAuthorizationCode is the authorization_code grant: it signs the
+// user into a new session for the client that was given the code. A code
+// presented twice also revokes the session it started the first time.
+func (a *Auth) ExchangeAuthorizationCode(ctx context.Context, creds models.ClientCredentials, code string, redirectURI string, codeVerifier string, client models.ClientInfo) (models.OAuthTokens, error) {
+	const op = "auth.ExchangeAuthorizationCode"
+
+	oauthClient, err := a.tokenClient(ctx, creds, GrantAuthorizationCode)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	stored, err := a.authorizationCodeProvider.GetAuthorizationCode(ctx, hashToken(code))
+	if err != nil {
+		if errors.Is(err, sqlite.ErrCodeNotFound) {
+			return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "unknown authorization code"))
+		}
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if stored.Used {
+		a.log.Warn("authorization code reuse detected", slog.Int64("uid", stored.UserID), slog.String("client", stored.ClientID))
+		if err := a.revokeSession(ctx, stored.UserID, stored.SessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "authorization code already used"))
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "authorization code expired"))
+	}
+	if stored.ClientID != oauthClient.ID || stored.RedirectURI != redirectURI {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "authorization code was issued to another client or redirect_uri"))
+	}
+	if !verifyCodeChallenge(stored.CodeChallenge, codeVerifier) {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "code_verifier does not match the code_challenge"))
+	}
+
+	user, err := a.userProvider.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if client.DeviceName == "" {
+		client.DeviceName = oauthClient.Name
+	}
+	session, err := a.startSession(ctx, user.ID, client, oauthClient.ID, stored.Scope)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	used, err := a.authorizationCodeUpdater.UseAuthorizationCode(ctx, stored.ID, session.ID)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !used {
+		// Lost a race with another exchange of the same code.
+		if err := a.revokeSession(ctx, user.ID, session.ID); err != nil {
+			return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "authorization code already used"))
+	}
+
+	tokens, err := a.issueTokens(ctx, user, session)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	idToken, err := a.issueIDToken(ctx, user, session, stored.Nonce, stored.AuthTime)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return models.OAuthTokens{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    a.accessTokenTTL,
+		Scope:        session.Scope,
+		IDToken:      idToken,
+	}, nil
+}
+
+// RefreshOAuthToken is the refresh_token grant. It works like Refresh but
+// only for the client the refresh token was issued to.
+func (a *Auth) RefreshOAuthToken(ctx context.Context, creds models.ClientCredentials, refreshToken string, client models.ClientInfo) (models.OAuthTokens, error) {
+	const op = "auth.RefreshOAuthToken"
+
+	oauthClient, err := a.tokenClient(ctx, creds, GrantRefreshToken)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	stored, err := a.refreshTokenProvider.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sqlite.ErrTokenNotFound) {
+			return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "unknown refresh token"))
+		}
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	session, err := a.sessionProvider.GetSession(ctx, stored.FamilyID)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrSessionNotFound) {
+			return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "unknown refresh token"))
+		}
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if session.ClientID == "" || session.ClientID != oauthClient.ID {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "refresh token was issued to another client"))
+	}
+
+	tokens, err := a.refresh(ctx, refreshToken, oauthClient.ID, client)
+	if err != nil {
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+			return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, oauthError(OAuthInvalidGrant, "invalid refresh token"))
+		}
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	// A refreshed ID token has no nonce, see OpenID Connect Core 12.2.
+	user, err := a.userProvider.GetUserByID(ctx, session.UserID)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	idToken, err := a.issueIDToken(ctx, user, session, "", time.Time{})
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return models.OAuthTokens{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    a.accessTokenTTL,
+		Scope:        session.Scope,
+		IDToken:      idToken,
+	}, nil
+}
+
+// grantConsent adds the scopes to the ones the user already granted the
+// client.
+func (a *Auth) grantConsent(ctx context.Context, userID int64, clientID string, scope string) error {
+	consent, err := a.oauthConsentProvider.GetOAuthConsent(ctx, userID, clientID)
+	if err != nil {
+		return err
+	}
+	granted := strings.Fields(consent.Scope)
+	for _, scope := range strings.Fields(scope) {
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return a.oauthConsentSaver.SaveOAuthConsent(ctx, models.OAuthConsent{
+		UserID:    userID,
+		ClientID:  clientID,
+		Scope:     strings.Join(granted, " "),
+		GrantedAt: time.Now(),
+	})
+}
+
+func (a *Auth) authorizationRequest(ctx context.Context, requestID string) (models.AuthorizationRequest, error) {
+	value, ok := a.cache.Get(ctx, authorizationRequestKey(requestID))
+	return pendingAuthorizationRequest(value, ok)
+}
+
+// takeAuthorizationRequest is authorizationRequest that also removes the
+// request, so of several concurrent decisions on it only one goes through.
+func (a *Auth) takeAuthorizationRequest(ctx context.Context, requestID string) (models.AuthorizationRequest, error) {
+	value, ok := a.cache.Take(ctx, authorizationRequestKey(requestID))
+	return pendingAuthorizationRequest(value, ok)
+}
+
+func pendingAuthorizationRequest(value interface{}, ok bool) (models.AuthorizationRequest, error) {
+	if !ok {
+		return models.AuthorizationRequest{}, ErrInvalidAuthorizationRequest
+	}
+	req, ok := value.(models.AuthorizationRequest)
+	if !ok || time.Now().After(req.ExpiresAt) {
+		return models.AuthorizationRequest{}, ErrInvalidAuthorizationRequest
+	}
+	return req, nil
+}
+
+func (a *Auth) oauthClient(ctx context.Context, clientID string) (models.OAuthClient, error) {
+	client, err := a.oauthClientProvider.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrClientNotFound) {
+			return models.OAuthClient{}, ErrInvalidClient
+		}
+		return models.OAuthClient{}, err
+	}
+	if client.Disabled {
+		return models.OAuthClient{}, ErrInvalidClient
+	}
+	return client, nil
+}
+
+// authorizationRedirect sends the result of a request back to the client,
+// naming this server as the issuer as RFC 9207 recommends.
+func (a *Auth) authorizationRedirect(req models.AuthorizationRequest, params url.Values) string {
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	if a.tokenOptions.Issuer != "" {
+		params.Set("iss", a.tokenOptions.Issuer)
+	}
+	return addQuery(req.RedirectURI, params)
+}
+
+// grantableScope returns the requested scope, or all of the client's scopes
+// when none were requested.
+func grantableScope(client models.OAuthClient, requested string) (string, error) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return strings.Join(client.Scopes, " "), nil
+	}
+	var granted []string
+	for _, scope := range scopes {
+		if !validScopeToken(scope) || !slices.Contains(client.Scopes, scope) {
+			return "", oauthError(OAuthInvalidScope, fmt.Sprintf("scope %q is not allowed for this client", scope))
+		}
+		if !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return strings.Join(granted, " "), nil
+}
+
+// redirectURIAllowed compares redirect URIs exactly, except that loopback
+// URIs of native apps may use any port, as RFC 8252 section 7.3 requires.
+func redirectURIAllowed(client models.OAuthClient, redirectURI string) bool {
+	if slices.Contains(client.RedirectURIs, redirectURI) {
+		return true
+	}
+	requested, err := url.Parse(redirectURI)
+	if err != nil || requested.Scheme != "http" || !loopbackHost(requested.Hostname()) {
+		return false
+	}
+	for _, registered := range client.RedirectURIs {
+		u, err := url.Parse(registered)
+		if err != nil || u.Scheme != "http" || !loopbackHost(u.Hostname()) {
+			continue
+		}
+		if u.Hostname() == requested.Hostname() && u.Path == requested.Path && u.RawQuery == requested.RawQuery {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRedirectURI accepts absolute URIs without a fragment, over https
+// unless they point at the loopback interface.
+func checkRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return fmt.Errorf("%w: %q", ErrInvalidRedirectURI, redirectURI)
+	}
+	if u.Scheme == "http" && !loopbackHost(u.Hostname()) {
+		return fmt.Errorf("%w: %q must use https", ErrInvalidRedirectURI, redirectURI)
+	}
+	return nil
+}
+
+func loopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// validScopeToken checks a scope against the scope-token grammar of RFC 6749
+// section 3.3.
+func validScopeToken(scope string) bool {
+	if scope == "" {
+		return false
+	}
+	for _, c := range scope {
+		if c < 0x21 || c > 0x7e || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// verifyCodeChallenge checks a PKCE verifier against its S256 challenge,
+// see RFC 7636 section 4.6.
+func verifyCodeChallenge(challenge string, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("-._~", c)) {
+			return false
+		}
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func addQuery(base string, params url.Values) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return base + "?" + params.Encode()
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func authorizationRequestKey(requestID string) string {
+	return "oauthRequest:" + hashToken(requestID)
+}
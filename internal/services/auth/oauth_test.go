@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshRejectsOAuthRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	ta.registerPublicClient(t)
+	tokens := ta.oauthTokens(t, "alice")
+
+	if _, err := ta.Refresh(ctx, tokens.RefreshToken, models.ClientInfo{}); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("Refresh = %v, want ErrInvalidRefreshToken", err)
+	}
+	// The rejected call must not have used the token up.
+	if _, err := ta.RefreshOAuthToken(ctx, publicClient(), tokens.RefreshToken, models.ClientInfo{}); err != nil {
+		t.Fatalf("RefreshOAuthToken: %v", err)
+	}
+}
+
+// oauthErrorCode returns the OAuth error code err carries, or "" if it is
+// not an *OAuthError.
+func oauthErrorCode(err error) string {
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) {
+		return ""
+	}
+	return oauthErr.Code
+}
+
+func TestExchangeAuthorizationCodeChecksPKCE(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	ta.registerPublicClient(t)
+	code := ta.authorizationCode(t, ta.login(t, "alice").AccessToken)
+
+	for _, verifier := range []string{"", "wrong-verifier-wrong-verifier-wrong-verifier-0"} {
+		_, err := ta.ExchangeAuthorizationCode(ctx, publicClient(), code, testRedirectURI, verifier, models.ClientInfo{})
+		if got := oauthErrorCode(err); got != OAuthInvalidGrant {
+			t.Fatalf("code_verifier %q: got %v, want %s", verifier, err, OAuthInvalidGrant)
+		}
+	}
+}
+
+func TestAuthorizationRequestIsCompletedOnce(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	ta.registerPublicClient(t)
+	accessToken := ta.login(t, "alice").AccessToken
+	requestID := ta.authorizationRequest(t)
+
+	const callers = 20
+	var completed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ta.CompleteAuthorization(ctx, accessToken, requestID, true)
+			switch {
+			case err == nil:
+				completed.Add(1)
+			case !errors.Is(err, ErrInvalidAuthorizationRequest):
+				t.Errorf("CompleteAuthorization: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := completed.Load(); got != 1 {
+		t.Fatalf("request completed %d times, want 1", got)
+	}
+}
+
+func TestAuthorizationCodeReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	ta.registerPublicClient(t)
+	code := ta.authorizationCode(t, ta.login(t, "alice").AccessToken)
+
+	tokens, err := ta.ExchangeAuthorizationCode(ctx, publicClient(), code, testRedirectURI, testVerifier, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("ExchangeAuthorizationCode: %v", err)
+	}
+	_, err = ta.ExchangeAuthorizationCode(ctx, publicClient(), code, testRedirectURI, testVerifier, models.ClientInfo{})
+	if got := oauthErrorCode(err); got != OAuthInvalidGrant {
+		t.Fatalf("reused code: got %v, want %s", err, OAuthInvalidGrant)
+	}
+	// The tokens the code was first exchanged for may have leaked with it.
+	if _, err := ta.ValidateToken(ctx, tokens.AccessToken); err == nil {
+		t.Fatal("access token of the reused code is still valid")
+	}
+	if _, err := ta.RefreshOAuthToken(ctx, publicClient(), tokens.RefreshToken, models.ClientInfo{}); oauthErrorCode(err) != OAuthInvalidGrant {
+		t.Fatalf("refresh token of the reused code: got %v, want %s", err, OAuthInvalidGrant)
+	}
+}
+
+func TestRefreshOAuthTokenRotatesAndDetectsReuse(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	ta.registerPublicClient(t)
+	first := ta.oauthTokens(t, "alice")
+
+	second, err := ta.RefreshOAuthToken(ctx, publicClient(), first.RefreshToken, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshOAuthToken: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// Replaying the rotated-out token revokes the whole family.
+	_, err = ta.RefreshOAuthToken(ctx, publicClient(), first.RefreshToken, models.ClientInfo{})
+	if got := oauthErrorCode(err); got != OAuthInvalidGrant {
+		t.Fatalf("replayed refresh token: got %v, want %s", err, OAuthInvalidGrant)
+	}
+	if _, err := ta.RefreshOAuthToken(ctx, publicClient(), second.RefreshToken, models.ClientInfo{}); oauthErrorCode(err) != OAuthInvalidGrant {
+		t.Fatalf("latest refresh token after reuse: got %v, want %s", err, OAuthInvalidGrant)
+	}
+	if _, err := ta.ValidateToken(ctx, second.AccessToken); err == nil {
+		t.Fatal("access token is still valid after refresh token reuse")
+	}
+}
+
+func TestSigningKeyRetentionCoversIDTokens(t *testing.T) {
+	tests := []struct {
+		name           string
+		accessTokenTTL time.Duration
+		idTokenTTL     time.Duration
+		want           time.Duration
+	}{
+		{"default ID token TTL", 5 * time.Second, 0, defaultIDTokenTTL},
+		{"configured ID token TTL", 5 * time.Second, 2 * time.Hour, 2 * time.Hour},
+		{"access tokens outlive ID tokens", 3 * time.Hour, time.Hour, 3 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SigningKeyRetention(tt.accessTokenTTL, OAuthSettings{IDTokenTTL: tt.idTokenTTL})
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/jwk"
+	"AuthGrpc/internal/lib/encryption/jwt"
+	"AuthGrpc/internal/lib/encryption/signer"
+	"AuthGrpc/internal/lib/encryption/token"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Grant types a client may be allowed to use at /token.
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	GrantDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// Token endpoint auth methods of RFC 7591 section 2 and OpenID Connect Core
+// section 9.
+const (
+	AuthMethodNone              = "none"
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+	AuthMethodPrivateKeyJWT     = "private_key_jwt"
+)
+
+// clientAssertionTypeJWT is the client_assertion_type of RFC 7523 section
+// 2.2.
+const clientAssertionTypeJWT = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+var ErrInvalidClientMetadata = errors.New("invalid client metadata")
+
+var (
+	grantTypes  = []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials, GrantDeviceCode}
+	authMethods = []string{AuthMethodNone, AuthMethodClientSecretBasic, AuthMethodClientSecretPost, AuthMethodPrivateKeyJWT}
+	// assertionAlgorithms are the algorithms private_key_jwt clients may
+	// sign with.
+	assertionAlgorithms = []string{string(signer.ES256), string(signer.ES384), string(signer.EdDSA), string(signer.RS256), string(signer.PS256)}
+)
+
+// CreateOAuthClient registers a client on behalf of an admin and returns it
+// with its generated ID. Clients authenticating with a secret also get the
+// secret, which is only stored hashed and so cannot be shown again.
+func (a *Auth) CreateOAuthClient(ctx context.Context, jwtToken string, client models.OAuthClient) (models.OAuthClient, string, error) {
+	const op = "auth.CreateOAuthClient"
+
+	if err := a.requireAdmin(ctx, jwtToken); err != nil {
+		return models.OAuthClient{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	if client.AuthMethod == "" {
+		client.AuthMethod = AuthMethodClientSecretBasic
+	}
+	if len(client.GrantTypes) == 0 {
+		client.GrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken}
+	}
+	if err := checkClientMetadata(client); err != nil {
+		return models.OAuthClient{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	clientID, err := token.GenerateToken()
+	if err != nil {
+		return models.OAuthClient{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	client.ID = clientID
+	if client.Name == "" {
+		client.Name = client.ID
+	}
+	var secret string
+	if usesSecret(client.AuthMethod) {
+		secret, err = token.GenerateToken()
+		if err != nil {
+			return models.OAuthClient{}, "", fmt.Errorf("%s: %w", op, err)
+		}
+		client.SecretHash = hashToken(secret)
+	}
+	if err := a.oauthClientSaver.SaveOAuthClient(ctx, client); err != nil {
+		return models.OAuthClient{}, "", fmt.Errorf("%s: %w", op, err)
+	}
+	client.SecretHash = ""
+	return client, secret, nil
+}
+
+// RotateOAuthClientSecret replaces the secret of a client authenticating
+// with one. The old secret stops working at once.
+func (a *Auth) RotateOAuthClientSecret(ctx context.Context, jwtToken string, clientID string) (string, error) {
+	const op = "auth.RotateOAuthClientSecret"
+
+	if err := a.requireAdmin(ctx, jwtToken); err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	client, err := a.oauthClientProvider.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrClientNotFound) {
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidClient)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if !usesSecret(client.AuthMethod) {
+		return "", fmt.Errorf("%s: %w: client does not authenticate with a secret", op, ErrInvalidClientMetadata)
+	}
+
+	secret, err := token.GenerateToken()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	ok, err := a.oauthClientUpdater.UpdateOAuthClientSecret(ctx, client.ID, hashToken(secret))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidClient)
+	}
+	a.log.Info("oauth client secret rotated", slog.String("client", client.ID))
+	return secret, nil
+}
+
+// DisableOAuthClient stops a client from getting tokens, makes the access
+// tokens it already has invalid and revokes its sessions and refresh tokens.
+func (a *Auth) DisableOAuthClient(ctx context.Context, jwtToken string, clientID string) (bool, error) {
+	const op = "auth.DisableOAuthClient"
+
+	if err := a.requireAdmin(ctx, jwtToken); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	ok, err := a.oauthClientUpdater.DisableOAuthClient(ctx, clientID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidClient)
+	}
+	_ = a.cache.Delete(ctx, clientActiveKey(clientID))
+	ids, err := a.sessionUpdater.RevokeClientSessions(ctx, clientID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, id := range ids {
+		_ = a.cache.Delete(ctx, sessionKey(id))
+	}
+	a.log.Info("oauth client disabled", slog.String("client", clientID), slog.Int("sessions", len(ids)))
+	return true, nil
+}
+
+// ClientCredentialsToken is the client_credentials grant of RFC 6749
+// section 4.4: a confidential client gets an access token for itself, with
+// no user and no refresh token.
+func (a *Auth) ClientCredentialsToken(ctx context.Context, creds models.ClientCredentials, scope string) (models.OAuthTokens, error) {
+	const op = "auth.ClientCredentialsToken"
+
+	client, err := a.tokenClient(ctx, creds, GrantClientCredentials)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	// Without a user there is nobody for openid to be about.
+	client.Scopes = slices.DeleteFunc(slices.Clone(client.Scopes), func(s string) bool {
+		return s == ScopeOpenID
+	})
+	scope, err = grantableScope(client, scope)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	signingKey, err := a.keys.Active()
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	accessToken, err := jwt.GenerateClientToken(ctx, client.ID, scope, a.accessTokenTTL, signingKey.Signer(), a.tokenOptions)
+	if err != nil {
+		return models.OAuthTokens{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.cache.Set(ctx, clientActiveKey(client.ID), true, a.accessTokenTTL); err != nil {
+		a.log.Error("failed to save in cache", slog.String("error", err.Error()))
+	}
+	return models.OAuthTokens{
+		AccessToken: accessToken,
+		ExpiresIn:   a.accessTokenTTL,
+		Scope:       scope,
+	}, nil
+}
+
+// tokenClient authenticates the client calling /token and checks that it
+// may use the grant.
+func (a *Auth) tokenClient(ctx context.Context, creds models.ClientCredentials, grantType string) (models.OAuthClient, error) {
+	client, err := a.authenticateClient(ctx, creds)
+	if err != nil {
+		return models.OAuthClient{}, err
+	}
+	if !slices.Contains(client.GrantTypes, grantType) {
+		return models.OAuthClient{}, oauthError(OAuthUnauthorizedClient, fmt.Sprintf("client may not use the %s grant", grantType))
+	}
+	return client, nil
+}
+
+// authenticateClient checks the credentials against the auth method the
+// client registered. Every failure is an invalid_client *OAuthError, so the
+// response does not tell which part was wrong.
+func (a *Auth) authenticateClient(ctx context.Context, creds models.ClientCredentials) (models.OAuthClient, error) {
+	invalidClient := oauthError(OAuthInvalidClient, "client authentication failed")
+
+	if creds.ID == "" && creds.Assertion != "" {
+		subject, err := jwt.AssertionSubject(creds.Assertion)
+		if err != nil {
+			return models.OAuthClient{}, invalidClient
+		}
+		creds.ID = subject
+	}
+	if creds.ID == "" {
+		return models.OAuthClient{}, oauthError(OAuthInvalidClient, "client_id is required")
+	}
+	client, err := a.oauthClient(ctx, creds.ID)
+	if err != nil {
+		if errors.Is(err, ErrInvalidClient) {
+			return models.OAuthClient{}, invalidClient
+		}
+		return models.OAuthClient{}, err
+	}
+	if creds.Method != client.AuthMethod {
+		return models.OAuthClient{}, invalidClient
+	}
+
+	switch client.AuthMethod {
+	case AuthMethodNone:
+	case AuthMethodClientSecretBasic, AuthMethodClientSecretPost:
+		if client.SecretHash == "" || subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(hashToken(creds.Secret))) != 1 {
+			return models.OAuthClient{}, invalidClient
+		}
+	case AuthMethodPrivateKeyJWT:
+		if err := a.verifyClientAssertion(ctx, client, creds); err != nil {
+			a.log.Warn("client assertion rejected", slog.String("client", client.ID), slog.String("error", err.Error()))
+			return models.OAuthClient{}, invalidClient
+		}
+	default:
+		return models.OAuthClient{}, invalidClient
+	}
+	return client, nil
+}
+
+// verifyClientAssertion checks a private_key_jwt assertion, which must name
+// this server's token endpoint or issuer as its audience and may be used
+// only once.
+func (a *Auth) verifyClientAssertion(ctx context.Context, client models.OAuthClient, creds models.ClientCredentials) error {
+	if creds.AssertionType != clientAssertionTypeJWT {
+		return errors.New("unsupported client_assertion_type")
+	}
+	set, err := jwk.ParseSet([]byte(client.JWKS))
+	if err != nil {
+		return err
+	}
+	issuer := strings.TrimSuffix(a.tokenOptions.Issuer, "/")
+	audience := []string{issuer + "/token", issuer, a.tokenOptions.Issuer}
+	assertion, err := jwt.ParseClientAssertion(ctx, creds.Assertion, client.ID, clientKeys(set.Keys), audience)
+	if err != nil {
+		return err
+	}
+
+	uses, err := a.cache.Increment(ctx, "clientAssertion:"+client.ID+":"+assertion.Jti, 1, time.Until(assertion.Exp))
+	if err != nil {
+		return err
+	}
+	if uses > 1 {
+		return errors.New("client assertion replayed")
+	}
+	return nil
+}
+
+// clientActive reports whether a token issued to the client may still be
+// used, which stops once the client is disabled.
+func (a *Auth) clientActive(ctx context.Context, clientID string) (bool, error) {
+	if _, ok := a.cache.Get(ctx, clientActiveKey(clientID)); ok {
+		return true, nil
+	}
+	client, err := a.oauthClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, ErrInvalidClient) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := a.cache.Set(ctx, clientActiveKey(client.ID), true, a.accessTokenTTL); err != nil {
+		a.log.Error("failed to save in cache", slog.String("error", err.Error()))
+	}
+	return true, nil
+}
+
+// checkClientMetadata validates a client before it is saved.
+func checkClientMetadata(client models.OAuthClient) error {
+	if !slices.Contains(authMethods, client.AuthMethod) {
+		return fmt.Errorf("%w: unsupported auth method %q", ErrInvalidClientMetadata, client.AuthMethod)
+	}
+	for _, grantType := range client.GrantTypes {
+		if !slices.Contains(grantTypes, grantType) {
+			return fmt.Errorf("%w: unsupported grant type %q", ErrInvalidClientMetadata, grantType)
+		}
+	}
+	if slices.Contains(client.GrantTypes, GrantRefreshToken) &&
+		!slices.Contains(client.GrantTypes, GrantAuthorizationCode) && !slices.Contains(client.GrantTypes, GrantDeviceCode) {
+		return fmt.Errorf("%w: refresh_token needs authorization_code or device_code", ErrInvalidClientMetadata)
+	}
+	if slices.Contains(client.GrantTypes, GrantClientCredentials) && client.AuthMethod == AuthMethodNone {
+		return fmt.Errorf("%w: client_credentials needs a confidential client", ErrInvalidClientMetadata)
+	}
+	if slices.Contains(client.GrantTypes, GrantAuthorizationCode) && len(client.RedirectURIs) == 0 {
+		return fmt.Errorf("%w: authorization_code needs redirect uris", ErrInvalidClientMetadata)
+	}
+	for _, redirectURI := range client.RedirectURIs {
+		if err := checkRedirectURI(redirectURI); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidClientMetadata, err)
+		}
+	}
+	for _, scope := range client.Scopes {
+		if !validScopeToken(scope) {
+			return fmt.Errorf("%w: bad scope %q", ErrInvalidClientMetadata, scope)
+		}
+	}
+
+	if client.AuthMethod != AuthMethodPrivateKeyJWT {
+		if client.JWKS != "" {
+			return fmt.Errorf("%w: jwks is only used by private_key_jwt", ErrInvalidClientMetadata)
+		}
+		return nil
+	}
+	set, err := jwk.ParseSet([]byte(client.JWKS))
+	if err != nil {
+		return fmt.Errorf("%w: jwks: %w", ErrInvalidClientMetadata, err)
+	}
+	for _, key := range set.Keys {
+		if _, err := keyVerifier(key); err != nil {
+			return fmt.Errorf("%w: jwks: %w", ErrInvalidClientMetadata, err)
+		}
+	}
+	if len(set.Keys) > 1 && slices.ContainsFunc(set.Keys, func(key jwk.Key) bool { return key.Kid == "" }) {
+		return fmt.Errorf("%w: jwks: keys need a kid", ErrInvalidClientMetadata)
+	}
+	return nil
+}
+
+func usesSecret(authMethod string) bool {
+	return authMethod == AuthMethodClientSecretBasic || authMethod == AuthMethodClientSecretPost
+}
+
+// clientKeys resolves the keys a private_key_jwt client registered. An
+// assertion without a kid is verified with the only key of the set.
+type clientKeys []jwk.Key
+
+func (keys clientKeys) VerificationKey(kid string) (signer.Verifier, error) {
+	for _, key := range keys {
+		if key.Kid == kid || (kid == "" && len(keys) == 1) {
+			return keyVerifier(key)
+		}
+	}
+	return signer.Verifier{}, fmt.Errorf("unknown key %q", kid)
+}
+
+// keyVerifier turns a client's JWK into a verifier. A key without an alg
+// gets the usual one for its type.
+func keyVerifier(key jwk.Key) (signer.Verifier, error) {
+	if key.Use != "" && key.Use != "sig" {
+		return signer.Verifier{}, fmt.Errorf("key %q is not a signing key", key.Kid)
+	}
+	publicKey, err := key.PublicKey()
+	if err != nil {
+		return signer.Verifier{}, err
+	}
+	alg := key.Alg
+	if alg == "" {
+		alg = defaultAlgorithm(publicKey)
+	}
+	algorithm, err := signer.ParseAlgorithm(alg)
+	if err != nil {
+		return signer.Verifier{}, err
+	}
+	if !algorithm.Accepts(publicKey) {
+		return signer.Verifier{}, fmt.Errorf("alg %s does not match key %q", algorithm, key.Kid)
+	}
+	return signer.Verifier{Algorithm: algorithm, Key: publicKey}, nil
+}
+
+func defaultAlgorithm(publicKey crypto.PublicKey) string {
+	switch pub := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if pub.Curve == elliptic.P384() {
+			return string(signer.ES384)
+		}
+		return string(signer.ES256)
+	case ed25519.PublicKey:
+		return string(signer.EdDSA)
+	case *rsa.PublicKey:
+		return string(signer.RS256)
+	default:
+		return ""
+	}
+}
+
+func clientActiveKey(clientID string) string {
+	return "oauthClient:" + clientID
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/jwk"
+	"AuthGrpc/internal/lib/encryption/signer"
+	"context"
+	"crypto"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testTokenEndpoint = "https://auth.example.com/token"
+
+// registerSecretClient registers a client_credentials client authenticating
+// with client_secret_basic.
+func (ta testAuth) registerSecretClient(t *testing.T, clientID string, secret string) {
+	t.Helper()
+	err := ta.RegisterOAuthClient(context.Background(), models.OAuthClient{
+		ID:         clientID,
+		Scopes:     []string{"profile"},
+		GrantTypes: []string{GrantClientCredentials},
+		AuthMethod: AuthMethodClientSecretBasic,
+		SecretHash: hashToken(secret),
+	})
+	if err != nil {
+		t.Fatalf("RegisterOAuthClient: %v", err)
+	}
+}
+
+// registerPrivateKeyJWTClient registers a client_credentials client
+// authenticating with private_key_jwt and returns its signing key.
+func (ta testAuth) registerPrivateKeyJWTClient(t *testing.T, clientID string) crypto.Signer {
+	t.Helper()
+	key, err := signer.ES256.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	publicJWK, err := jwk.FromPublicKey(key.Public(), string(signer.ES256))
+	if err != nil {
+		t.Fatalf("FromPublicKey: %v", err)
+	}
+	jwks, err := json.Marshal(jwk.Set{Keys: []jwk.Key{publicJWK}})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	err = ta.RegisterOAuthClient(context.Background(), models.OAuthClient{
+		ID:         clientID,
+		Scopes:     []string{"profile"},
+		GrantTypes: []string{GrantClientCredentials},
+		AuthMethod: AuthMethodPrivateKeyJWT,
+		JWKS:       string(jwks),
+	})
+	if err != nil {
+		t.Fatalf("RegisterOAuthClient: %v", err)
+	}
+	return key
+}
+
+// clientAssertion signs a fresh private_key_jwt assertion for clientID.
+func clientAssertion(t *testing.T, key crypto.Signer, clientID string, audience string) models.ClientCredentials {
+	t.Helper()
+	token := gojwt.NewWithClaims(gojwt.SigningMethodES256, gojwt.RegisteredClaims{
+		Issuer:    clientID,
+		Subject:   clientID,
+		Audience:  gojwt.ClaimStrings{audience},
+		ExpiresAt: gojwt.NewNumericDate(time.Now().Add(time.Minute)),
+		ID:        uuid.NewString(),
+	})
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+	return models.ClientCredentials{
+		Method:        AuthMethodPrivateKeyJWT,
+		Assertion:     signed,
+		AssertionType: clientAssertionTypeJWT,
+	}
+}
+
+func TestClientAuthentication(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	const secret = "client-secret"
+	ta.registerSecretClient(t, "service", secret)
+	ta.registerPublicClient(t)
+
+	if _, err := ta.ClientCredentialsToken(ctx, models.ClientCredentials{ID: "service", Method: AuthMethodClientSecretBasic, Secret: secret}, "profile"); err != nil {
+		t.Fatalf("ClientCredentialsToken: %v", err)
+	}
+	tests := []struct {
+		name  string
+		creds models.ClientCredentials
+		want  string
+	}{
+		{"wrong secret", models.ClientCredentials{ID: "service", Method: AuthMethodClientSecretBasic, Secret: "wrong"}, OAuthInvalidClient},
+		{"no secret", models.ClientCredentials{ID: "service", Method: AuthMethodClientSecretBasic}, OAuthInvalidClient},
+		{"other method", models.ClientCredentials{ID: "service", Method: AuthMethodClientSecretPost, Secret: secret}, OAuthInvalidClient},
+		{"as public client", models.ClientCredentials{ID: "service", Method: AuthMethodNone}, OAuthInvalidClient},
+		{"unknown client", models.ClientCredentials{ID: "nobody", Method: AuthMethodClientSecretBasic, Secret: secret}, OAuthInvalidClient},
+		{"no client id", models.ClientCredentials{Method: AuthMethodClientSecretBasic, Secret: secret}, OAuthInvalidClient},
+		{"grant not registered", publicClient(), OAuthUnauthorizedClient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ta.ClientCredentialsToken(ctx, tt.creds, "profile")
+			if got := oauthErrorCode(err); got != tt.want {
+				t.Fatalf("got %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotatedAndDisabledClientSecretsFail(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "admin")
+	ta.exec(t, "UPDATE users SET role = 'admin' WHERE login = 'admin'")
+	adminToken := ta.login(t, "admin").AccessToken
+	const oldSecret = "client-secret"
+	ta.registerSecretClient(t, "service", oldSecret)
+
+	newSecret, err := ta.RotateOAuthClientSecret(ctx, adminToken, "service")
+	if err != nil {
+		t.Fatalf("RotateOAuthClientSecret: %v", err)
+	}
+	creds := func(secret string) models.ClientCredentials {
+		return models.ClientCredentials{ID: "service", Method: AuthMethodClientSecretBasic, Secret: secret}
+	}
+	if _, err := ta.ClientCredentialsToken(ctx, creds(oldSecret), "profile"); oauthErrorCode(err) != OAuthInvalidClient {
+		t.Fatalf("old secret after rotation: got %v, want %s", err, OAuthInvalidClient)
+	}
+	if _, err := ta.ClientCredentialsToken(ctx, creds(newSecret), "profile"); err != nil {
+		t.Fatalf("new secret after rotation: %v", err)
+	}
+
+	if _, err := ta.DisableOAuthClient(ctx, adminToken, "service"); err != nil {
+		t.Fatalf("DisableOAuthClient: %v", err)
+	}
+	if _, err := ta.ClientCredentialsToken(ctx, creds(newSecret), "profile"); oauthErrorCode(err) != OAuthInvalidClient {
+		t.Fatalf("secret of a disabled client: got %v, want %s", err, OAuthInvalidClient)
+	}
+}
+
+func TestPrivateKeyJWTClientAuthentication(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	key := ta.registerPrivateKeyJWTClient(t, "service")
+
+	creds := clientAssertion(t, key, "service", testTokenEndpoint)
+	if _, err := ta.ClientCredentialsToken(ctx, creds, "profile"); err != nil {
+		t.Fatalf("ClientCredentialsToken: %v", err)
+	}
+	if _, err := ta.ClientCredentialsToken(ctx, creds, "profile"); oauthErrorCode(err) != OAuthInvalidClient {
+		t.Fatalf("replayed assertion: got %v, want %s", err, OAuthInvalidClient)
+	}
+
+	otherKey, err := signer.ES256.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	wrongType := clientAssertion(t, key, "service", testTokenEndpoint)
+	wrongType.AssertionType = "urn:example:other"
+	tests := map[string]models.ClientCredentials{
+		"other key":      clientAssertion(t, otherKey, "service", testTokenEndpoint),
+		"other audience": clientAssertion(t, key, "service", "https://other.example.com/token"),
+		"other client":   clientAssertion(t, key, "other", testTokenEndpoint),
+		"assertion type": wrongType,
+	}
+	for name, creds := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ta.ClientCredentialsToken(ctx, creds, "profile"); oauthErrorCode(err) != OAuthInvalidClient {
+				t.Fatalf("got %v, want %s", err, OAuthInvalidClient)
+			}
+		})
+	}
+}
+
+func TestClientAssertionReplayUnderConcurrency(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	key := ta.registerPrivateKeyJWTClient(t, "service")
+	creds := clientAssertion(t, key, "service", testTokenEndpoint)
+
+	const callers = 20
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ta.ClientCredentialsToken(ctx, creds, "profile"); err == nil {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := accepted.Load(); got != 1 {
+		t.Fatalf("assertion accepted %d times, want 1", got)
+	}
+}
+
+func TestDisableOAuthClientRevokesSessions(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "admin")
+	ta.exec(t, "UPDATE users SET role = 'admin' WHERE login = 'admin'")
+	ta.newUser(t, "alice")
+	ta.registerPublicClient(t)
+	tokens := ta.oauthTokens(t, "alice")
+
+	if _, err := ta.DisableOAuthClient(ctx, ta.login(t, "admin").AccessToken, testClientID); err != nil {
+		t.Fatalf("DisableOAuthClient: %v", err)
+	}
+
+	if _, err := ta.ValidateToken(ctx, tokens.AccessToken); err == nil {
+		t.Fatal("access token of a disabled client still valid")
+	}
+	stored, err := ta.refreshTokenProvider.GetRefreshToken(ctx, hashToken(tokens.RefreshToken))
+	if err != nil {
+		t.Fatalf("GetRefreshToken: %v", err)
+	}
+	if !stored.Revoked {
+		t.Fatal("refresh token of a disabled client not revoked")
+	}
+	session, err := ta.sessionProvider.GetSession(ctx, stored.FamilyID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if !session.Revoked {
+		t.Fatal("session of a disabled client not revoked")
+	}
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/jwt"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultIDTokenTTL = time.Hour
+
+// Scopes of OpenID Connect Core section 5.4. Each but openid adds claims
+// about the user to the ID token and userinfo.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopePhone   = "phone"
+)
+
+var ErrInsufficientScope = errors.New("insufficient scope")
+
+// scopeClaims are the claims each scope releases.
+var scopeClaims = map[string][]string{
+	ScopeProfile: {"preferred_username", "locale"},
+	ScopeEmail:   {"email", "email_verified"},
+	ScopePhone:   {"phone_number", "phone_number_verified"},
+}
+
+// OpenIDProvider describes this server for the discovery document.
+func (a *Auth) OpenIDProvider() models.OpenIDProvider {
+	var algorithms []string
+	for _, key := range a.keys.Keys() {
+		if !slices.Contains(algorithms, string(key.Algorithm)) {
+			algorithms = append(algorithms, string(key.Algorithm))
+		}
+	}
+	claims := []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce"}
+	scopes := []string{ScopeOpenID}
+	for _, scope := range []string{ScopeProfile, ScopeEmail, ScopePhone} {
+		scopes = append(scopes, scope)
+		claims = append(claims, scopeClaims[scope]...)
+	}
+	return models.OpenIDProvider{
+		Issuer:                      a.tokenOptions.Issuer,
+		SigningAlgorithms:           algorithms,
+		Scopes:                      scopes,
+		Claims:                      claims,
+		GrantTypes:                  grantTypes,
+		TokenEndpointAuthMethods:    authMethods,
+		TokenEndpointAuthAlgorithms: assertionAlgorithms,
+	}
+}
+
+// UserInfo returns the claims about the token's owner that the scopes granted
+// to its client allow, as the userinfo endpoint of OpenID Connect Core
+// section 5.3 does. The token must have been issued with the openid scope.
+func (a *Auth) UserInfo(ctx context.Context, jwtToken string) (map[string]interface{}, error) {
+	const op = "auth.UserInfo"
+
+	accessToken, err := a.ValidateToken(ctx, jwtToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if accessToken.ClientID == "" || !hasScope(accessToken.Scope, ScopeOpenID) {
+		return nil, fmt.Errorf("%s: %w", op, ErrInsufficientScope)
+	}
+	user, err := a.userProvider.GetUserByID(ctx, accessToken.Uid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	claims := userClaims(user, accessToken.Scope)
+	claims["sub"] = strconv.FormatInt(user.ID, 10)
+	return claims, nil
+}
+
+// issueIDToken returns an ID token when the openid scope was granted, and an
+// empty string otherwise.
+func (a *Auth) issueIDToken(ctx context.Context, user models.User, session models.Session, nonce string, authTime time.Time) (string, error) {
+	if !hasScope(session.Scope, ScopeOpenID) {
+		return "", nil
+	}
+	signingKey, err := a.keys.Active()
+	if err != nil {
+		return "", err
+	}
+	return jwt.GenerateIDToken(ctx, jwt.IDToken{
+		Subject:  strconv.FormatInt(user.ID, 10),
+		ClientID: session.ClientID,
+		Nonce:    nonce,
+		AuthTime: authTime,
+		Claims:   userClaims(user, session.Scope),
+	}, a.oauth.IDTokenTTL, signingKey.Signer(), a.tokenOptions)
+}
+
+// userClaims maps the user to the standard claims of OpenID Connect Core
+// section 5.1 that the scope releases. Empty values are left out, but
+// verification flags are always given with their address.
+func userClaims(user models.User, scope string) map[string]interface{} {
+	claims := map[string]interface{}{}
+	if hasScope(scope, ScopeProfile) {
+		claims["preferred_username"] = user.Login
+		if user.Locale != "" {
+			claims["locale"] = user.Locale
+		}
+	}
+	if hasScope(scope, ScopeEmail) && user.Email != "" {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerified
+	}
+	if hasScope(scope, ScopePhone) && user.Phone != "" {
+		claims["phone_number"] = user.Phone
+		claims["phone_number_verified"] = user.PhoneVerified
+	}
+	return claims
+}
+
+func hasScope(scope string, want string) bool {
+	return slices.Contains(strings.Fields(scope), want)
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// openIDTokens registers testClientID for the OpenID Connect scopes and signs
+// the user in to it with scope.
+func (ta testAuth) openIDTokens(t *testing.T, login string, scope string) models.OAuthTokens {
+	t.Helper()
+	ctx := context.Background()
+	err := ta.RegisterOAuthClient(ctx, models.OAuthClient{
+		ID:           testClientID,
+		RedirectURIs: []string{testRedirectURI},
+		Scopes:       []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopePhone},
+	})
+	if err != nil {
+		t.Fatalf("RegisterOAuthClient: %v", err)
+	}
+	challenge := sha256.Sum256([]byte(testVerifier))
+	loginURL, err := ta.Authorize(ctx, models.AuthorizationRequest{
+		ClientID:            testClientID,
+		RedirectURI:         testRedirectURI,
+		ResponseType:        "code",
+		Scope:               scope,
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(challenge[:]),
+		CodeChallengeMethod: codeChallengeMethodS256,
+	})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	redirect, err := ta.CompleteAuthorization(ctx, ta.login(t, login).AccessToken, queryParam(t, loginURL, "request_id"), true)
+	if err != nil {
+		t.Fatalf("CompleteAuthorization: %v", err)
+	}
+	tokens, err := ta.ExchangeAuthorizationCode(ctx, publicClient(), queryParam(t, redirect, "code"), testRedirectURI, testVerifier, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("ExchangeAuthorizationCode: %v", err)
+	}
+	return tokens
+}
+
+func TestUserClaims(t *testing.T) {
+	user := models.User{
+		ID:            7,
+		Login:         "alice",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Phone:         "+15550100",
+		Locale:        "ru",
+	}
+	tests := []struct {
+		name  string
+		user  models.User
+		scope string
+		want  map[string]interface{}
+	}{
+		{"openid alone", user, "openid", map[string]interface{}{}},
+		{"profile", user, "openid profile", map[string]interface{}{
+			"preferred_username": "alice",
+			"locale":             "ru",
+		}},
+		{"email and phone", user, "openid email phone", map[string]interface{}{
+			"email":                 "alice@example.com",
+			"email_verified":        true,
+			"phone_number":          "+15550100",
+			"phone_number_verified": false,
+		}},
+		{"empty values are left out", models.User{Login: "bob"}, "openid profile email phone", map[string]interface{}{
+			"preferred_username": "bob",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := userClaims(tt.user, tt.scope); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("userClaims(%q) = %v, want %v", tt.scope, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	user, err := ta.userProvider.GetUser(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+
+	tokens := ta.openIDTokens(t, "alice", "openid email")
+	if tokens.IDToken == "" {
+		t.Fatal("no ID token for the openid scope")
+	}
+	claims, err := ta.UserInfo(ctx, tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("UserInfo: %v", err)
+	}
+	want := map[string]interface{}{
+		"sub":            strconv.FormatInt(user.ID, 10),
+		"email":          "alice@example.com",
+		"email_verified": false,
+	}
+	if !reflect.DeepEqual(claims, want) {
+		t.Fatalf("UserInfo = %v, want %v", claims, want)
+	}
+}
+
+func TestUserInfoRequiresOpenIDScope(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+
+	tokens := ta.openIDTokens(t, "alice", "profile email")
+	if tokens.IDToken != "" {
+		t.Fatal("ID token issued without the openid scope")
+	}
+	if _, err := ta.UserInfo(ctx, tokens.AccessToken); !errors.Is(err, ErrInsufficientScope) {
+		t.Fatalf("UserInfo without openid = %v, want ErrInsufficientScope", err)
+	}
+	if _, err := ta.UserInfo(ctx, ta.login(t, "alice").AccessToken); !errors.Is(err, ErrInsufficientScope) {
+		t.Fatalf("UserInfo with a first-party token = %v, want ErrInsufficientScope", err)
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"AuthGrpc/internal/lib/password"
+	"AuthGrpc/internal/lib/webauthn"
+	"AuthGrpc/internal/pkg/i18n"
+	"AuthGrpc/internal/pkg/notifier"
+	"time"
+)
+
+type Option func(*Auth)
+
+// WithMFA enables TOTP enrollment. encryptionKey protects the stored secrets,
+// issuer is the account label shown by authenticator apps and challengeTTL
+// bounds the time between the password step and VerifyMFA.
+func WithMFA(encryptionKey []byte, issuer string, challengeTTL time.Duration) Option {
+	return func(a *Auth) {
+		a.mfaKey = encryptionKey
+		if issuer != "" {
+			a.mfaIssuer = issuer
+		}
+		if challengeTTL > 0 {
+			a.mfaChallengeTTL = challengeTTL
+		}
+	}
+}
+
+// WithWebAuthn enables passkey registration and login for the relying party
+// described by cfg.
+func WithWebAuthn(cfg webauthn.Config) Option {
+	return func(a *Auth) {
+		a.webauthn = &cfg
+	}
+}
+
+// WithEmailVerification sets whether Login is refused until the user has
+// verified their email address, and how long verification tokens live.
+func WithEmailVerification(required bool, tokenTTL time.Duration) Option {
+	return func(a *Auth) {
+		a.requireVerifiedEmail = required
+		if tokenTTL > 0 {
+			a.verificationTokenTTL = tokenTTL
+		}
+	}
+}
+
+// WithMagicLinkTTL sets how long emailed sign-in links stay valid.
+func WithMagicLinkTTL(ttl time.Duration) Option {
+	return func(a *Auth) {
+		if ttl > 0 {
+			a.magicLinkTTL = ttl
+		}
+	}
+}
+
+// WithOAuth replaces the non-zero fields of the default OAuth settings.
+func WithOAuth(settings OAuthSettings) Option {
+	return func(a *Auth) {
+		if settings.LoginURL != "" {
+			a.oauth.LoginURL = settings.LoginURL
+		}
+		if settings.CodeTTL > 0 {
+			a.oauth.CodeTTL = settings.CodeTTL
+		}
+		if settings.RequestTTL > 0 {
+			a.oauth.RequestTTL = settings.RequestTTL
+		}
+		if settings.IDTokenTTL > 0 {
+			a.oauth.IDTokenTTL = settings.IDTokenTTL
+		}
+		if settings.VerificationURL != "" {
+			a.oauth.VerificationURL = settings.VerificationURL
+		}
+		if settings.DeviceCodeTTL > 0 {
+			a.oauth.DeviceCodeTTL = settings.DeviceCodeTTL
+		}
+		if settings.DevicePollInterval > 0 {
+			a.oauth.DevicePollInterval = settings.DevicePollInterval
+		}
+	}
+}
+
+// WithNotifier sets how emails and text messages reach users and the pages
+// emailed links point to.
+func WithNotifier(n notifier.Notifier, links Links) Option {
+	return func(a *Auth) {
+		a.notifier = n
+		a.links = links
+	}
+}
+
+// WithCatalog sets the templates notifications are rendered from and the
+// locales users may choose.
+func WithCatalog(catalog *i18n.Catalog) Option {
+	return func(a *Auth) {
+		a.catalog = catalog
+	}
+}
+
+// WithLockout replaces the non-zero fields of the default lockout policy.
+func WithLockout(policy LockoutPolicy) Option {
+	return func(a *Auth) {
+		if policy.Threshold > 0 {
+			a.lockout.Threshold = policy.Threshold
+		}
+		if policy.Duration > 0 {
+			a.lockout.Duration = policy.Duration
+		}
+		if policy.MaxDuration > 0 {
+			a.lockout.MaxDuration = policy.MaxDuration
+		}
+		if policy.IPThreshold > 0 {
+			a.lockout.IPThreshold = policy.IPThreshold
+		}
+		if policy.IPWindow > 0 {
+			a.lockout.IPWindow = policy.IPWindow
+		}
+	}
+}
+
+// WithPasswordPolicy sets the rules new passwords are checked against. With
+// reportBreachedLogins, logins with a password in the policy's breach list
+// are logged.
+func WithPasswordPolicy(policy password.Policy, reportBreachedLogins bool) Option {
+	return func(a *Auth) {
+		a.passwordPolicy = policy
+		a.reportBreachedLogins = reportBreachedLogins
+	}
+}
+
+// WithPasswordHasher sets how new passwords are hashed. Hashes made
+// differently are replaced on the next successful login.
+func WithPasswordHasher(hasher password.Hasher) Option {
+	return func(a *Auth) {
+		a.hasher = hasher
+	}
+}
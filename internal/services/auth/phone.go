@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+const (
+	phoneCodeDigits = 6
+	phoneCodeTTL    = 10 * time.Minute
+	// phoneCodeResendInterval is how long a user has to wait before asking for
+	// another code.
+	phoneCodeResendInterval = time.Minute
+	maxPhoneCodeAttempts    = 5
+)
+
+var (
+	ErrPhoneMissing         = errors.New("no phone number")
+	ErrPhoneAlreadyVerified = errors.New("phone already verified")
+	ErrPhoneTaken           = errors.New("phone already verified by another user")
+	ErrPhoneCodeTooSoon     = errors.New("phone code requested too soon")
+	ErrInvalidPhoneCode     = errors.New("invalid phone code")
+)
+
+// SendPhoneCode sends a one-time code to the token owner's phone number.
+func (a *Auth) SendPhoneCode(ctx context.Context, jwtToken string) (bool, error) {
+	const op = "auth.SendPhoneCode"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := a.userProvider.GetUserByID(ctx, accessToken.Uid)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.Phone == "" {
+		return false, fmt.Errorf("%s: %w", op, ErrPhoneMissing)
+	}
+	if user.PhoneVerified {
+		return false, fmt.Errorf("%s: %w", op, ErrPhoneAlreadyVerified)
+	}
+
+	previous, err := a.phoneCodeProvider.GetPhoneCode(ctx, user.ID)
+	if err != nil && !errors.Is(err, sqlite.ErrCodeNotFound) {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err == nil && time.Since(previous.CreatedAt) < phoneCodeResendInterval {
+		return false, fmt.Errorf("%s: %w", op, ErrPhoneCodeTooSoon)
+	}
+	// Attempts carry over to the next code until the current one expires, so
+	// once they are used up a new code could not be checked anyway.
+	if err == nil && previous.Attempts >= maxPhoneCodeAttempts && time.Now().Before(previous.ExpiresAt) {
+		return false, fmt.Errorf("%s: %w", op, ErrPhoneCodeTooSoon)
+	}
+
+	code, err := generatePhoneCode()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	now := time.Now()
+	err = a.phoneCodeSaver.SavePhoneCode(ctx, models.PhoneCode{
+		UserID:    user.ID,
+		Phone:     user.Phone,
+		CodeHash:  phoneCodeHash(user.ID, code),
+		CreatedAt: now,
+		ExpiresAt: now.Add(phoneCodeTTL),
+	})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.sendPhoneCode(ctx, user, code); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return true, nil
+}
+
+// VerifyPhone checks a code sent by SendPhoneCode and marks the phone number
+// as verified, which also lets it be used to log in.
+func (a *Auth) VerifyPhone(ctx context.Context, jwtToken string, code string) (bool, error) {
+	const op = "auth.VerifyPhone"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := a.userProvider.GetUserByID(ctx, accessToken.Uid)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	stored, err := a.phoneCodeProvider.GetPhoneCode(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrCodeNotFound) {
+			return false, fmt.Errorf("%s: %w", op, ErrInvalidPhoneCode)
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	// A code sent to a number the user has since replaced proves nothing.
+	if time.Now().After(stored.ExpiresAt) || stored.Phone != user.Phone {
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidPhoneCode)
+	}
+	ok, err := a.phoneCodeUpdater.AddPhoneCodeAttempt(ctx, stored.ID, maxPhoneCodeAttempts)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok || subtle.ConstantTimeCompare([]byte(stored.CodeHash), []byte(phoneCodeHash(user.ID, code))) != 1 {
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidPhoneCode)
+	}
+
+	if err := a.userUpdater.SetPhoneVerified(ctx, user.ID, true); err != nil {
+		if errors.Is(err, sqlite.ErrPhoneTaken) {
+			return false, fmt.Errorf("%s: %w", op, ErrPhoneTaken)
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.phoneCodeUpdater.DeletePhoneCode(ctx, user.ID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return true, nil
+}
+
+func generatePhoneCode() (string, error) {
+	limit := big.NewInt(1)
+	for i := 0; i < phoneCodeDigits; i++ {
+		limit.Mul(limit, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", phoneCodeDigits, n), nil
+}
+
+func phoneCodeHash(userID int64, code string) string {
+	return hashToken(strconv.FormatInt(userID, 10) + ":" + code)
+}
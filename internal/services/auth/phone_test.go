@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// savePhoneCode replaces the phone code of login with code.
+func (ta testAuth) savePhoneCode(t *testing.T, login, code string) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := ta.userProvider.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	now := time.Now()
+	err = ta.phoneCodeSaver.SavePhoneCode(ctx, models.PhoneCode{
+		UserID:    user.ID,
+		Phone:     user.Phone,
+		CodeHash:  phoneCodeHash(user.ID, code),
+		CreatedAt: now,
+		ExpiresAt: now.Add(phoneCodeTTL),
+	})
+	if err != nil {
+		t.Fatalf("SavePhoneCode: %v", err)
+	}
+}
+
+func TestVerifyPhone(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	accessToken := ta.login(t, "alice").AccessToken
+
+	if _, err := ta.SendPhoneCode(ctx, accessToken); err != nil {
+		t.Fatalf("SendPhoneCode: %v", err)
+	}
+	if _, err := ta.SendPhoneCode(ctx, accessToken); !errors.Is(err, ErrPhoneCodeTooSoon) {
+		t.Fatalf("second SendPhoneCode = %v, want ErrPhoneCodeTooSoon", err)
+	}
+	// An unverified number does not sign anyone in.
+	if _, err := ta.Login(ctx, "+1555alice", testPassword, models.ClientInfo{}); err == nil {
+		t.Fatal("Login with an unverified phone number succeeded")
+	}
+
+	ta.savePhoneCode(t, "alice", "123456")
+	if _, err := ta.VerifyPhone(ctx, accessToken, "654321"); !errors.Is(err, ErrInvalidPhoneCode) {
+		t.Fatalf("VerifyPhone with a wrong code = %v, want ErrInvalidPhoneCode", err)
+	}
+	if _, err := ta.VerifyPhone(ctx, accessToken, "123456"); err != nil {
+		t.Fatalf("VerifyPhone: %v", err)
+	}
+	if _, err := ta.VerifyPhone(ctx, accessToken, "123456"); !errors.Is(err, ErrInvalidPhoneCode) {
+		t.Fatalf("reused phone code = %v, want ErrInvalidPhoneCode", err)
+	}
+	if _, err := ta.SendPhoneCode(ctx, accessToken); !errors.Is(err, ErrPhoneAlreadyVerified) {
+		t.Fatalf("SendPhoneCode after verification = %v, want ErrPhoneAlreadyVerified", err)
+	}
+	ta.login(t, "+1555alice")
+}
+
+func TestVerifyPhoneAttemptLimit(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	accessToken := ta.login(t, "alice").AccessToken
+	ta.savePhoneCode(t, "alice", "123456")
+
+	for i := 0; i < maxPhoneCodeAttempts; i++ {
+		if _, err := ta.VerifyPhone(ctx, accessToken, "000000"); !errors.Is(err, ErrInvalidPhoneCode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidPhoneCode", i+1, err)
+		}
+	}
+	if _, err := ta.VerifyPhone(ctx, accessToken, "123456"); !errors.Is(err, ErrInvalidPhoneCode) {
+		t.Fatalf("VerifyPhone after %d wrong codes = %v, want ErrInvalidPhoneCode", maxPhoneCodeAttempts, err)
+	}
+}
+
+func TestVerifiedPhoneIsUnique(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	if _, err := ta.RegisterNewUser(ctx, "bob@example.com", "bob", "+1555alice", testPassword); err != nil {
+		t.Fatalf("RegisterNewUser(bob): %v", err)
+	}
+
+	ta.savePhoneCode(t, "alice", "123456")
+	if _, err := ta.VerifyPhone(ctx, ta.login(t, "alice").AccessToken, "123456"); err != nil {
+		t.Fatalf("VerifyPhone(alice): %v", err)
+	}
+	ta.savePhoneCode(t, "bob", "123456")
+	if _, err := ta.VerifyPhone(ctx, ta.login(t, "bob").AccessToken, "123456"); !errors.Is(err, ErrPhoneTaken) {
+		t.Fatalf("VerifyPhone(bob) = %v, want ErrPhoneTaken", err)
+	}
+}
+
+func TestResendingPhoneCodeKeepsAttempts(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	accessToken := ta.login(t, "alice").AccessToken
+
+	ta.savePhoneCode(t, "alice", "123456")
+	for i := 0; i < maxPhoneCodeAttempts-1; i++ {
+		if _, err := ta.VerifyPhone(ctx, accessToken, "000000"); !errors.Is(err, ErrInvalidPhoneCode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidPhoneCode", i+1, err)
+		}
+	}
+	// A new code only has the attempts the old one had left.
+	ta.savePhoneCode(t, "alice", "654321")
+	if _, err := ta.VerifyPhone(ctx, accessToken, "000000"); !errors.Is(err, ErrInvalidPhoneCode) {
+		t.Fatalf("last attempt: got %v, want ErrInvalidPhoneCode", err)
+	}
+	if _, err := ta.VerifyPhone(ctx, accessToken, "654321"); !errors.Is(err, ErrInvalidPhoneCode) {
+		t.Fatalf("VerifyPhone after resending = %v, want ErrInvalidPhoneCode", err)
+	}
+
+	// No new code is sent while the used up one is still valid.
+	ta.exec(t, "UPDATE phoneCodes SET created_at = ?", time.Now().Add(-2*phoneCodeResendInterval).UTC())
+	if _, err := ta.SendPhoneCode(ctx, accessToken); !errors.Is(err, ErrPhoneCodeTooSoon) {
+		t.Fatalf("SendPhoneCode with no attempts left = %v, want ErrPhoneCodeTooSoon", err)
+	}
+
+	// Once it expires, the next code starts over.
+	ta.exec(t, "UPDATE phoneCodes SET expires_at = ?", time.Now().Add(-time.Second).UTC())
+	ta.savePhoneCode(t, "alice", "111111")
+	if _, err := ta.VerifyPhone(ctx, accessToken, "111111"); err != nil {
+		t.Fatalf("VerifyPhone after the code expired: %v", err)
+	}
+}
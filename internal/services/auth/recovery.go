@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeLength is the number of characters in a code, printed in two
+	// dash-separated halves.
+	recoveryCodeLength = 10
+	recoveryAlphabet   = "abcdefghijkmnpqrstuvwxyz23456789"
+)
+
+var ErrInvalidRecoveryCode = errors.New("invalid recovery code")
+
+// GenerateRecoveryCodes replaces the token owner's recovery codes with a new
+// set and returns it. The codes are only stored hashed, so this is the only
+// time they can be shown. They stand in for a second factor, so the owner
+// has to have one.
+//
+// The codes are random enough that a keyed SHA-256 protects them; a slow
+// password hash would only make every guess expensive for the server.
+func (a *Auth) GenerateRecoveryCodes(ctx context.Context, jwtToken string) ([]string, error) {
+	const op = "auth.GenerateRecoveryCodes"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	enrolled, err := a.mfaEnrolled(ctx, accessToken.Uid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !enrolled {
+		return nil, fmt.Errorf("%s: %w", op, ErrMFANotEnrolled)
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([][]byte, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, a.recoveryCodeHash(normalizeRecoveryCode(code)))
+	}
+
+	if err := a.recoveryCodeSaver.ReplaceRecoveryCodes(ctx, accessToken.Uid, hashes); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return codes, nil
+}
+
+// RecoveryCodesRemaining returns how many unused recovery codes the token
+// owner has left.
+func (a *Auth) RecoveryCodesRemaining(ctx context.Context, jwtToken string) (int, error) {
+	const op = "auth.RecoveryCodesRemaining"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	codes, err := a.recoveryCodeProvider.ListRecoveryCodes(ctx, accessToken.Uid)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return len(codes), nil
+}
+
+// LoginWithRecoveryCode completes a Login that returned an MFA token, using a
+// recovery code in place of the second factor.
+func (a *Auth) LoginWithRecoveryCode(ctx context.Context, mfaToken string, code string) (models.TokenPair, error) {
+	const op = "auth.LoginWithRecoveryCode"
+
+	challenge, err := a.mfaChallenge(ctx, mfaToken)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.checkMFACode(ctx, challenge, ErrInvalidRecoveryCode, func() (bool, error) {
+		return a.useRecoveryCode(ctx, challenge.UserID, code)
+	})
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return a.completeMFAChallenge(ctx, op, challenge)
+}
+
+// ResetPasswordWithRecoveryCode sets a new password for a user who can access
+// neither their mailbox nor their account, spending one recovery code. Wrong
+// codes count towards the same lockout as failed logins. The new password is
+// only checked against the policy once the code is, so the answer says
+// nothing about an account to someone without one of its codes, and a
+// password the policy refuses leaves the code unspent.
+func (a *Auth) ResetPasswordWithRecoveryCode(ctx context.Context, login, code, newPassword string, client models.ClientInfo) (bool, error) {
+	const op = "auth.ResetPasswordWithRecoveryCode"
+
+	if err := a.checkIPFailures(ctx, client.IP); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := a.userProvider.GetUser(ctx, login)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrUserNotFound) {
+			a.addIPFailure(ctx, client.IP)
+			return false, fmt.Errorf("%s: %w", op, ErrInvalidRecoveryCode)
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.checkCredential(ctx, user, client.IP, ErrInvalidRecoveryCode, func() (bool, error) {
+		return a.hasRecoveryCode(ctx, user.ID, code)
+	})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.passwordPolicy.Check(newPassword, user.Login, user.Email); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	used, err := a.useRecoveryCode(ctx, user.ID, code)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if !used {
+		// A concurrent request spent the code first.
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidRecoveryCode)
+	}
+
+	passHash, err := a.hasher.Hash(newPassword)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	result, err := a.userUpdater.UpdatePassword(ctx, user.ID, passHash)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.invalidateTokens(ctx, user.ID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return result, nil
+}
+
+// hasRecoveryCode reports whether the user has an unused recovery code
+// matching code, without spending it.
+func (a *Auth) hasRecoveryCode(ctx context.Context, userID int64, code string) (bool, error) {
+	code = normalizeRecoveryCode(code)
+	if len(code) != recoveryCodeLength {
+		return false, nil
+	}
+	stored, err := a.recoveryCodeProvider.ListRecoveryCodes(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	codeHash := a.recoveryCodeHash(code)
+	for _, recoveryCode := range stored {
+		if hmac.Equal(recoveryCode.CodeHash, codeHash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// useRecoveryCode spends the user's recovery code matching code. It reports
+// false when none matches.
+func (a *Auth) useRecoveryCode(ctx context.Context, userID int64, code string) (bool, error) {
+	code = normalizeRecoveryCode(code)
+	if len(code) != recoveryCodeLength {
+		return false, nil
+	}
+	return a.recoveryCodeUpdater.UseRecoveryCode(ctx, userID, a.recoveryCodeHash(code))
+}
+
+// recoveryCodeHash is the HMAC-SHA256 of a normalized code under the MFA key.
+func (a *Auth) recoveryCodeHash(code string) []byte {
+	mac := hmac.New(sha256.New, a.mfaKey)
+	mac.Write([]byte(code))
+	return mac.Sum(nil)
+}
+
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		// The alphabet has 32 characters, so this keeps the distribution uniform.
+		b[i] = recoveryAlphabet[int(b[i])%len(recoveryAlphabet)]
+	}
+	half := recoveryCodeLength / 2
+	return string(b[:half]) + "-" + string(b[half:]), nil
+}
+
+// normalizeRecoveryCode strips the separators and case a user may type.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
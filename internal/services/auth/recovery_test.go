@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/password"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGenerateRecoveryCodesRequiresMFA(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	accessToken := ta.login(t, "alice").AccessToken
+
+	if _, err := ta.GenerateRecoveryCodes(ctx, accessToken); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Fatalf("GenerateRecoveryCodes without MFA = %v, want ErrMFANotEnrolled", err)
+	}
+	ta.enrollTOTP(t, accessToken)
+	codes, err := ta.GenerateRecoveryCodes(ctx, accessToken)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes: %v", err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+}
+
+func TestRecoveryCodesAreKeyedAndSpentOnce(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	accessToken := ta.login(t, "alice").AccessToken
+	ta.enrollTOTP(t, accessToken)
+	codes, err := ta.GenerateRecoveryCodes(ctx, accessToken)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes: %v", err)
+	}
+
+	user, err := ta.userProvider.GetUser(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	stored, err := ta.recoveryCodeProvider.ListRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("ListRecoveryCodes: %v", err)
+	}
+	unkeyed := sha256.Sum256([]byte(normalizeRecoveryCode(codes[0])))
+	for _, code := range stored {
+		if bytes.Equal(code.CodeHash, unkeyed[:]) {
+			t.Fatal("recovery code stored as an unkeyed SHA-256")
+		}
+	}
+
+	// Codes are accepted however the user types them, but only once.
+	const newPassword = "An0ther-Horse-Battery!"
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))
+	if _, err := ta.ResetPasswordWithRecoveryCode(ctx, "alice", typed, newPassword, models.ClientInfo{}); err != nil {
+		t.Fatalf("ResetPasswordWithRecoveryCode: %v", err)
+	}
+	if _, err := ta.ResetPasswordWithRecoveryCode(ctx, "alice", codes[0], newPassword, models.ClientInfo{}); !errors.Is(err, ErrInvalidRecoveryCode) {
+		t.Fatalf("reused recovery code = %v, want ErrInvalidRecoveryCode", err)
+	}
+	stored, err = ta.recoveryCodeProvider.ListRecoveryCodes(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("ListRecoveryCodes: %v", err)
+	}
+	if len(stored) != recoveryCodeCount-1 {
+		t.Fatalf("%d codes left, want %d", len(stored), recoveryCodeCount-1)
+	}
+}
+
+func TestResetPasswordWithRecoveryCodeChecksCodeBeforePolicy(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	accessToken := ta.login(t, "alice").AccessToken
+	ta.enrollTOTP(t, accessToken)
+	codes, err := ta.GenerateRecoveryCodes(ctx, accessToken)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes: %v", err)
+	}
+	const weakPassword = "short"
+
+	// Without a valid code, known and unknown logins get the same answer,
+	// whatever the new password.
+	_, unknownErr := ta.ResetPasswordWithRecoveryCode(ctx, "nobody", "aaaaa-bbbbb", weakPassword, models.ClientInfo{})
+	_, knownErr := ta.ResetPasswordWithRecoveryCode(ctx, "alice", "aaaaa-bbbbb", weakPassword, models.ClientInfo{})
+	if !errors.Is(unknownErr, ErrInvalidRecoveryCode) || !errors.Is(knownErr, ErrInvalidRecoveryCode) {
+		t.Fatalf("unknown login = %v, known login = %v, want ErrInvalidRecoveryCode for both", unknownErr, knownErr)
+	}
+
+	// With a valid code the policy applies, and a refused password leaves the
+	// code unspent.
+	var policyErr *password.PolicyError
+	if _, err := ta.ResetPasswordWithRecoveryCode(ctx, "alice", codes[0], weakPassword, models.ClientInfo{}); !errors.As(err, &policyErr) {
+		t.Fatalf("weak password with a valid code = %v, want a *password.PolicyError", err)
+	}
+	if _, err := ta.ResetPasswordWithRecoveryCode(ctx, "alice", codes[0], "An0ther-Horse-Battery!", models.ClientInfo{}); err != nil {
+		t.Fatalf("ResetPasswordWithRecoveryCode: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+// ListSessions returns the active sessions of the token's owner, marking the
+// one the token belongs to as current.
+func (a *Auth) ListSessions(ctx context.Context, jwtToken string) ([]models.Session, error) {
+	const op = "auth.ListSessions"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	sessions, err := a.sessionProvider.ListSessions(ctx, accessToken.Uid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == accessToken.Sid
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs out one of the token owner's sessions.
+func (a *Auth) RevokeSession(ctx context.Context, jwtToken string, sessionID string) (bool, error) {
+	const op = "auth.RevokeSession"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.revokeSession(ctx, accessToken.Uid, sessionID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return true, nil
+}
+
+// RevokeAllSessions signs out every session of the token's owner, or every
+// other session when keepCurrent is set.
+func (a *Auth) RevokeAllSessions(ctx context.Context, jwtToken string, keepCurrent bool) (int, error) {
+	const op = "auth.RevokeAllSessions"
+
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	exceptID := ""
+	if keepCurrent {
+		exceptID = accessToken.Sid
+	}
+	revoked, err := a.revokeAllSessions(ctx, accessToken.Uid, exceptID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return revoked, nil
+}
+
+// startSession signs the user in on the client's device. clientID and scope
+// are empty unless an OAuth client is signing the user in.
+func (a *Auth) startSession(ctx context.Context, userID int64, client models.ClientInfo, clientID string, scope string) (models.Session, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		DeviceName: client.DeviceName,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		ClientID:   clientID,
+		Scope:      scope,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(a.refreshTokenTTL),
+	}
+	if err := a.sessionSaver.SaveSession(ctx, session); err != nil {
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+// sessionActive reports whether the session exists and is not revoked. Active
+// sessions are cached for the access token TTL so validation does not hit the
+// database on every call.
+func (a *Auth) sessionActive(ctx context.Context, sessionID string) (bool, error) {
+	if sessionID == "" {
+		return false, nil
+	}
+	if _, ok := a.cache.Get(ctx, sessionKey(sessionID)); ok {
+		return true, nil
+	}
+
+	session, err := a.sessionProvider.GetSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrSessionNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if session.Revoked || time.Now().After(session.ExpiresAt) {
+		return false, nil
+	}
+
+	if err := a.cache.Set(ctx, sessionKey(sessionID), session.UserID, a.accessTokenTTL); err != nil {
+		a.log.Error("failed to save in cache", slog.String("error", err.Error()))
+	}
+	return true, nil
+}
+
+func (a *Auth) revokeSession(ctx context.Context, userID int64, sessionID string) error {
+	ok, err := a.sessionUpdater.RevokeSession(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	_ = a.cache.Delete(ctx, sessionKey(sessionID))
+	return nil
+}
+
+func (a *Auth) revokeAllSessions(ctx context.Context, userID int64, exceptID string) (int, error) {
+	ids, err := a.sessionUpdater.RevokeAllSessions(ctx, userID, exceptID)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		_ = a.cache.Delete(ctx, sessionKey(id))
+	}
+	return len(ids), nil
+}
+
+func sessionKey(sessionID string) string {
+	return "session:" + sessionID
+}
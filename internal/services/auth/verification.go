@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/encryption/token"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultVerificationTokenTTL = 48 * time.Hour
+
+var (
+	ErrEmailNotVerified         = errors.New("email not verified")
+	ErrInvalidVerificationToken = errors.New("invalid verification token")
+)
+
+// VerifyEmail marks the address a verification token was issued for as
+// verified. A token issued for an address the user has since replaced is
+// rejected.
+func (a *Auth) VerifyEmail(ctx context.Context, verificationToken string) (bool, error) {
+	const op = "auth.VerifyEmail"
+
+	stored, err := a.verificationTokenProvider.GetVerificationToken(ctx, hashToken(verificationToken))
+	if err != nil {
+		if errors.Is(err, sqlite.ErrTokenNotFound) {
+			return false, fmt.Errorf("%s: %w", op, ErrInvalidVerificationToken)
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidVerificationToken)
+	}
+	user, err := a.userProvider.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.Email != stored.Email {
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidVerificationToken)
+	}
+
+	if err := a.userUpdater.SetEmailVerified(ctx, user.ID, true); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.verificationTokenUpdater.DeleteVerificationTokens(ctx, user.ID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return true, nil
+}
+
+// ResendVerificationEmail sends a new verification link to a user whose email
+// is not verified yet, replacing any link sent before. It succeeds for unknown
+// logins and verified users too, so it cannot be used to probe for accounts;
+// callers are expected to rate limit it.
+func (a *Auth) ResendVerificationEmail(ctx context.Context, login string) (bool, error) {
+	const op = "auth.ResendVerificationEmail"
+
+	user, err := a.userProvider.GetUser(ctx, login)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrUserNotFound) {
+			return true, nil
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.EmailVerified {
+		return true, nil
+	}
+	if err := a.verificationTokenUpdater.DeleteVerificationTokens(ctx, user.ID); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.issueVerificationToken(ctx, user); err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return true, nil
+}
+
+// issueVerificationToken creates a token proving control of the user's
+// current email address.
+func (a *Auth) issueVerificationToken(ctx context.Context, user models.User) error {
+	verificationToken, err := token.GenerateToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(a.verificationTokenTTL)
+	err = a.verificationTokenSaver.SaveVerificationToken(ctx, models.VerificationToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(verificationToken),
+		Email:     user.Email,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.sendVerificationEmail(ctx, user, verificationToken, expiresAt)
+}
+
+// checkEmailVerified enforces the verified email policy before signing in.
+func (a *Auth) checkEmailVerified(user models.User) error {
+	if a.requireVerifiedEmail && !user.EmailVerified {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
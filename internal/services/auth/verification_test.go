@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/pkg/notifier"
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// outbox records the messages the service sends instead of delivering them.
+type outbox struct {
+	mu       sync.Mutex
+	messages []notifier.Message
+}
+
+func (o *outbox) Send(_ context.Context, msg notifier.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+var linkToken = regexp.MustCompile(`[?&]token=([^&\s"<]+)`)
+
+// lastToken returns the token in the link of the last message sent to to.
+func (o *outbox) lastToken(t *testing.T, to string) string {
+	t.Helper()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i := len(o.messages) - 1; i >= 0; i-- {
+		if o.messages[i].To != to {
+			continue
+		}
+		match := linkToken.FindStringSubmatch(o.messages[i].Body)
+		if match == nil {
+			t.Fatalf("no token in the message to %s: %q", to, o.messages[i].Body)
+		}
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			t.Fatalf("QueryUnescape: %v", err)
+		}
+		return token
+	}
+	t.Fatalf("no message sent to %s", to)
+	return ""
+}
+
+func newVerifyingTestAuth(t *testing.T) (testAuth, *outbox) {
+	t.Helper()
+	box := &outbox{}
+	ta := newTestAuth(t,
+		WithEmailVerification(true, time.Hour),
+		WithNotifier(box, Links{VerifyEmail: "https://example.com/verify"}),
+	)
+	return ta, box
+}
+
+// saveVerificationToken stores token as a verification token for email,
+// issued to login.
+func (ta testAuth) saveVerificationToken(t *testing.T, login, token, email string, expiresAt time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	user, err := ta.userProvider.GetUser(ctx, login)
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	err = ta.verificationTokenSaver.SaveVerificationToken(ctx, models.VerificationToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		Email:     email,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("SaveVerificationToken: %v", err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t, WithEmailVerification(true, time.Hour))
+	ta.newUser(t, "alice")
+
+	if _, err := ta.Login(ctx, "alice", testPassword, models.ClientInfo{}); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("Login before verification = %v, want ErrEmailNotVerified", err)
+	}
+	ta.saveVerificationToken(t, "alice", "valid", "alice@example.com", time.Now().Add(time.Hour))
+	if _, err := ta.VerifyEmail(ctx, "valid"); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	ta.login(t, "alice")
+	if _, err := ta.VerifyEmail(ctx, "valid"); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Fatalf("reused verification token = %v, want ErrInvalidVerificationToken", err)
+	}
+}
+
+func TestVerifyEmailRejectsExpiredAndStaleTokens(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t, WithEmailVerification(true, time.Hour))
+	ta.newUser(t, "alice")
+
+	ta.saveVerificationToken(t, "alice", "expired", "alice@example.com", time.Now().Add(-time.Minute))
+	if _, err := ta.VerifyEmail(ctx, "expired"); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Fatalf("expired verification token = %v, want ErrInvalidVerificationToken", err)
+	}
+	if _, err := ta.VerifyEmail(ctx, "unknown"); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Fatalf("unknown verification token = %v, want ErrInvalidVerificationToken", err)
+	}
+
+	ta.saveVerificationToken(t, "alice", "current", "alice@example.com", time.Now().Add(time.Hour))
+	ta.saveVerificationToken(t, "alice", "stale", "alice@example.com", time.Now().Add(time.Hour))
+	if _, err := ta.VerifyEmail(ctx, "current"); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if _, err := ta.VerifyEmail(ctx, "stale"); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Fatalf("second token after verification = %v, want ErrInvalidVerificationToken", err)
+	}
+	// A new address has to be verified again, and tokens for the old one no
+	// longer count.
+	if _, err := ta.UpdateUser(ctx, ta.login(t, "alice").AccessToken, "alice@example.org", "", ""); err != nil {
+		t.Fatalf("UpdateUser: %v", err)
+	}
+	if _, err := ta.Login(ctx, "alice", testPassword, models.ClientInfo{}); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("Login after changing the email = %v, want ErrEmailNotVerified", err)
+	}
+	ta.saveVerificationToken(t, "alice", "old-address", "alice@example.com", time.Now().Add(time.Hour))
+	if _, err := ta.VerifyEmail(ctx, "old-address"); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Fatalf("token for the old address = %v, want ErrInvalidVerificationToken", err)
+	}
+}
+
+func TestRegisterSendsVerificationToTheNewUser(t *testing.T) {
+	ctx := context.Background()
+	ta, box := newVerifyingTestAuth(t)
+	ta.newUser(t, "alice")
+
+	// The new login is alice's email, so looking the user up by it would
+	// find alice.
+	ok, err := ta.RegisterNewUser(ctx, "carol@example.com", "alice@example.com", "", testPassword)
+	if err != nil || !ok {
+		t.Fatalf("RegisterNewUser = %v, %v", ok, err)
+	}
+	if _, err := ta.VerifyEmail(ctx, box.lastToken(t, "carol@example.com")); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if _, err := ta.Login(ctx, "carol@example.com", testPassword, models.ClientInfo{}); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	ctx := context.Background()
+	ta, box := newVerifyingTestAuth(t)
+	ta.newUser(t, "alice")
+	first := box.lastToken(t, "alice@example.com")
+
+	if _, err := ta.ResendVerificationEmail(ctx, "alice"); err != nil {
+		t.Fatalf("ResendVerificationEmail: %v", err)
+	}
+	second := box.lastToken(t, "alice@example.com")
+	if second == first {
+		t.Fatal("ResendVerificationEmail sent the same token again")
+	}
+	// Only the latest link works.
+	if _, err := ta.VerifyEmail(ctx, first); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Fatalf("replaced verification token = %v, want ErrInvalidVerificationToken", err)
+	}
+	if _, err := ta.VerifyEmail(ctx, second); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	ta.login(t, "alice")
+}
+
+func TestResendVerificationEmailSendsNothingToUnknownOrVerifiedUsers(t *testing.T) {
+	ctx := context.Background()
+	ta, box := newVerifyingTestAuth(t)
+	ta.newUser(t, "alice")
+	if _, err := ta.VerifyEmail(ctx, box.lastToken(t, "alice@example.com")); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	sent := len(box.messages)
+
+	for _, login := range []string{"alice", "nobody"} {
+		if ok, err := ta.ResendVerificationEmail(ctx, login); err != nil || !ok {
+			t.Fatalf("ResendVerificationEmail(%s) = %v, %v", login, ok, err)
+		}
+	}
+	if len(box.messages) != sent {
+		t.Fatalf("%d messages sent, want none", len(box.messages)-sent)
+	}
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/webauthn"
+	"AuthGrpc/internal/pkg/storage/sqlite"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrWebAuthnDisabled          = errors.New("webauthn is not configured")
+	ErrInvalidWebAuthnChallenge  = errors.New("invalid webauthn challenge")
+	ErrInvalidWebAuthnCredential = errors.New("invalid webauthn credential")
+	ErrWebAuthnCredentialExists  = errors.New("webauthn credential already registered")
+)
+
+// BeginWebAuthnRegistration starts registering a passkey for the token's
+// owner and returns the options to pass to navigator.credentials.create.
+func (a *Auth) BeginWebAuthnRegistration(ctx context.Context, jwtToken string) (webauthn.CreationOptions, error) {
+	const op = "auth.BeginWebAuthnRegistration"
+
+	if a.webauthn == nil {
+		return webauthn.CreationOptions{}, fmt.Errorf("%s: %w", op, ErrWebAuthnDisabled)
+	}
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return webauthn.CreationOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := a.userProvider.GetUserByID(ctx, accessToken.Uid)
+	if err != nil {
+		return webauthn.CreationOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+	existing, err := a.webauthnCredentialIDs(ctx, user.ID)
+	if err != nil {
+		return webauthn.CreationOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	challenge, err := a.startWebAuthnChallenge(ctx, webauthn.TypeCreate, user.ID)
+	if err != nil {
+		return webauthn.CreationOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+	webauthnUser := webauthn.User{
+		ID:          webauthn.EncodeUserID(userHandle(user.ID)),
+		Name:        user.Login,
+		DisplayName: user.Login,
+	}
+	return a.webauthn.NewCreationOptions(challenge, webauthnUser, existing), nil
+}
+
+// FinishWebAuthnRegistration verifies the authenticator's response and
+// stores the new credential under name.
+func (a *Auth) FinishWebAuthnRegistration(ctx context.Context, jwtToken, name string, clientDataJSON, attestationObject []byte) (bool, error) {
+	const op = "auth.FinishWebAuthnRegistration"
+
+	if a.webauthn == nil {
+		return false, fmt.Errorf("%s: %w", op, ErrWebAuthnDisabled)
+	}
+	accessToken, err := a.firstPartyToken(ctx, jwtToken)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	challenge, ceremony, err := a.webauthnChallenge(ctx, webauthn.TypeCreate, clientDataJSON)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if ceremony.UserID != accessToken.Uid {
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidWebAuthnChallenge)
+	}
+
+	credential, err := a.webauthn.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w: %w", op, ErrInvalidWebAuthnCredential, err)
+	}
+	err = a.webauthnSaver.SaveWebAuthnCredential(ctx, models.WebAuthnCredential{
+		UserID:       accessToken.Uid,
+		CredentialID: credential.ID,
+		PublicKey:    credential.PublicKey,
+		SignCount:    credential.SignCount,
+		AAGUID:       credential.AAGUID,
+		Name:         name,
+	})
+	if err != nil {
+		if errors.Is(err, sqlite.ErrCredentialExists) {
+			return false, fmt.Errorf("%s: %w", op, ErrWebAuthnCredentialExists)
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return true, nil
+}
+
+// BeginWebAuthnLogin starts a passkey login and returns the options to pass
+// to navigator.credentials.get. With an empty login the authenticator offers
+// any of its discoverable credentials. An unknown login gets the same answer
+// as a user without passkeys.
+func (a *Auth) BeginWebAuthnLogin(ctx context.Context, login string) (webauthn.RequestOptions, error) {
+	const op = "auth.BeginWebAuthnLogin"
+
+	if a.webauthn == nil {
+		return webauthn.RequestOptions{}, fmt.Errorf("%s: %w", op, ErrWebAuthnDisabled)
+	}
+
+	var (
+		userID int64
+		allow  [][]byte
+	)
+	if login != "" {
+		user, err := a.userProvider.GetUser(ctx, login)
+		if err != nil && !errors.Is(err, sqlite.ErrUserNotFound) {
+			return webauthn.RequestOptions{}, fmt.Errorf("%s: %w", op, err)
+		}
+		if err == nil {
+			userID = user.ID
+			allow, err = a.webauthnCredentialIDs(ctx, user.ID)
+			if err != nil {
+				return webauthn.RequestOptions{}, fmt.Errorf("%s: %w", op, err)
+			}
+		}
+	}
+
+	challenge, err := a.startWebAuthnChallenge(ctx, webauthn.TypeGet, userID)
+	if err != nil {
+		return webauthn.RequestOptions{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return a.webauthn.NewRequestOptions(challenge, allow), nil
+}
+
+// FinishWebAuthnLogin verifies a passkey assertion and signs its owner in.
+// A passkey that verified the user stands for both factors; one that only
+// proved their presence is a first factor like a password, so an enrolled
+// TOTP factor is still asked for.
+func (a *Auth) FinishWebAuthnLogin(ctx context.Context, assertion webauthn.Assertion, client models.ClientInfo) (models.LoginResult, error) {
+	const op = "auth.FinishWebAuthnLogin"
+
+	if a.webauthn == nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, ErrWebAuthnDisabled)
+	}
+	challenge, ceremony, err := a.webauthnChallenge(ctx, webauthn.TypeGet, assertion.ClientDataJSON)
+	if err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	credential, err := a.webauthnProvider.GetWebAuthnCredential(ctx, assertion.CredentialID)
+	if err != nil {
+		if errors.Is(err, sqlite.ErrCredentialNotFound) {
+			return models.LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidWebAuthnCredential)
+		}
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if ceremony.UserID != 0 && ceremony.UserID != credential.UserID {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidWebAuthnCredential)
+	}
+	if len(assertion.UserHandle) != 0 && !bytes.Equal(assertion.UserHandle, userHandle(credential.UserID)) {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, ErrInvalidWebAuthnCredential)
+	}
+
+	signCount, userVerified, err := a.webauthn.VerifyAssertion(challenge, credential.PublicKey, credential.SignCount, assertion)
+	if err != nil {
+		if errors.Is(err, webauthn.ErrSignCountRegression) {
+			a.log.Warn("webauthn sign count regression, credential may be cloned", slog.Int64("credential", credential.ID))
+		}
+		return models.LoginResult{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidWebAuthnCredential, err)
+	}
+	if err := a.webauthnUpdater.UpdateWebAuthnSignCount(ctx, credential.ID, signCount); err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.userProvider.GetUserByID(ctx, credential.UserID)
+	if err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	lock, err := a.accountLockProvider.GetAccountLock(ctx, user.ID)
+	if err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if lock.Locked(time.Now()) {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, ErrAccountLocked)
+	}
+
+	if !userVerified {
+		result, err := a.firstFactorPassed(ctx, user, client)
+		if err != nil {
+			return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+		}
+		return result, nil
+	}
+	tokens, err := a.signIn(ctx, user, client)
+	if err != nil {
+		return models.LoginResult{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return models.LoginResult{Tokens: tokens}, nil
+}
+
+func (a *Auth) webauthnCredentialIDs(ctx context.Context, userID int64) ([][]byte, error) {
+	credentials, err := a.webauthnProvider.ListWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([][]byte, 0, len(credentials))
+	for _, credential := range credentials {
+		ids = append(ids, credential.CredentialID)
+	}
+	return ids, nil
+}
+
+func (a *Auth) startWebAuthnChallenge(ctx context.Context, ceremony string, userID int64) ([]byte, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+	ttl := a.webauthn.TimeoutOrDefault()
+	state := models.WebAuthnChallenge{
+		Type:      ceremony,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := a.cache.Set(ctx, webauthnChallengeKey(challenge), state, ttl); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// webauthnChallenge looks up and consumes the ceremony the client data
+// answers, so every challenge can be used only once.
+func (a *Auth) webauthnChallenge(ctx context.Context, ceremony string, clientDataJSON []byte) ([]byte, models.WebAuthnChallenge, error) {
+	clientData, err := webauthn.ParseClientData(clientDataJSON)
+	if err != nil {
+		return nil, models.WebAuthnChallenge{}, ErrInvalidWebAuthnChallenge
+	}
+	key := webauthnChallengeKey(clientData.Challenge)
+	value, ok := a.cache.Take(ctx, key)
+	if !ok {
+		return nil, models.WebAuthnChallenge{}, ErrInvalidWebAuthnChallenge
+	}
+	state, ok := value.(models.WebAuthnChallenge)
+	if !ok || state.Type != ceremony || time.Now().After(state.ExpiresAt) {
+		return nil, models.WebAuthnChallenge{}, ErrInvalidWebAuthnChallenge
+	}
+	return clientData.Challenge, state, nil
+}
+
+// userHandle is the WebAuthn user.id of a user.
+func userHandle(userID int64) []byte {
+	return []byte(strconv.FormatInt(userID, 10))
+}
+
+func webauthnChallengeKey(challenge []byte) string {
+	return "webauthnChallenge:" + hashToken(string(challenge))
+}
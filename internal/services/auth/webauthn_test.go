@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"AuthGrpc/internal/domain/models"
+	"AuthGrpc/internal/lib/webauthn"
+	"AuthGrpc/internal/lib/webauthn/virtual"
+	"context"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// registerPasskey registers a credential on a new virtual authenticator for
+// the owner of accessToken.
+func (ta testAuth) registerPasskey(t *testing.T, accessToken string) *virtual.Authenticator {
+	t.Helper()
+	ctx := context.Background()
+	authenticator := virtual.New("example.com", "https://example.com")
+	options, err := ta.BeginWebAuthnRegistration(ctx, accessToken)
+	if err != nil {
+		t.Fatalf("BeginWebAuthnRegistration: %v", err)
+	}
+	_, clientDataJSON, attestationObject, err := authenticator.Create(decodeBase64URL(t, options.Challenge), decodeBase64URL(t, options.User.ID))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := ta.FinishWebAuthnRegistration(ctx, accessToken, "key", clientDataJSON, attestationObject); err != nil {
+		t.Fatalf("FinishWebAuthnRegistration: %v", err)
+	}
+	return authenticator
+}
+
+// passkeyAssertion starts a passkey login for login and answers it.
+func (ta testAuth) passkeyAssertion(t *testing.T, authenticator *virtual.Authenticator, login string) webauthn.Assertion {
+	t.Helper()
+	options, err := ta.BeginWebAuthnLogin(context.Background(), login)
+	if err != nil {
+		t.Fatalf("BeginWebAuthnLogin: %v", err)
+	}
+	assertion, err := authenticator.Get(decodeBase64URL(t, options.Challenge))
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	return assertion
+}
+
+func decodeBase64URL(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return b
+}
+
+func TestWebAuthnLogin(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	authenticator := ta.registerPasskey(t, ta.login(t, "alice").AccessToken)
+
+	assertion := ta.passkeyAssertion(t, authenticator, "alice")
+	result, err := ta.FinishWebAuthnLogin(ctx, assertion, models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("FinishWebAuthnLogin: %v", err)
+	}
+	if result.Tokens.AccessToken == "" || result.MFAToken != "" {
+		t.Fatalf("FinishWebAuthnLogin = %+v, want tokens", result)
+	}
+	if _, err := ta.FinishWebAuthnLogin(ctx, assertion, models.ClientInfo{}); !errors.Is(err, ErrInvalidWebAuthnChallenge) {
+		t.Fatalf("replayed assertion = %v, want ErrInvalidWebAuthnChallenge", err)
+	}
+}
+
+func TestWebAuthnChallengeIsUsedOnce(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	authenticator := ta.registerPasskey(t, ta.login(t, "alice").AccessToken)
+	assertion := ta.passkeyAssertion(t, authenticator, "alice")
+
+	const attempts = 8
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ta.FinishWebAuthnLogin(ctx, assertion, models.ClientInfo{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrInvalidWebAuthnChallenge):
+			t.Fatalf("got %v, want ErrInvalidWebAuthnChallenge", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d concurrent logins succeeded with one challenge, want 1", succeeded)
+	}
+}
+
+func TestWebAuthnLoginWithoutUserVerificationAsksForTOTP(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	accessToken := ta.login(t, "alice").AccessToken
+	authenticator := ta.registerPasskey(t, accessToken)
+	ta.enrollTOTP(t, accessToken)
+
+	authenticator.UserVerified = false
+	result, err := ta.FinishWebAuthnLogin(ctx, ta.passkeyAssertion(t, authenticator, "alice"), models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("FinishWebAuthnLogin: %v", err)
+	}
+	if result.MFAToken == "" || result.Tokens.AccessToken != "" {
+		t.Fatalf("FinishWebAuthnLogin = %+v, want an MFA challenge", result)
+	}
+
+	authenticator.UserVerified = true
+	result, err = ta.FinishWebAuthnLogin(ctx, ta.passkeyAssertion(t, authenticator, "alice"), models.ClientInfo{})
+	if err != nil {
+		t.Fatalf("FinishWebAuthnLogin: %v", err)
+	}
+	if result.Tokens.AccessToken == "" {
+		t.Fatalf("FinishWebAuthnLogin = %+v, want tokens", result)
+	}
+}
+
+func TestWebAuthnLoginOnLockedAccount(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAuth(t)
+	ta.newUser(t, "alice")
+	authenticator := ta.registerPasskey(t, ta.login(t, "alice").AccessToken)
+	ta.failLogins(t, "alice", defaultLockoutPolicy.Threshold, models.ClientInfo{})
+
+	_, err := ta.FinishWebAuthnLogin(ctx, ta.passkeyAssertion(t, authenticator, "alice"), models.ClientInfo{})
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("FinishWebAuthnLogin on a locked account = %v, want ErrAccountLocked", err)
+	}
+	if lock := ta.accountLock(t, "alice"); !lock.Locked(time.Now()) || lock.Lockouts != 1 {
+		t.Fatalf("lock after a passkey login = %+v, want the account still locked", lock)
+	}
+}
@@ -0,0 +1,11 @@
+#!/bin/bash
+
+set -e
+
+readonly proto_dir="authproto/proto"
+readonly out_dir="authproto/gen/go"
+
+protoc -I "$proto_dir" \
+    --go_out="$out_dir" --go_opt=paths=source_relative \
+    --go-grpc_out="$out_dir" --go-grpc_opt=paths=source_relative \
+    sso/sso.proto